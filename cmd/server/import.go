@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/ArtemZ007/wb-l0/internal/domain/model"
+	"github.com/ArtemZ007/wb-l0/internal/repository/database"
+	"github.com/ArtemZ007/wb-l0/pkg/config"
+	"github.com/ArtemZ007/wb-l0/pkg/logger"
+)
+
+// importUsage описывает формат подкоманды import.
+const importUsage = "использование: import [-batch N] <файл.json|файл.jsonl>"
+
+// runImport загружает заказы из файла пакетами через SaveOrders.
+// Файл может содержать JSON-массив заказов или по одному заказу в строке.
+func runImport(cfg config.IConfiguration, log logger.Logger, args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	batchSize := flags.Int("batch", 1000, "число заказов в одном пакете")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 || *batchSize <= 0 {
+		return errors.New(importUsage)
+	}
+	path := flags.Arg(0)
+
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("не удалось открыть файл импорта: %w", err)
+	}
+	defer file.Close()
+
+	store, err := openStorage(cfg, log)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	ctx := database.WithRevisionSource(context.Background(), model.RevisionSource{Kind: model.SourceAdmin, Ref: "import:" + path})
+	total := database.BulkResult{}
+	batch := make([]model.Order, 0, *batchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		// При ошибке результат описывает уже зафиксированную часть пакета
+		result, err := store.orders.SaveOrders(ctx, batch)
+		if result != nil {
+			total.Inserted += result.Inserted
+			total.Overwritten += result.Overwritten
+			total.Skipped += result.Skipped
+			log.Info(fmt.Sprintf("Импортировано заказов: новых %d, перезаписано %d, пропущено %d",
+				total.Inserted, total.Overwritten, total.Skipped))
+		}
+		if err != nil {
+			return err
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	err = decodeOrders(file, func(order model.Order) error {
+		batch = append(batch, order)
+		if len(batch) >= *batchSize {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return flush()
+}
+
+// decodeOrders последовательно декодирует заказы из JSON-массива или потока JSON-объектов.
+func decodeOrders(r io.Reader, fn func(order model.Order) error) error {
+	reader := bufio.NewReader(r)
+	decoder := json.NewDecoder(reader)
+
+	// Определение формата по первому значимому символу
+	for {
+		b, err := reader.Peek(1)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if b[0] == ' ' || b[0] == '\t' || b[0] == '\r' || b[0] == '\n' {
+			_, _ = reader.ReadByte()
+			continue
+		}
+		if b[0] == '[' {
+			if _, err := decoder.Token(); err != nil {
+				return err
+			}
+		}
+		break
+	}
+
+	for decoder.More() {
+		var order model.Order
+		if err := decoder.Decode(&order); err != nil {
+			return fmt.Errorf("ошибка при разборе заказа: %w", err)
+		}
+		if err := fn(order); err != nil {
+			return err
+		}
+	}
+	return nil
+}
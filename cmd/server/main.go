@@ -11,90 +11,127 @@ import (
 	"syscall"
 
 	httpQS "github.com/ArtemZ007/wb-l0/internal/delivery/http"
-	"github.com/ArtemZ007/wb-l0/internal/domain/model"
 	"github.com/ArtemZ007/wb-l0/internal/repository/cache"
 	"github.com/ArtemZ007/wb-l0/internal/repository/database"
 	"github.com/ArtemZ007/wb-l0/internal/subscription"
 	"github.com/ArtemZ007/wb-l0/pkg/config"
 	"github.com/ArtemZ007/wb-l0/pkg/logger"
 	_ "github.com/lib/pq"
-	"github.com/sirupsen/logrus"
 )
 
 func main() {
 	cfg := loadConfig()
 	log := logger.New(cfg.GetLogLevel())
 
+	// Служебные подкоманды
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			if err := runMigrate(cfg, log, os.Args[2:]); err != nil {
+				log.Fatal("Ошибка выполнения миграций: ", err)
+			}
+			return
+		case "reconcile":
+			if err := runReconcile(cfg, log, os.Args[2:]); err != nil {
+				log.Fatal("Ошибка сверки кэша: ", err)
+			}
+			return
+		case "import":
+			if err := runImport(cfg, log, os.Args[2:]); err != nil {
+				log.Fatal("Ошибка импорта заказов: ", err)
+			}
+			return
+		}
+	}
+
 	if err := runApp(cfg, log); err != nil {
 		log.Fatal("Ошибка запуска приложения: ", err)
 	}
 }
 
 func runApp(cfg config.IConfiguration, log logger.Logger) error {
-	// Подключение к базе данных
-	db, err := sql.Open("postgres", cfg.GetDBConnectionString())
+	// Подключение к хранилищу заказов
+	store, err := openStorage(cfg, log)
 	if err != nil {
-		log.Error("Ошибка подключения к базе данных: ", err)
-		return err
-	}
-	defer closeDB(db, log)
-
-	// Проверка соединения с базой данных
-	if err := db.Ping(); err != nil {
-		log.Error("Не удалось подключиться к базе данных: ", err)
 		return err
 	}
-	log.Info("Успешное подключение к базе данных")
+	defer store.Close()
 	log.Info("Запуск приложения")
 
-	// Инициализация сервиса базы данных
-	dbService, err := database.NewService(db, logrus.New())
+	// Инициализация хранилища, из которого выдаются заказы
+	orderStore, err := initOrderStore(cfg, store, log)
 	if err != nil {
-		log.Error("Ошибка создания сервиса базы данных: ", err)
 		return err
 	}
-	log.Info("Сервис базы данных инициализирован")
 
-	// Инициализация сервиса кэша
-	cacheService, err := initCacheService(cfg, log)
-	if err != nil {
-		log.Error("Ошибка инициализации сервиса кэша: ", err)
-		return err
+	// Периодическая сверка кэша с хранилищем заказов
+	var reconciler *cache.Reconciler
+	reconcileInterval := cfg.GetCacheReconcileInterval()
+	if reconcileInterval > 0 {
+		reconciler, err = newReconciler(orderStore, store, cfg.GetCacheReconcileRepair(), log)
+		if errors.Is(err, cache.ErrReconcileUnsupported) {
+			// Хранилище без кэша нечего сверять: сверка пропускается, а не прерывает запуск
+			log.Warn("Сверка кэша отключена: ", err)
+		} else if err != nil {
+			log.Error("Ошибка инициализации сверки кэша: ", err)
+			return err
+		}
 	}
-	log.Info("Сервис кэша инициализирован")
 
-	// Установка сервиса базы данных в сервис кэша
-	cacheService.SetDBService(dbService)
+	// Контекст фоновых процессов, отменяемый при завершении работы
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-	// Инициализация кэша данными из базы данных
-	ctx := context.Background()
-	if err := cacheService.InitCacheWithDBOrders(ctx); err != nil {
-		log.Error("Ошибка инициализации кэша данными из базы данных: ", err)
+	// Запуск фоновых процессов хранилища: проверки реплик, очистки заказов и обслуживания секций
+	if err := store.orders.Start(ctx); err != nil {
+		log.Error("Ошибка запуска сервиса базы данных: ", err)
 		return err
 	}
 
-	// Обертка для сервиса кэша
-	cacheServiceWrapper := &CacheServiceWrapper{cacheService: cacheService}
+	// Прогрев кэша в фоне; до его окончания заказы загружаются из хранилища по запросу,
+	// а события об изменении заказов задерживаются, чтобы прогрев не перезаписал их устаревшими данными.
+	// Затем запускается фоновая работа кэша: инвалидация локального кэша или сохранение снимков,
+	// а также периодическая сверка кэша с хранилищем.
+	// При завершении работы дожидаемся ее окончания, чтобы снимок был записан полностью
+	warmup := cache.NewWarmupProgress()
+	cacheEvents := newEventGate(orderStore.ApplyOrderEvent, !store.redeliversEvents())
+	cacheDone := make(chan struct{})
+	go func() {
+		defer close(cacheDone)
+		runCache(orderStore, store, warmup, cacheEvents, reconciler, reconcileInterval, ctx, log)
+	}()
 
 	// Инициализация HTTP хендлера
-	handler := httpQS.NewHandler(cacheServiceWrapper, log)
+	handler := httpQS.NewHandler(orderStore, log)
+	handler.SetOrderRepository(store.orders)
+	handler.SetWarmupProgress(warmup)
 	server := initHTTPServer(cfg, handler)
 
 	// Запуск HTTP сервера в отдельной горутине
 	go startHTTPServer(server, log)
 
 	// Инициализация NATS слушателя
-	natsListener, err := subscription.NewListener(cfg.GetNATSURL(), cfg.GetNATSClusterID(), cfg.GetNATSClientID(), cacheService, dbService, log)
+	natsListener, err := subscription.NewListener(cfg.GetNATSURL(), cfg.GetNATSClusterID(), cfg.GetNATSClientID(), orderStore, log)
 	if err != nil {
 		log.Error("Ошибка инициализации NATS слушателя: ", err)
 		return err
 	}
+	natsListener.SetBatchMode(cfg.GetNATSBatchSize(), cfg.GetNATSBatchWait())
+
+	// Доставка событий об изменении заказов в кэш и, при необходимости, в NATS
+	handlers := []database.OutboxHandler{cacheEvents.handle}
+	if subject := cfg.GetOutboxEventsSubject(); subject != "" {
+		handlers = append(handlers, natsListener.EventPublisher(subject))
+	}
+	store.deliverEvents(ctx, cfg, handlers...)
 
 	// Запуск NATS слушателя в отдельной горутине
 	go startNATSListener(natsListener, ctx, log)
 
 	// Ожидание сигнала завершения работы
 	<-waitForShutdownSignal(log)
+	cancel()
+	<-cacheDone
 
 	// Завершение работы HTTP сервера
 	if err := server.Shutdown(context.Background()); err != nil {
@@ -111,17 +148,143 @@ func closeDB(db *sql.DB, log logger.Logger) {
 	}
 }
 
-// initCacheService инициализирует сервис кэша
-func initCacheService(cfg config.IConfiguration, log logger.Logger) (*cache.CacheService, error) {
-	logrusLogger := logrus.New()
-	logWrapper := logger.NewLogrusAdapter(logrusLogger)
-	cacheService := cache.NewCacheService(cfg.GetRedisAddr(), cfg.GetRedisPassword(), cfg.GetRedisDB(), logWrapper)
-	if cacheService == nil {
-		log.Error("Не удалось создать сервис кэша")
-		return nil, errors.New("не удалось создать сервис кэша")
-	}
-	log.Info("Сервис кэша успешно создан")
-	return cacheService, nil
+// openDB открывает пул соединений с базой данных dsn с параметрами пула из конфигурации
+func openDB(cfg config.IConfiguration, dsn string, log logger.Logger) (*sql.DB, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		log.Error("Ошибка подключения к базе данных: ", err)
+		return nil, err
+	}
+	database.PoolConfig{
+		MaxOpenConns:    cfg.GetDBMaxOpenConns(),
+		MaxIdleConns:    cfg.GetDBMaxIdleConns(),
+		ConnMaxLifetime: cfg.GetDBConnMaxLifetime(),
+		ConnMaxIdleTime: cfg.GetDBConnMaxIdleTime(),
+	}.Apply(db)
+	return db, nil
+}
+
+// connectDB ожидает доступности базы данных, повторяя попытки подключения с растущей паузой.
+// Ожидание прерывается сигналом завершения работы.
+func connectDB(cfg config.IConfiguration, db *sql.DB, log logger.Logger) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	policy := database.RetryPolicy{
+		Attempts:   cfg.GetDBConnectAttempts(),
+		Backoff:    cfg.GetDBConnectBackoff(),
+		MaxBackoff: cfg.GetDBConnectMaxBackoff(),
+	}
+	if err := database.Connect(ctx, db, policy, log.Logrus()); err != nil {
+		log.Error("Не удалось подключиться к базе данных: ", err)
+		return err
+	}
+	log.Info("Успешное подключение к базе данных")
+	return nil
+}
+
+// initDBService инициализирует сервис базы данных
+func initDBService(cfg config.IConfiguration, db *sql.DB, log logger.Logger) (*database.Service, error) {
+	dbService, err := database.NewService(db, log.Logrus())
+	if err != nil {
+		log.Error("Ошибка создания сервиса базы данных: ", err)
+		return nil, err
+	}
+
+	conflictPolicy, err := database.ParseConflictPolicy(cfg.GetOrderConflictPolicy())
+	if err != nil {
+		log.Error("Ошибка конфигурации политики конфликтов: ", err)
+		return nil, err
+	}
+	dbService.SetConflictPolicy(conflictPolicy)
+	dbService.SetCircuitBreaker(cfg.GetDBBreakerThreshold(), cfg.GetDBBreakerCooldown())
+	dbService.SetRetention(cfg.GetOrderDeletedRetention(), cfg.GetOrderMaxAge(), cfg.GetOrderRetentionInterval())
+
+	expiredAction, err := database.ParseExpiredPartitionAction(cfg.GetPartitionExpiredAction())
+	if err != nil {
+		log.Error("Ошибка конфигурации секций заказов: ", err)
+		return nil, err
+	}
+	dbService.SetPartitioning(cfg.GetPartitionPremake(), cfg.GetPartitionRetention(), expiredAction, cfg.GetPartitionInterval())
+
+	// Создание и обновление схем арендаторов
+	tenants, err := database.ParseTenantSchemas(cfg.GetTenantSchemas(), cfg.GetTenantDefaultSchema())
+	if err != nil {
+		log.Error("Ошибка конфигурации арендаторов: ", err)
+		return nil, err
+	}
+	dbService.SetTenants(tenants)
+	if err := dbService.ProvisionTenants(context.Background()); err != nil {
+		log.Error("Ошибка создания схем арендаторов: ", err)
+		return nil, err
+	}
+
+	return dbService, nil
+}
+
+// openReplicas открывает подключения к репликам для чтения
+func openReplicas(cfg config.IConfiguration, log logger.Logger) ([]*sql.DB, error) {
+	var replicas []*sql.DB
+	for _, dsn := range cfg.GetDBReplicaConnectionStrings() {
+		replica, err := openDB(cfg, dsn, log)
+		if err != nil {
+			for _, opened := range replicas {
+				closeDB(opened, log)
+			}
+			return nil, err
+		}
+		replicas = append(replicas, replica)
+	}
+	return replicas, nil
+}
+
+// initOrderStore создает хранилище заказов, выбранное CACHE_BACKEND, с источником заказов store
+func initOrderStore(cfg config.IConfiguration, store *storage, log logger.Logger) (cache.OrderStore, error) {
+	expiryMode, err := cache.ParseExpiryMode(cfg.GetCacheTTLPolicy())
+	if err != nil {
+		log.Error("Ошибка конфигурации времени жизни заказов в кэше: ", err)
+		return nil, err
+	}
+	codec, err := cache.ParseCodec(cfg.GetCacheCodec())
+	if err != nil {
+		log.Error("Ошибка конфигурации формата заказов в кэше: ", err)
+		return nil, err
+	}
+	compress, err := cache.ParseCompression(cfg.GetCacheCompression())
+	if err != nil {
+		log.Error("Ошибка конфигурации формата заказов в кэше: ", err)
+		return nil, err
+	}
+
+	orderStore, err := cache.NewStore(cfg.GetCacheBackend(), cache.StoreConfig{
+		DBService: store.orders,
+		Writer:    store.orders,
+		Logger:    log,
+		Namespace: store.namespace,
+		Expiry: cache.ExpiryPolicy{
+			Mode:       expiryMode,
+			TTL:        cfg.GetCacheTTL(),
+			MaxAge:     cfg.GetCacheTTLMaxAge(),
+			MaxEntries: cfg.GetCacheMaxEntries(),
+		},
+		NegativeTTL:      cfg.GetCacheNegativeTTL(),
+		RedisAddr:        cfg.GetRedisAddr(),
+		RedisPassword:    cfg.GetRedisPassword(),
+		RedisDB:          cfg.GetRedisDB(),
+		LocalMaxEntries:  cfg.GetCacheLocalMaxEntries(),
+		LocalMaxBytes:    cfg.GetCacheLocalMaxBytes(),
+		Codec:            codec,
+		Compress:         compress,
+		SnapshotPath:     cfg.GetCacheSnapshotFile(),
+		SnapshotInterval: cfg.GetCacheSnapshotInterval(),
+		SnapshotMaxAge:   cfg.GetCacheSnapshotMaxAge(),
+	})
+	if err != nil {
+		log.Error("Не удалось создать хранилище заказов: ", err)
+		return nil, err
+	}
+	log.Info("Хранилище заказов ", cfg.GetCacheBackend(), " успешно создано")
+	return orderStore, nil
 }
 
 // initHTTPServer инициализирует HTTP сервер
@@ -147,19 +310,18 @@ func startNATSListener(listener *subscription.Listener, ctx context.Context, log
 	}
 }
 
-// CacheServiceWrapper оборачивает cache.CacheService для реализации интерфейса httpQS.DataService
-type CacheServiceWrapper struct {
-	cacheService *cache.CacheService
-}
-
-// GetData метод для CacheServiceWrapper
-func (w *CacheServiceWrapper) GetData() ([]model.Order, bool) {
-	return w.cacheService.GetData()
+// startOutboxRelay запускает доставку событий outbox
+func startOutboxRelay(relay *database.OutboxRelay, ctx context.Context, log logger.Logger) {
+	if err := relay.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+		log.Error("Ошибка доставки событий outbox: ", err)
+	}
 }
 
-// GetOrder метод для CacheServiceWrapper
-func (w *CacheServiceWrapper) GetOrder(orderUID string) (*model.Order, error) {
-	return w.cacheService.GetOrder(context.Background(), orderUID)
+// startCache запускает фоновую работу хранилища заказов
+func startCache(orderStore cache.OrderStore, ctx context.Context, log logger.Logger) {
+	if err := orderStore.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+		log.Error("Ошибка фоновой работы кэша: ", err)
+	}
 }
 
 // waitForShutdownSignal ожидает сигнала завершения работы
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/ArtemZ007/wb-l0/internal/repository/database"
+	"github.com/ArtemZ007/wb-l0/migrations"
+	"github.com/ArtemZ007/wb-l0/pkg/config"
+	"github.com/ArtemZ007/wb-l0/pkg/logger"
+)
+
+// migrateUsage описывает формат подкоманды migrate.
+const migrateUsage = "использование: migrate [-schema имя] status | up | down [N] | to N"
+
+// runMigrate выполняет подкоманду migrate.
+func runMigrate(cfg config.IConfiguration, log logger.Logger, args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	schema := flags.String("schema", database.DefaultSchema, "схема базы данных, например схема арендатора")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	args = flags.Args()
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	db, err := openDB(cfg, cfg.GetDBConnectionString(), log)
+	if err != nil {
+		return err
+	}
+	defer closeDB(db, log)
+	if err := connectDB(cfg, db, log); err != nil {
+		return err
+	}
+
+	migrator, err := database.NewMigrator(db, migrations.FS, *schema, log.Logrus())
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	switch args[0] {
+	case "status":
+		return printMigrationStatus(ctx, migrator)
+	case "up":
+		return migrator.Up(ctx)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil {
+				return fmt.Errorf("некорректное число шагов %q: %w", args[1], err)
+			}
+		}
+		return migrator.Down(ctx, steps)
+	case "to":
+		if len(args) < 2 {
+			return errors.New(migrateUsage)
+		}
+		version, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("некорректный номер версии %q: %w", args[1], err)
+		}
+		return migrator.To(ctx, version)
+	default:
+		return errors.New(migrateUsage)
+	}
+}
+
+// printMigrationStatus выводит таблицу состояния миграций.
+func printMigrationStatus(ctx context.Context, migrator *database.Migrator) error {
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+	for _, status := range statuses {
+		state, appliedAt := "pending", ""
+		if status.Applied {
+			state, appliedAt = "applied", status.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		if status.Modified {
+			state = "modified"
+		}
+		fmt.Fprintf(w, "%04d\t%s\t%s\t%s\n", status.Version, status.Name, state, appliedAt)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+	"time"
+
+	"github.com/ArtemZ007/wb-l0/internal/repository/cache"
+	"github.com/ArtemZ007/wb-l0/pkg/config"
+	"github.com/ArtemZ007/wb-l0/pkg/logger"
+)
+
+// reconcileUsage описывает формат подкоманды reconcile.
+const reconcileUsage = "использование: reconcile [-repair]"
+
+// runReconcile однократно сверяет кэш с хранилищем заказов и выводит отчет в формате JSON.
+// С флагом -repair найденные расхождения исправляются.
+func runReconcile(cfg config.IConfiguration, log logger.Logger, args []string) error {
+	flags := flag.NewFlagSet("reconcile", flag.ContinueOnError)
+	repair := flags.Bool("repair", false, "исправить найденные расхождения")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 0 {
+		return errors.New(reconcileUsage)
+	}
+	// Кэш в памяти процесса принадлежит работающему серверу и извне недоступен
+	if cfg.GetCacheBackend() == cache.StoreMemory {
+		return errors.New("кэш в памяти процесса сверяется только периодически, см. CACHE_RECONCILE_INTERVAL")
+	}
+
+	store, err := openStorage(cfg, log)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	orderStore, err := initOrderStore(cfg, store, log)
+	if err != nil {
+		return err
+	}
+
+	reconciler, err := newReconciler(orderStore, store, *repair, log)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	report, err := reconciler.Reconcile(ctx, store.contexts(ctx))
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+// newReconciler создает сверку кэша с хранилищем заказов
+func newReconciler(orderStore cache.OrderStore, store *storage, repair bool, log logger.Logger) (*cache.Reconciler, error) {
+	reconciler, err := cache.NewReconciler(orderStore, store.orders, log)
+	if err != nil {
+		return nil, err
+	}
+	reconciler.SetRepair(repair)
+	return reconciler, nil
+}
+
+// startReconciler запускает периодическую сверку кэша с хранилищем заказов
+func startReconciler(reconciler *cache.Reconciler, store *storage, interval time.Duration, ctx context.Context, log logger.Logger) {
+	if err := reconciler.Run(ctx, interval, store.contexts(ctx)); err != nil && !errors.Is(err, context.Canceled) {
+		log.Error("Ошибка периодической сверки кэша: ", err)
+	}
+}
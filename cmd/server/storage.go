@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/ArtemZ007/wb-l0/internal/domain/model"
+	"github.com/ArtemZ007/wb-l0/internal/repository/database"
+	"github.com/ArtemZ007/wb-l0/internal/repository/filestore"
+	"github.com/ArtemZ007/wb-l0/pkg/config"
+	"github.com/ArtemZ007/wb-l0/pkg/logger"
+)
+
+// Поддерживаемые хранилища заказов.
+const (
+	storagePostgres = "postgres" // PostgreSQL с репликами, арендаторами и outbox
+	storageFile     = "file"     // Встроенное хранилище в файле JSON для локального запуска
+)
+
+// storage описывает хранилище заказов, выбранное конфигурацией.
+type storage struct {
+	orders  database.IOrderService
+	db      *sql.DB           // Основная база данных; nil для встроенного хранилища
+	pg      *database.Service // Сервис PostgreSQL; nil для встроенного хранилища
+	file    *filestore.Store  // Встроенное хранилище; nil для PostgreSQL
+	closers []func()          // Функции освобождения ресурсов
+	log     logger.Logger
+}
+
+// openStorage открывает хранилище заказов, выбранное STORAGE_BACKEND.
+func openStorage(cfg config.IConfiguration, log logger.Logger) (*storage, error) {
+	switch cfg.GetStorageBackend() {
+	case storagePostgres, "":
+		return openPostgresStorage(cfg, log)
+	case storageFile:
+		return openFileStorage(cfg, log)
+	default:
+		return nil, fmt.Errorf("неизвестное хранилище заказов %q", cfg.GetStorageBackend())
+	}
+}
+
+// openPostgresStorage подключается к основной базе данных и репликам для чтения.
+func openPostgresStorage(cfg config.IConfiguration, log logger.Logger) (*storage, error) {
+	db, err := openDB(cfg, cfg.GetDBConnectionString(), log)
+	if err != nil {
+		return nil, err
+	}
+	s := &storage{db: db, log: log}
+	s.closers = append(s.closers, func() { closeDB(db, log) })
+
+	// Ожидание доступности базы данных, например пока она запускается
+	if err := connectDB(cfg, db, log); err != nil {
+		s.Close()
+		return nil, err
+	}
+
+	// Инициализация сервиса базы данных
+	dbService, err := initDBService(cfg, db, log)
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+	s.pg, s.orders = dbService, dbService
+
+	// Подключение реплик для чтения
+	replicas, err := openReplicas(cfg, log)
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+	s.closers = append(s.closers, func() {
+		for _, replica := range replicas {
+			closeDB(replica, log)
+		}
+	})
+	dbService.SetReplicas(replicas, cfg.GetDBReplicaMaxLag(), cfg.GetDBReplicaCheckInterval())
+	log.Info("Сервис базы данных инициализирован")
+	return s, nil
+}
+
+// openFileStorage открывает встроенное хранилище заказов в файле STORAGE_FILE.
+func openFileStorage(cfg config.IConfiguration, log logger.Logger) (*storage, error) {
+	store, err := filestore.NewStore(cfg.GetStorageFile(), log.Logrus())
+	if err != nil {
+		log.Error("Ошибка открытия файла хранилища: ", err)
+		return nil, err
+	}
+
+	conflictPolicy, err := database.ParseConflictPolicy(cfg.GetOrderConflictPolicy())
+	if err != nil {
+		log.Error("Ошибка конфигурации политики конфликтов: ", err)
+		return nil, err
+	}
+	store.SetConflictPolicy(conflictPolicy)
+
+	log.Info("Встроенное хранилище заказов открыто: ", cfg.GetStorageFile())
+	return &storage{orders: store, file: store, log: log}, nil
+}
+
+// contexts возвращает контексты для обхода заказов всех арендаторов, например при прогреве кэша.
+func (s *storage) contexts(ctx context.Context) []context.Context {
+	if s.pg == nil {
+		return []context.Context{ctx}
+	}
+	var contexts []context.Context
+	for _, schema := range s.pg.Tenants().All() {
+		contexts = append(contexts, database.WithSchema(ctx, schema))
+	}
+	return contexts
+}
+
+// namespace возвращает пространство имен кэша для контекста ctx — схему арендатора,
+// чтобы заказы разных схем не смешивались в кэше. Встроенное хранилище не разделяет арендаторов.
+func (s *storage) namespace(ctx context.Context) (string, error) {
+	if s.pg == nil {
+		return "", nil
+	}
+	return s.pg.Schema(ctx)
+}
+
+// redeliversEvents сообщает, доставляются ли отклоненные обработчиком события повторно.
+// Повторно доставляет события только outbox PostgreSQL.
+func (s *storage) redeliversEvents() bool {
+	return s.file == nil
+}
+
+// deliverEvents направляет события об изменении заказов обработчикам.
+// Для PostgreSQL события доставляются из outbox, встроенное хранилище передает их сразу после записи.
+func (s *storage) deliverEvents(ctx context.Context, cfg config.IConfiguration, handlers ...database.OutboxHandler) {
+	if s.file != nil {
+		s.file.SetEventHandler(func(ctx context.Context, event model.OrderEvent) error {
+			var errs []error
+			for _, handler := range handlers {
+				if err := handler(ctx, event); err != nil {
+					errs = append(errs, err)
+				}
+			}
+			return errors.Join(errs...)
+		})
+		return
+	}
+
+	relay := database.NewOutboxRelay(s.db, s.log.Logrus(), cfg.GetOutboxPollInterval(), cfg.GetOutboxBatchSize())
+	relay.SetTenants(s.pg.Tenants())
+	relay.SetMaxAttempts(cfg.GetOutboxMaxAttempts())
+	for _, handler := range handlers {
+		relay.AddHandler(handler)
+	}
+	go startOutboxRelay(relay, ctx, s.log)
+}
+
+// Close освобождает ресурсы хранилища в обратном порядке.
+func (s *storage) Close() {
+	for i := len(s.closers) - 1; i >= 0; i-- {
+		s.closers[i]()
+	}
+}
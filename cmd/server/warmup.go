@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ArtemZ007/wb-l0/internal/domain/model"
+	"github.com/ArtemZ007/wb-l0/internal/repository/cache"
+	"github.com/ArtemZ007/wb-l0/internal/repository/database"
+	"github.com/ArtemZ007/wb-l0/pkg/logger"
+)
+
+const warmupRetryDelay = 5 * time.Second // Пауза перед повторным прогревом кэша после ошибки
+
+// runCache прогревает кэш, повторяя попытки до успеха, открывает шлюз событий об изменении заказов
+// и выполняет фоновую работу кэша и его периодическую сверку с хранилищем, если reconciler задан,
+// до завершения контекста
+func runCache(orderStore cache.OrderStore, store *storage, warmup *cache.WarmupProgress, events *eventGate,
+	reconciler *cache.Reconciler, reconcileInterval time.Duration, ctx context.Context, log logger.Logger) {
+	for {
+		err := warmCache(orderStore, store, warmup, ctx, log)
+		if err == nil {
+			break
+		}
+		warmup.Finish(err)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Error("Ошибка прогрева кэша, повтор через ", warmupRetryDelay, ": ", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(warmupRetryDelay):
+		}
+	}
+
+	events.release()
+	warmup.Finish(nil)
+	log.Info("Прогрев кэша завершен, сервис готов")
+
+	var wg sync.WaitGroup
+	if reconciler != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			startReconciler(reconciler, store, reconcileInterval, ctx, log)
+		}()
+	}
+	startCache(orderStore, ctx, log)
+	wg.Wait()
+}
+
+// warmCache заполняет кэш: кэш в памяти процесса сначала восстанавливается из снимка,
+// а если снимка нет или он устарел, кэш загружается из хранилища всех арендаторов
+func warmCache(orderStore cache.OrderStore, store *storage, warmup *cache.WarmupProgress, ctx context.Context, log logger.Logger) error {
+	warmup.Start()
+	if memoryCache, ok := orderStore.(*cache.MemoryCache); ok {
+		restored, err := memoryCache.RestoreSnapshot()
+		if err != nil {
+			log.Error("Ошибка восстановления кэша из снимка: ", err)
+		}
+		if restored {
+			return nil
+		}
+	}
+
+	for _, tenantCtx := range store.contexts(ctx) {
+		if err := orderStore.InitCacheWithDBOrders(tenantCtx, warmup); err != nil {
+			log.Error("Ошибка инициализации кэша данными из базы данных: ", err)
+			return err
+		}
+	}
+	return nil
+}
+
+// errWarmupPending возвращается для событий, поступивших до окончания прогрева кэша
+var errWarmupPending = errors.New("прогрев кэша не завершен, событие будет доставлено повторно")
+
+// eventGate задерживает события об изменении заказов до окончания прогрева кэша: прогрев мог
+// прочитать заказ до события и перезаписать его устаревшими данными. События не накапливаются в памяти,
+// где они потерялись бы при аварийном завершении: outbox доставит отклоненное событие повторно,
+// а события встроенного хранилища, которое их не повторяет, ожидают окончания прогрева.
+type eventGate struct {
+	opened chan struct{}
+	once   sync.Once
+	wait   bool // Ожидать окончания прогрева вместо отказа
+	apply  database.OutboxHandler
+}
+
+// newEventGate создает закрытый шлюз событий, передающий события в apply после release.
+// При wait события до окончания прогрева ожидают его, иначе отклоняются с errWarmupPending
+func newEventGate(apply database.OutboxHandler, wait bool) *eventGate {
+	return &eventGate{opened: make(chan struct{}), wait: wait, apply: apply}
+}
+
+// handle применяет событие, если прогрев завершен, а иначе ожидает его окончания или отклоняет событие
+func (g *eventGate) handle(ctx context.Context, event model.OrderEvent) error {
+	select {
+	case <-g.opened:
+		return g.apply(ctx, event)
+	default:
+	}
+	if !g.wait {
+		return errWarmupPending
+	}
+	select {
+	case <-g.opened:
+		return g.apply(ctx, event)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// release пропускает последующие события без задержки
+func (g *eventGate) release() {
+	g.once.Do(func() { close(g.opened) })
+}
@@ -1,10 +1,20 @@
 package httpQS
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/ArtemZ007/wb-l0/internal/domain/model"
+	"github.com/ArtemZ007/wb-l0/internal/repository/cache"
+	"github.com/ArtemZ007/wb-l0/internal/repository/database"
 	"github.com/ArtemZ007/wb-l0/pkg/logger"
 )
 
@@ -13,19 +23,70 @@ const (
 	contentTypeJSON   = "application/json"
 	contentTypeHTML   = "text/html"
 	serverErrorMsg    = "Внутренняя ошибка сервера"
+	tenantHeader      = "X-Tenant"
+	indexPageSize     = 100  // Число заказов на HTML-странице по умолчанию
+	maxIndexPageSize  = 1000 // Максимальное число заказов на HTML-странице
 )
 
 // Handler представляет HTTP обработчик
 type Handler struct {
-	dataService DataService   // Сервис для работы с данными
-	logger      logger.Logger // Логгер для регистрации событий
+	store  cache.OrderStore      // Хранилище заказов для их выдачи
+	orders OrderRepository       // Хранилище заказов для API истории
+	warmup *cache.WarmupProgress // Ход прогрева кэша для проверки готовности
+	api    *http.ServeMux        // Маршрутизатор JSON API
+	logger logger.Logger         // Логгер для регистрации событий
+}
+
+// OrderRepository определяет методы хранилища заказов, необходимые JSON API.
+type OrderRepository interface {
+	SearchOrders(ctx context.Context, query model.SearchQuery) ([]model.Order, error)
+	OrderHistory(ctx context.Context, orderUID string) ([]model.OrderRevision, error)
+	GetOrderAsOf(ctx context.Context, orderUID string, at time.Time) (*model.Order, error)
+	UpdateOrder(ctx context.Context, order *model.Order) error
 }
 
 // NewHandler создает новый экземпляр HTTP обработчика
-func NewHandler(dataService DataService, logger logger.Logger) *Handler {
-	return &Handler{
-		dataService: dataService,
-		logger:      logger,
+func NewHandler(store cache.OrderStore, logger logger.Logger) *Handler {
+	h := &Handler{
+		store:  store,
+		api:    http.NewServeMux(),
+		logger: logger,
+	}
+	h.api.HandleFunc("GET /api/v1/orders/search", h.handleSearch)
+	h.api.HandleFunc("GET /api/v1/orders/{uid}", h.handleAPIOrder)
+	h.api.HandleFunc("PUT /api/v1/orders/{uid}", h.handleUpdateOrder)
+	h.api.HandleFunc("GET /api/v1/orders/{uid}/history", h.handleOrderHistory)
+	return h
+}
+
+// SetOrderRepository устанавливает хранилище заказов для JSON API.
+func (h *Handler) SetOrderRepository(orders OrderRepository) {
+	h.orders = orders
+}
+
+// SetWarmupProgress устанавливает ход прогрева кэша, от которого зависит готовность сервиса.
+func (h *Handler) SetWarmupProgress(progress *cache.WarmupProgress) {
+	h.warmup = progress
+}
+
+// handleReady сообщает готовность сервиса: до окончания прогрева кэша отвечает 503.
+// Заказы при этом уже выдаются, загружаясь из хранилища по запросу.
+func (h *Handler) handleReady(w http.ResponseWriter, r *http.Request) {
+	response := map[string]interface{}{"status": "ready"}
+	status := http.StatusOK
+	if h.warmup != nil {
+		warmup := h.warmup.Status()
+		response["warmup"] = warmup
+		if !warmup.Done {
+			response["status"] = "warming_up"
+			status = http.StatusServiceUnavailable
+		}
+	}
+
+	w.Header().Set(contentTypeHeader, contentTypeJSON)
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		h.logger.Error("Ошибка при кодировании ответа: ", err)
 	}
 }
 
@@ -42,14 +103,13 @@ func (h *Handler) handleOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	order, err := h.dataService.GetOrder(orderUID)
+	order, err := h.store.GetOrder(r.Context(), orderUID)
 	if err != nil {
-		h.logger.Error("Ошибка при получении заказа: ", err)
-		h.writeJSONError(w, serverErrorMsg, http.StatusInternalServerError)
+		h.writeRepositoryError(w, "Ошибка при получении заказа: ", err)
 		return
 	}
 
-	if order == nil {
+	if order == nil || !tenantOwns(r, order) {
 		h.writeJSONError(w, "Заказ не найден", http.StatusNotFound)
 		return
 	}
@@ -62,66 +122,279 @@ func (h *Handler) handleOrder(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// writeJSONError записывает ошибку в формате JSON в ответ
-func (h *Handler) writeJSONError(w http.ResponseWriter, message string, statusCode int) {
-	w.Header().Set(contentTypeHeader, contentTypeJSON)
-	w.WriteHeader(statusCode)
-	if err := json.NewEncoder(w).Encode(map[string]string{"error": message}); err != nil {
-		h.logger.Error("Ошибка при кодировании ошибки: ", err)
+// handleAPIOrder возвращает заказ в формате JSON.
+// Параметр as_of (RFC 3339) позволяет получить состояние заказа на указанный момент.
+func (h *Handler) handleAPIOrder(w http.ResponseWriter, r *http.Request) {
+	orderUID := r.PathValue("uid")
+
+	var order *model.Order
+	var err error
+	if asOf := r.URL.Query().Get("as_of"); asOf != "" {
+		if h.orders == nil {
+			h.writeJSONError(w, "История заказов недоступна", http.StatusServiceUnavailable)
+			return
+		}
+		at, parseErr := time.Parse(time.RFC3339, asOf)
+		if parseErr != nil {
+			h.writeJSONError(w, "Параметр as_of должен быть в формате RFC 3339", http.StatusBadRequest)
+			return
+		}
+		order, err = h.orders.GetOrderAsOf(r.Context(), orderUID, at)
+	} else {
+		order, err = h.store.GetOrder(r.Context(), orderUID)
+	}
+	if err != nil {
+		h.writeRepositoryError(w, "Ошибка при получении заказа: ", err)
+		return
 	}
+	if order == nil || !tenantOwns(r, order) {
+		h.writeJSONError(w, "Заказ не найден", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("ETag", versionETag(order.Version))
+	h.writeJSON(w, order, http.StatusOK)
 }
 
-// DataService интерфейс, определяющий методы для работы с данными.
-type DataService interface {
-	GetData() ([]model.Order, bool)
-	GetOrder(orderUID string) (*model.Order, error)
+// handleUpdateOrder заменяет заказ документом из тела запроса.
+// Заголовок If-Match с ETag, полученным при чтении заказа, обязателен:
+// если заказ с тех пор изменился, возвращается 412 и текущий ETag.
+// If-Match: * заменяет заказ независимо от его текущей версии.
+func (h *Handler) handleUpdateOrder(w http.ResponseWriter, r *http.Request) {
+	if h.orders == nil {
+		h.writeJSONError(w, "Изменение заказов недоступно", http.StatusServiceUnavailable)
+		return
+	}
+
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" {
+		h.writeJSONError(w, "Требуется заголовок If-Match", http.StatusPreconditionRequired)
+		return
+	}
+	version := 0 // Нулевая версия не проверяется при обновлении
+	if strings.TrimSpace(ifMatch) != "*" {
+		var ok bool
+		if version, ok = parseVersionETag(ifMatch); !ok {
+			h.writeJSONError(w, "Некорректный заголовок If-Match", http.StatusBadRequest)
+			return
+		}
+	}
+
+	var order model.Order
+	if err := json.NewDecoder(r.Body).Decode(&order); err != nil {
+		h.writeJSONError(w, "Некорректный документ заказа", http.StatusBadRequest)
+		return
+	}
+	uid := r.PathValue("uid")
+	if order.OrderUID == "" {
+		order.OrderUID = uid
+	}
+	if order.OrderUID != uid || order.DateCreated == "" {
+		h.writeJSONError(w, "Документ заказа не соответствует запросу", http.StatusBadRequest)
+		return
+	}
+	if tenant := database.TenantFromContext(r.Context()); tenant != "" && (order.Entry == nil || *order.Entry != tenant) {
+		h.writeJSONError(w, "Заказ не принадлежит арендатору", http.StatusBadRequest)
+		return
+	}
+	if err := order.Validate(); err != nil {
+		h.writeJSONError(w, "Некорректный документ заказа: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	order.Version = version
+
+	ctx := database.WithRevisionSource(r.Context(), model.RevisionSource{Kind: model.SourceHTTP, Ref: r.RemoteAddr})
+	err := h.orders.UpdateOrder(ctx, &order)
+	var conflict *database.VersionConflictError
+	switch {
+	case errors.As(err, &conflict):
+		w.Header().Set("ETag", versionETag(conflict.Actual))
+		h.writeJSONError(w, "Заказ был изменен другим запросом", http.StatusPreconditionFailed)
+		return
+	case errors.Is(err, database.ErrOrderNotFound):
+		h.writeJSONError(w, "Заказ не найден", http.StatusNotFound)
+		return
+	case err != nil:
+		h.writeRepositoryError(w, "Ошибка при обновлении заказа: ", err)
+		return
+	}
+
+	w.Header().Set("ETag", versionETag(order.Version))
+	h.writeJSON(w, order, http.StatusOK)
 }
 
-// Service структура, реализующая интерфейс DataService.
-type Service struct {
-	cache  map[string]*model.Order // Кэш для хранения заказов
-	logger logger.Logger           // Логгер для регистрации событий
+// versionETag формирует ETag из версии заказа.
+func versionETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
 }
 
-// NewService функция для создания нового экземпляра Service.
-func NewService(logger logger.Logger) *Service {
-	return &Service{
-		cache:  make(map[string]*model.Order),
-		logger: logger,
+// parseVersionETag извлекает версию заказа из ETag. Слабые ETag не подходят для If-Match.
+func parseVersionETag(etag string) (int, bool) {
+	etag = strings.TrimSpace(etag)
+	if len(etag) < 3 || etag[0] != '"' || etag[len(etag)-1] != '"' {
+		return 0, false
+	}
+	version, err := strconv.Atoi(etag[1 : len(etag)-1])
+	if err != nil || version <= 0 {
+		return 0, false
 	}
+	return version, true
 }
 
-// GetData метод для получения всех заказов.
-func (s *Service) GetData() ([]model.Order, bool) {
-	var orders []model.Order
-	for _, order := range s.cache {
-		orders = append(orders, *order)
+// handleOrderHistory возвращает историю изменений заказа.
+func (h *Handler) handleOrderHistory(w http.ResponseWriter, r *http.Request) {
+	if h.orders == nil {
+		h.writeJSONError(w, "История заказов недоступна", http.StatusServiceUnavailable)
+		return
+	}
+
+	revisions, err := h.orders.OrderHistory(r.Context(), r.PathValue("uid"))
+	if err != nil {
+		h.writeRepositoryError(w, "Ошибка при получении истории заказа: ", err)
+		return
 	}
-	if len(orders) == 0 {
-		return nil, false
+	if len(revisions) == 0 {
+		h.writeJSONError(w, "История заказа не найдена", http.StatusNotFound)
+		return
 	}
-	return orders, true
+
+	h.writeJSON(w, revisions, http.StatusOK)
+}
+
+// handleSearch ищет заказы по параметрам запроса:
+// track_number, customer_id, phone, email, transaction, brand, nm_id, q и limit.
+func (h *Handler) handleSearch(w http.ResponseWriter, r *http.Request) {
+	if h.orders == nil {
+		h.writeJSONError(w, "Поиск заказов недоступен", http.StatusServiceUnavailable)
+		return
+	}
+
+	params := r.URL.Query()
+	query := model.SearchQuery{
+		TrackNumber: params.Get("track_number"),
+		CustomerID:  params.Get("customer_id"),
+		Phone:       params.Get("phone"),
+		Email:       params.Get("email"),
+		Transaction: params.Get("transaction"),
+		Brand:       params.Get("brand"),
+		Text:        params.Get("q"),
+	}
+	var err error
+	if query.NmID, err = parseIntParam(params.Get("nm_id")); err != nil {
+		h.writeJSONError(w, "Параметр nm_id должен быть целым числом", http.StatusBadRequest)
+		return
+	}
+	if query.Limit, err = parseIntParam(params.Get("limit")); err != nil {
+		h.writeJSONError(w, "Параметр limit должен быть целым числом", http.StatusBadRequest)
+		return
+	}
+	if query.IsEmpty() {
+		h.writeJSONError(w, "Не задан ни один критерий поиска", http.StatusBadRequest)
+		return
+	}
+
+	orders, err := h.orders.SearchOrders(r.Context(), query)
+	if err != nil {
+		h.writeRepositoryError(w, "Ошибка при поиске заказов: ", err)
+		return
+	}
+
+	h.writeJSON(w, orders, http.StatusOK)
+}
+
+// parseIntParam разбирает необязательный целочисленный параметр запроса.
+func parseIntParam(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(value)
 }
 
-// GetOrder метод для получения заказа по его уникальному идентификатору.
-func (s *Service) GetOrder(orderUID string) (*model.Order, error) {
-	order, exists := s.cache[orderUID]
-	if !exists {
-		return nil, nil
+// writeJSON записывает значение в формате JSON в ответ
+func (h *Handler) writeJSON(w http.ResponseWriter, value interface{}, statusCode int) {
+	w.Header().Set(contentTypeHeader, contentTypeJSON)
+	w.WriteHeader(statusCode)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		h.logger.Error("Ошибка при кодировании ответа: ", err)
+	}
+}
+
+// writeRepositoryError записывает ответ для ошибки хранилища заказов
+func (h *Handler) writeRepositoryError(w http.ResponseWriter, message string, err error) {
+	if errors.Is(err, cache.ErrOrderNotFound) {
+		h.writeJSONError(w, "Заказ не найден", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, database.ErrUnknownTenant) {
+		h.writeJSONError(w, "Неизвестный арендатор", http.StatusBadRequest)
+		return
+	}
+	var circuitErr *database.CircuitOpenError
+	if errors.As(err, &circuitErr) {
+		// Округление вверх, чтобы клиент не повторил запрос раньше окончания паузы
+		retryAfter := int((circuitErr.RetryAfter + time.Second - 1) / time.Second)
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		h.writeJSONError(w, "База данных временно недоступна", http.StatusServiceUnavailable)
+		return
+	}
+	h.logger.Error(message, err)
+	h.writeJSONError(w, serverErrorMsg, http.StatusInternalServerError)
+}
+
+// writeJSONError записывает ошибку в формате JSON в ответ
+func (h *Handler) writeJSONError(w http.ResponseWriter, message string, statusCode int) {
+	w.Header().Set(contentTypeHeader, contentTypeJSON)
+	w.WriteHeader(statusCode)
+	if err := json.NewEncoder(w).Encode(map[string]string{"error": message}); err != nil {
+		h.logger.Error("Ошибка при кодировании ошибки: ", err)
 	}
-	return order, nil
 }
 
 // ServeHTTP метод для обработки HTTP-запросов.
+// Все маршруты выполняются в схеме арендатора из заголовка X-Tenant или параметра tenant.
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	switch r.URL.Path {
-	case "/":
+	r = withTenant(r)
+	switch {
+	case r.URL.Path == "/":
 		h.handleIndex(w, r)
+	case r.URL.Path == "/readyz":
+		h.handleReady(w, r)
+	case strings.HasPrefix(r.URL.Path, "/api/v1/"):
+		h.api.ServeHTTP(w, r)
 	default:
 		h.handleOrder(w, r)
 	}
 }
 
+// tenantOwns сообщает, принадлежит ли заказ арендатору запроса. Арендаторы без собственной схемы
+// делят схему по умолчанию и ее пространство имен в кэше, поэтому чужие заказы скрываются.
+func tenantOwns(r *http.Request, order *model.Order) bool {
+	tenant := database.TenantFromContext(r.Context())
+	return tenant == "" || (order.Entry != nil && *order.Entry == tenant)
+}
+
+// withTenant добавляет в контекст запроса арендатора из заголовка X-Tenant или параметра tenant.
+func withTenant(r *http.Request) *http.Request {
+	tenant := r.Header.Get(tenantHeader)
+	if tenant == "" {
+		tenant = r.URL.Query().Get("tenant")
+	}
+	if tenant == "" {
+		return r
+	}
+	return r.WithContext(database.WithTenant(r.Context(), tenant))
+}
+
+// tenantParam возвращает параметр tenant для ссылок страницы, чтобы переход по ним
+// выполнялся в схеме арендатора запроса.
+func tenantParam(r *http.Request) string {
+	tenant := database.TenantFromContext(r.Context())
+	if tenant == "" {
+		return ""
+	}
+	return "&tenant=" + url.QueryEscape(tenant)
+}
+
 // handleIndex метод для обработки запросов к корневому маршруту.
 func (h *Handler) handleIndex(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -129,12 +402,33 @@ func (h *Handler) handleIndex(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	data, ok := h.dataService.GetData()
-	if !ok {
-		h.logger.Error("Ошибка при получении данных")
-		h.writeJSONError(w, serverErrorMsg, http.StatusInternalServerError)
+	offset, err := parseIntParam(r.URL.Query().Get("offset"))
+	if err != nil || offset < 0 {
+		h.writeJSONError(w, "Некорректный параметр offset", http.StatusBadRequest)
 		return
 	}
+	limit, err := parseIntParam(r.URL.Query().Get("limit"))
+	if err != nil || limit < 0 || limit > maxIndexPageSize {
+		h.writeJSONError(w, "Некорректный параметр limit", http.StatusBadRequest)
+		return
+	}
+	if limit == 0 {
+		limit = indexPageSize
+	}
+
+	// Недоступные заказы страницы записываются в журнал, остальные выводятся
+	data, err := h.store.GetOrdersPage(r.Context(), offset, limit)
+	var partial *cache.PartialError
+	if errors.As(err, &partial) {
+		h.logger.Warn("Часть заказов страницы не удалось получить: ", err)
+	} else if err != nil {
+		h.writeRepositoryError(w, "Ошибка при получении заказов: ", err)
+		return
+	}
+	unavailable := 0
+	if partial != nil {
+		unavailable = len(partial.Failed)
+	}
 
 	// Генерация HTML-страницы
 	w.Header().Set(contentTypeHeader, contentTypeHTML)
@@ -143,21 +437,37 @@ func (h *Handler) handleIndex(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("<h1>Заказы</h1>"))
 	w.Write([]byte("<table border='1'><tr><th>OrderUID</th><th>TrackNumber</th><th>Entry</th></tr>"))
 
+	tenant := tenantParam(r)
 	for _, order := range data {
+		if !tenantOwns(r, &order) {
+			continue
+		}
 		w.Write([]byte("<tr>"))
-		w.Write([]byte("<td><a href='/order?uid=" + order.OrderUID + "'>" + order.OrderUID + "</a></td>"))
+		w.Write([]byte("<td><a href='/order?uid=" + html.EscapeString(order.OrderUID) + tenant + "'>" + html.EscapeString(order.OrderUID) + "</a></td>"))
 		if order.TrackNumber != nil && *order.TrackNumber != "" {
-			w.Write([]byte("<td>" + *order.TrackNumber + "</td>"))
+			w.Write([]byte("<td>" + html.EscapeString(*order.TrackNumber) + "</td>"))
 		} else {
 			w.Write([]byte("<td></td>"))
 		}
 		if order.Entry != nil && *order.Entry != "" {
-			w.Write([]byte("<td>" + *order.Entry + "</td>"))
+			w.Write([]byte("<td>" + html.EscapeString(*order.Entry) + "</td>"))
 		} else {
 			w.Write([]byte("<td></td>"))
 		}
 		w.Write([]byte("</tr>"))
 	}
 
-	w.Write([]byte("</table></body></html>"))
+	w.Write([]byte("</table>"))
+	if unavailable > 0 {
+		w.Write([]byte(fmt.Sprintf("<p>Временно недоступно заказов: %d</p>", unavailable)))
+	}
+	w.Write([]byte("<p>"))
+	if offset > 0 {
+		w.Write([]byte(fmt.Sprintf("<a href='/?offset=%d&limit=%d%s'>Назад</a> ", max(offset-limit, 0), limit, tenant)))
+	}
+	if len(data)+unavailable == limit {
+		w.Write([]byte(fmt.Sprintf("<a href='/?offset=%d&limit=%d%s'>Далее</a>", offset+limit, limit, tenant)))
+	}
+	w.Write([]byte("</p>"))
+	w.Write([]byte("</body></html>"))
 }
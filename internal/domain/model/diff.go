@@ -0,0 +1,76 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// DiffOrders вычисляет изменения полей между двумя версиями заказа.
+// Отсутствующая версия (nil) считается пустым документом.
+func DiffOrders(prev, next *Order) []FieldChange {
+	before := flattenOrder(prev)
+	after := flattenOrder(next)
+
+	paths := make(map[string]struct{}, len(before)+len(after))
+	for path := range before {
+		paths[path] = struct{}{}
+	}
+	for path := range after {
+		paths[path] = struct{}{}
+	}
+
+	changes := []FieldChange{}
+	for path := range paths {
+		oldValue, newValue := before[path], after[path]
+		if !reflect.DeepEqual(oldValue, newValue) {
+			changes = append(changes, FieldChange{Path: path, Old: oldValue, New: newValue})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
+
+// flattenOrder раскладывает JSON-представление заказа в отображение путь → значение.
+func flattenOrder(order *Order) map[string]interface{} {
+	fields := make(map[string]interface{})
+	if order == nil {
+		return fields
+	}
+
+	data, err := json.Marshal(order)
+	if err != nil {
+		return fields
+	}
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return fields
+	}
+	flatten("", doc, fields)
+	// Версия меняется при каждом изменении заказа и сама изменением не считается
+	delete(fields, "version")
+	return fields
+}
+
+// flatten рекурсивно обходит значение JSON, собирая листовые значения.
+func flatten(prefix string, value interface{}, fields map[string]interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			path := key
+			if prefix != "" {
+				path = prefix + "." + key
+			}
+			flatten(path, child, fields)
+		}
+	case []interface{}:
+		for i, child := range v {
+			flatten(fmt.Sprintf("%s[%d]", prefix, i), child, fields)
+		}
+	default:
+		if v != nil {
+			fields[prefix] = v
+		}
+	}
+}
@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// Типы событий об изменении заказов.
+const (
+	EventOrderUpserted = "order.upserted" // Заказ создан или изменен
+	EventOrderDeleted  = "order.deleted"  // Заказ удален
+)
+
+// OrderEvent описывает событие об изменении заказа, доставляемое из outbox.
+type OrderEvent struct {
+	ID        int64     `json:"id"`              // Идентификатор события
+	Type      string    `json:"type"`            // Тип события
+	OrderUID  string    `json:"order_uid"`       // Уникальный идентификатор заказа
+	Order     *Order    `json:"order,omitempty"` // Актуальный документ заказа, пуст для удаления
+	CreatedAt time.Time `json:"created_at"`      // Время возникновения события
+}
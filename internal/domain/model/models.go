@@ -8,7 +8,7 @@ import (
 
 // Delivery описывает информацию о доставке.
 type Delivery struct {
-	ID      *int    `json:"-" validate:"required"`                     // Идентификатор
+	ID      *int    `json:"-"`                                         // Идентификатор
 	Name    *string `json:"name,omitempty" validate:"required"`        // Имя получателя
 	Phone   *string `json:"phone,omitempty" validate:"required,e164"`  // Телефонный номер получателя
 	Zip     *string `json:"zip,omitempty" validate:"required"`         // Почтовый индекс
@@ -20,7 +20,7 @@ type Delivery struct {
 
 // Payment описывает информацию об оплате.
 type Payment struct {
-	ID           *int    `json:"-" validate:"required"`                         // Идентификатор
+	ID           *int    `json:"-"`                                             // Идентификатор
 	Transaction  *string `json:"transaction,omitempty" validate:"required"`     // Идентификатор транзакции
 	RequestID    *string `json:"request_id,omitempty" validate:"required"`      // Идентификатор запроса
 	Currency     *string `json:"currency,omitempty" validate:"required"`        // Валюта
@@ -35,7 +35,7 @@ type Payment struct {
 
 // Item описывает информацию о товаре в заказе.
 type Item struct {
-	ID          *int    `json:"-" validate:"required"`                          // Идентификатор
+	ID          *int    `json:"-"`                                              // Идентификатор
 	ChrtID      *int    `json:"chrt_id,omitempty" validate:"required"`          // Идентификатор товара
 	TrackNumber *string `json:"track_number,omitempty" validate:"required"`     // Номер отслеживания
 	Price       *int    `json:"price,omitempty" validate:"required,gt=0"`       // Цена
@@ -51,12 +51,12 @@ type Item struct {
 
 // Order описывает структуру заказа.
 type Order struct {
-	ID                string    `json:"id" validate:"required,uuid4"`                      // Идентификатор заказа
+	ID                string    `json:"id" validate:"omitempty,uuid4"`                     // Идентификатор заказа
 	OrderUID          string    `json:"order_uid" validate:"required,uuid4"`               // Уникальный идентификатор заказа
 	TrackNumber       *string   `json:"track_number,omitempty" validate:"omitempty,uuid4"` // Номер отслеживания заказа
 	Entry             *string   `json:"entry,omitempty" validate:"omitempty"`              // Точка входа
-	Delivery          *Delivery `json:"delivery,omitempty" validate:"omitempty,dive"`      // Информация о доставке
-	Payment           *Payment  `json:"payment,omitempty" validate:"omitempty,dive"`       // Информация об оплате
+	Delivery          *Delivery `json:"delivery,omitempty" validate:"omitempty"`           // Информация о доставке
+	Payment           *Payment  `json:"payment,omitempty" validate:"omitempty"`            // Информация об оплате
 	Items             []Item    `json:"items" validate:"required,dive"`                    // Список товаров
 	Locale            *string   `json:"locale,omitempty" validate:"omitempty"`             // Локализация
 	InternalSignature *string   `json:"internal_signature,omitempty" validate:"omitempty"` // Внутренняя подпись
@@ -66,6 +66,7 @@ type Order struct {
 	SMID              *int      `json:"sm_id,omitempty" validate:"omitempty"`              // Идентификатор социальных медиа
 	DateCreated       string    `json:"date_created" validate:"required"`                  // Дата создания заказа
 	OofShard          *string   `json:"oof_shard,omitempty" validate:"omitempty"`          // Шард для OOF
+	Version           int       `json:"version,omitempty"`                                 // Версия заказа, увеличивается при каждом изменении
 }
 
 // Validate выполняет валидацию структуры с использованием библиотеки go-playground/validator.
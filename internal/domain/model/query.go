@@ -0,0 +1,41 @@
+package model
+
+import "time"
+
+// ListQuery описывает параметры постраничной выборки заказов.
+type ListQuery struct {
+	Limit           int       // Максимальное число заказов на странице
+	Cursor          string    // Курсор, полученный с предыдущей страницы
+	Descending      bool      // Сортировка от новых заказов к старым
+	Entry           string    // Фильтр по точке входа
+	DeliveryService string    // Фильтр по службе доставки
+	Locale          string    // Фильтр по локализации
+	CustomerID      string    // Фильтр по идентификатору клиента
+	CreatedFrom     time.Time // Нижняя граница даты создания (включительно)
+	CreatedTo       time.Time // Верхняя граница даты создания (не включительно)
+}
+
+// OrderPage описывает страницу заказов.
+type OrderPage struct {
+	Orders     []Order `json:"orders"`                // Заказы на странице
+	NextCursor string  `json:"next_cursor,omitempty"` // Курсор следующей страницы, пуст на последней
+}
+
+// SearchQuery описывает критерии поиска заказов. Заданные критерии объединяются через И.
+type SearchQuery struct {
+	TrackNumber string // Номер отслеживания заказа
+	CustomerID  string // Идентификатор клиента
+	Phone       string // Телефон получателя
+	Email       string // Электронная почта получателя (без учета регистра)
+	Transaction string // Идентификатор транзакции оплаты
+	Brand       string // Бренд товара (поиск по подстроке)
+	NmID        int    // Внешний идентификатор товара
+	Text        string // Подстрока в имени получателя, городе, адресе или названии товара
+	Limit       int    // Максимальное число результатов
+}
+
+// IsEmpty сообщает, что не задан ни один критерий поиска.
+func (q SearchQuery) IsEmpty() bool {
+	return q.TrackNumber == "" && q.CustomerID == "" && q.Phone == "" && q.Email == "" &&
+		q.Transaction == "" && q.Brand == "" && q.NmID == 0 && q.Text == ""
+}
@@ -0,0 +1,43 @@
+package model
+
+import "time"
+
+// Операции, фиксируемые в истории заказа.
+const (
+	OperationCreate  = "create"  // Заказ создан
+	OperationUpdate  = "update"  // Заказ изменен
+	OperationDelete  = "delete"  // Заказ удален
+	OperationRestore = "restore" // Удаленный заказ восстановлен
+)
+
+// Источники изменений заказа.
+const (
+	SourceNATS   = "nats"   // Сообщение из NATS Streaming
+	SourceHTTP   = "http"   // Запрос к HTTP API
+	SourceAdmin  = "admin"  // Административная команда
+	SourceSystem = "system" // Внутренний процесс сервиса
+)
+
+// RevisionSource описывает, откуда пришло изменение заказа.
+type RevisionSource struct {
+	Kind string `json:"kind"`          // Тип источника
+	Ref  string `json:"ref,omitempty"` // Ссылка внутри источника, например номер сообщения NATS
+}
+
+// FieldChange описывает изменение одного поля заказа.
+type FieldChange struct {
+	Path string      `json:"path"`          // Путь к полю, например payment.amount или items[0].price
+	Old  interface{} `json:"old,omitempty"` // Значение до изменения
+	New  interface{} `json:"new,omitempty"` // Значение после изменения
+}
+
+// OrderRevision описывает одну запись истории заказа.
+type OrderRevision struct {
+	OrderUID  string         `json:"order_uid"`          // Уникальный идентификатор заказа
+	Revision  int            `json:"revision"`           // Порядковый номер ревизии
+	Operation string         `json:"operation"`          // Выполненная операция
+	Source    RevisionSource `json:"source"`             // Источник изменения
+	Previous  *Order         `json:"previous,omitempty"` // Документ заказа до изменения
+	Diff      []FieldChange  `json:"diff"`               // Изменения по полям
+	ChangedAt time.Time      `json:"changed_at"`         // Время изменения
+}
@@ -1,32 +1,123 @@
 package cache
 
 import (
+	"bytes"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/ArtemZ007/wb-l0/internal/domain/model"
 	"github.com/ArtemZ007/wb-l0/pkg/logger"
 	"github.com/go-redis/redis/v8"
 )
 
-// Cache определяет интерфейс для сервиса кэша.
-type Cache interface {
-	Get(key string) (*model.Order, bool)
-	Set(key string, value *model.Order)
-	Delete(key string)
+// OrderStore определяет хранилище заказов, из которого их читают HTTP обработчики, через которое
+// NATS слушатель записывает новые заказы и которое прогревается при запуске и получает события
+// об изменении заказов. Реализации регистрируются
+// по имени (см. RegisterStore): CacheService на основе Redis, MemoryCache в памяти процесса
+// и DatabaseStore, читающее заказы напрямую из базы данных.
+type OrderStore interface {
+	// InitCacheWithDBOrders заполняет хранилище заказами из базы данных.
+	InitCacheWithDBOrders(ctx context.Context, progress *WarmupProgress) error
+	// GetOrder возвращает заказ или ErrOrderNotFound, если его нет.
+	GetOrder(ctx context.Context, orderUID string) (*model.Order, error)
+	GetOrderIDs(ctx context.Context, offset, limit int) ([]string, error)
+	// GetOrders возвращает заказы в порядке идентификаторов, nil на месте отсутствующих
+	// и *PartialError, если часть заказов получить не удалось.
+	GetOrders(ctx context.Context, orderUIDs []string) ([]*model.Order, error)
+	GetOrdersPage(ctx context.Context, offset, limit int) ([]model.Order, error)
+	AddOrUpdateOrder(ctx context.Context, order *model.Order) error
+	DeleteOrder(ctx context.Context, orderUID string) error
+	ApplyOrderEvent(ctx context.Context, event model.OrderEvent) error
+	// Run выполняет фоновую работу хранилища и блокирует выполнение до завершения контекста.
+	Run(ctx context.Context) error
+	// OrderWriter записывает новые заказы в хранилище, из которого читает кэш.
+	OrderWriter
 }
 
 // OrderService определяет методы для операций с заказами.
 type OrderService interface {
-	ListOrders(ctx context.Context) ([]model.Order, error)
+	GetOrder(ctx context.Context, orderUID string) (*model.Order, error)
+	ListOrders(ctx context.Context, query model.ListQuery) (*model.OrderPage, error)
+}
+
+// NamespaceFunc возвращает пространство имен кэша для контекста запроса — схему базы данных арендатора,
+// чтобы заказы разных схем не смешивались в кэше. Пустое пространство имен означает общий кэш.
+type NamespaceFunc func(ctx context.Context) (string, error)
+
+// of возвращает пространство имен контекста ctx; без функции пространство имен пустое.
+func (f NamespaceFunc) of(ctx context.Context) (string, error) {
+	if f == nil {
+		return "", nil
+	}
+	return f(ctx)
+}
+
+const (
+	warmupPageSize = 500             // Число заказов, загружаемых из базы данных за один запрос при прогреве кэша
+	scanBatchSize  = 500             // Число ключей, запрашиваемых за одну итерацию SCAN
+	orderKeyPrefix = "order:"        // Префикс ключей заказов
+	changedPrefix  = "changed:"      // Префикс отметок о недавнем изменении заказов, см. fillOrder
+	orderIndexKey  = "orders:index"  // Сортированное множество идентификаторов заказов с датой создания в качестве веса
+	orderAccessKey = "orders:access" // Сортированное множество ключей заказов всех пространств имен со временем последнего обращения
+)
+
+// keyspace формирует ключи Redis в пространстве имен: ключи пустого пространства имен
+// не имеют префикса, остальные начинаются с имени пространства и двоеточия.
+type keyspace struct {
+	prefix string
+}
+
+// newKeyspace возвращает ключи пространства имен namespace.
+func newKeyspace(namespace string) keyspace {
+	if namespace == "" {
+		return keyspace{}
+	}
+	return keyspace{prefix: namespace + ":"}
+}
+
+// order возвращает ключ заказа orderUID. Ключ заказа уникален среди всех пространств имен,
+// поэтому им же заказ обозначается в локальном кэше, загрузчике и сообщениях инвалидации.
+func (k keyspace) order(orderUID string) string {
+	return k.prefix + orderKeyPrefix + orderUID
+}
+
+// changed возвращает ключ отметки о недавнем изменении заказа orderUID.
+func (k keyspace) changed(orderUID string) string {
+	return k.prefix + changedPrefix + orderUID
+}
+
+// index возвращает ключ индекса заказов пространства имен.
+func (k keyspace) index() string {
+	return k.prefix + orderIndexKey
+}
+
+// orderScore возвращает вес заказа в индексе — дату создания в миллисекундах.
+// Заказы с некорректной датой создания получают нулевой вес.
+func orderScore(order *model.Order) float64 {
+	created, err := time.Parse(time.RFC3339Nano, order.DateCreated)
+	if err != nil {
+		return 0
+	}
+	return float64(created.UnixMilli())
 }
 
 // CacheService представляет собой сервис кэша.
 type CacheService struct {
-	client    *redis.Client
-	logger    logger.Logger
-	dbService OrderService
+	orderWriter
+	client     *redis.Client
+	logger     logger.Logger
+	dbService  OrderService
+	expiry     ExpiryPolicy
+	envelope   envelope      // Формат значений заказов в Redis
+	loader     *loader       // Загрузчик отсутствующих в Redis заказов из базы данных
+	local      *lru          // Локальный кэш перед Redis; nil, если отключен
+	subscribed atomic.Bool   // Активна ли подписка на инвалидацию локального кэша
+	instanceID string        // Идентификатор экземпляра в сообщениях инвалидации
+	namespace  NamespaceFunc // Пространство имен ключей для контекста запроса
 }
 
 // NewCacheService создает и возвращает новый экземпляр CacheService.
@@ -38,8 +129,11 @@ func NewCacheService(redisAddr, redisPassword string, redisDB int, logger logger
 	})
 
 	return &CacheService{
-		client: rdb,
-		logger: logger,
+		client:     rdb,
+		logger:     logger,
+		envelope:   defaultEnvelope(),
+		loader:     newLoader(),
+		instanceID: newInstanceID(),
 	}
 }
 
@@ -48,97 +142,408 @@ func (s *CacheService) SetDBService(dbService OrderService) {
 	s.dbService = dbService
 }
 
-// InitCacheWithDBOrders инициализирует кэш заказами из базы данных.
-func (s *CacheService) InitCacheWithDBOrders(ctx context.Context) error {
-	orders, err := s.dbService.ListOrders(ctx)
+// SetNamespace задает пространство имен ключей Redis для контекста запроса.
+func (s *CacheService) SetNamespace(namespace NamespaceFunc) {
+	s.namespace = namespace
+}
+
+// keyspace возвращает ключи Redis пространства имен контекста ctx.
+func (s *CacheService) keyspace(ctx context.Context) (keyspace, error) {
+	namespace, err := s.namespace.of(ctx)
 	if err != nil {
-		s.logger.Error("Ошибка при получении заказов из базы данных", map[string]interface{}{"error": err})
-		return err
+		return keyspace{}, err
 	}
+	return newKeyspace(namespace), nil
+}
 
-	for _, order := range orders {
-		if err := s.AddOrUpdateOrder(&order); err != nil {
-			s.logger.Error("Ошибка при добавлении заказа в кэш", map[string]interface{}{"error": err})
-		}
-	}
-	s.logger.Info("Кэш инициализирован заказами", map[string]interface{}{"count": len(orders)})
+// SetExpiryPolicy задает время жизни заказов в Redis и ограничение их числа.
+func (s *CacheService) SetExpiryPolicy(policy ExpiryPolicy) {
+	s.expiry = policy
+}
 
-	return nil
+// SetCodec задает кодек, которым заказы записываются в Redis, и включает их сжатие zstd.
+// Значения читаются любым известным кодеком независимо от этой настройки, поэтому кодек можно
+// менять без очистки Redis.
+func (s *CacheService) SetCodec(codec Codec, compress bool) {
+	s.envelope = envelope{codec: codec, compress: compress}
+}
+
+// SetNegativeTTL задает, сколько помнить об отсутствии заказа в базе данных; 0 отключает запоминание.
+func (s *CacheService) SetNegativeTTL(ttl time.Duration) {
+	s.loader.setNegativeTTL(ttl)
+}
+
+// InitCacheWithDBOrders инициализирует кэш заказами из базы данных, загружая их постранично
+// и записывая каждую страницу в Redis одним конвейером. Ход прогрева отражается в progress, если он задан.
+func (s *CacheService) InitCacheWithDBOrders(ctx context.Context, progress *WarmupProgress) error {
+	return loadOrders(ctx, s.dbService, s.addOrders, progress, s.logger)
 }
 
 // GetOrder извлекает заказ из кэша по его уникальному идентификатору.
+// Заказ сначала ищется в локальном кэше, затем в Redis; истекший или вытесненный заказ
+// загружается из базы данных, а если его нет и там, возвращается ErrOrderNotFound. Заказ из локального кэша общий для всех вызывающих и не должен изменяться.
 func (s *CacheService) GetOrder(ctx context.Context, orderUID string) (*model.Order, error) {
-	orderData, err := s.client.Get(ctx, orderUID).Result()
-	if err == redis.Nil {
-		return nil, fmt.Errorf("заказ с UID %s не найден в кэше", orderUID)
-	} else if err != nil {
+	keys, err := s.keyspace(ctx)
+	if err != nil {
+		return nil, err
+	}
+	key := keys.order(orderUID)
+	local := s.localCache()
+	var epoch uint64
+	if local != nil {
+		if order, ok := local.get(key); ok {
+			return order, nil
+		}
+		epoch = local.currentEpoch()
+	}
+
+	orderData, err := s.client.Get(ctx, key).Bytes()
+	if err != nil && err != redis.Nil {
 		s.logger.Error("Ошибка при получении заказа из Redis", map[string]interface{}{"error": err})
 		return nil, err
 	}
 
 	var order model.Order
-	if err := json.Unmarshal([]byte(orderData), &order); err != nil {
-		s.logger.Error("Ошибка при декодировании заказа из Redis", map[string]interface{}{"error": err})
-		return nil, err
+	var undecoded []byte
+	if err == nil {
+		if err = s.envelope.decode(orderData, &order); err != nil {
+			s.logger.Warn("Ошибка при декодировании заказа из Redis, заказ будет загружен заново",
+				map[string]interface{}{"error": err, "orderUID": orderUID})
+			undecoded = orderData
+		}
+	}
+	if err != nil {
+		reloaded, err := s.reload(ctx, keys, orderUID, undecoded)
+		if err != nil {
+			return nil, err
+		}
+		if reloaded == nil {
+			return nil, notFound(orderUID)
+		}
+		return reloaded, nil
 	}
 
+	s.touch(ctx, key)
+	if local != nil {
+		local.add(key, &order, len(orderData), epoch)
+	}
 	return &order, nil
 }
 
-// GetAllOrderIDs возвращает все уникальные идентификаторы заказов.
+// GetAllOrderIDs возвращает идентификаторы всех заказов в кэше пространства имен контекста ctx.
+// Ключи перебираются командой SCAN, которая, в отличие от KEYS, не блокирует Redis.
 func (s *CacheService) GetAllOrderIDs(ctx context.Context) ([]string, error) {
-	keys, err := s.client.Keys(ctx, "*").Result()
+	keys, err := s.keyspace(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	prefix := keys.order("")
+	iter := s.client.Scan(ctx, 0, prefix+"*", scanBatchSize).Iterator()
+	for iter.Next(ctx) {
+		ids = append(ids, strings.TrimPrefix(iter.Val(), prefix))
+	}
+	if err := iter.Err(); err != nil {
+		s.logger.Error("Ошибка при переборе ключей заказов в Redis", map[string]interface{}{"error": err})
+		return nil, err
+	}
+	return ids, nil
+}
+
+// GetOrderIDs возвращает страницу идентификаторов заказов из индекса, от новых заказов к старым.
+func (s *CacheService) GetOrderIDs(ctx context.Context, offset, limit int) ([]string, error) {
+	if offset < 0 || limit <= 0 {
+		return []string{}, nil
+	}
+	keys, err := s.keyspace(ctx)
 	if err != nil {
-		s.logger.Error("Ошибка при получении всех ключей из Redis", map[string]interface{}{"error": err})
 		return nil, err
 	}
-	return keys, nil
+	ids, err := s.client.ZRevRange(ctx, keys.index(), int64(offset), int64(offset+limit-1)).Result()
+	if err != nil {
+		s.logger.Error("Ошибка при чтении индекса заказов из Redis", map[string]interface{}{"error": err})
+		return nil, err
+	}
+	return ids, nil
+}
+
+// GetOrdersPage возвращает страницу заказов из кэша, от новых заказов к старым, получая их через GetOrders.
+// Заказы, удаленные между чтением индекса и получением заказов, пропускаются.
+// Если часть заказов получить не удалось, вместе с остальными возвращается *PartialError.
+func (s *CacheService) GetOrdersPage(ctx context.Context, offset, limit int) ([]model.Order, error) {
+	return ordersPage(ctx, s, offset, limit)
 }
 
 // AddOrUpdateOrder добавляет или обновляет заказ в кэше.
-func (s *CacheService) AddOrUpdateOrder(order *model.Order) error {
-	orderData, err := json.Marshal(order)
+func (s *CacheService) AddOrUpdateOrder(ctx context.Context, order *model.Order) error {
+	keys, err := s.keyspace(ctx)
+	if err != nil {
+		return err
+	}
+	orderData, err := s.envelope.encode(order)
 	if err != nil {
 		s.logger.Error("Ошибка при сериализации заказа", map[string]interface{}{"error": err})
 		return err
 	}
 
-	if err := s.client.Set(context.Background(), order.OrderUID, orderData, 0).Err(); err != nil {
+	// Заказ, его запись в индексе и сообщение для локальных кэшей других экземпляров записываются атомарно
+	now := time.Now()
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		s.writeOrder(ctx, pipe, keys, order, orderData, now)
+		pipe.Set(ctx, keys.changed(order.OrderUID), 1, loadTimeout)
+		return nil
+	})
+	s.forgetLocal(keys.order(order.OrderUID))
+	if err != nil {
 		s.logger.Error("Ошибка при добавлении заказа в Redis", map[string]interface{}{"error": err})
 		return err
 	}
 
+	s.evict(ctx)
 	return nil
 }
 
-// GetData возвращает все заказы из кэша.
-func (s *CacheService) GetData() ([]model.Order, bool) {
-	ctx := context.Background()
-	keys, err := s.client.Keys(ctx, "*").Result()
+// addOrders записывает пакет заказов в Redis одним конвейером. В отличие от AddOrUpdateOrder
+// заказы пакета записываются не атомарно, зато за одно обращение к Redis.
+func (s *CacheService) addOrders(ctx context.Context, orders []model.Order) error {
+	if len(orders) == 0 {
+		return nil
+	}
+	keys, err := s.keyspace(ctx)
 	if err != nil {
-		s.logger.Error("Ошибка при получении всех ключей из Redis", map[string]interface{}{"error": err})
-		return nil, false
+		return err
+	}
+	now := time.Now()
+	_, err = s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i := range orders {
+			orderData, err := s.envelope.encode(&orders[i])
+			if err != nil {
+				s.logger.Error("Ошибка при сериализации заказа", map[string]interface{}{"error": err, "orderUID": orders[i].OrderUID})
+				continue
+			}
+			s.writeOrder(ctx, pipe, keys, &orders[i], orderData, now)
+		}
+		return nil
+	})
+	for i := range orders {
+		s.forgetLocal(keys.order(orders[i].OrderUID))
+	}
+	if err != nil {
+		s.logger.Error("Ошибка при добавлении заказов в Redis", map[string]interface{}{"error": err})
+		return err
+	}
+
+	s.evict(ctx)
+	return nil
+}
+
+// writeOrder добавляет в конвейер pipe запись заказа, его позиции в индексе и времени обращения,
+// а также сообщение для локальных кэшей других экземпляров.
+func (s *CacheService) writeOrder(ctx context.Context, pipe redis.Pipeliner, keys keyspace, order *model.Order, orderData []byte, now time.Time) {
+	key := keys.order(order.OrderUID)
+	pipe.Set(ctx, key, orderData, s.expiry.ttl(order, now))
+	pipe.ZAdd(ctx, keys.index(), &redis.Z{Score: orderScore(order), Member: order.OrderUID})
+	if s.expiry.MaxEntries > 0 {
+		pipe.ZAdd(ctx, orderAccessKey, &redis.Z{Score: float64(now.UnixMilli()), Member: key})
+	}
+	s.publishInvalidation(ctx, pipe, key)
+}
+
+// forgetLocal удаляет записанный заказ с ключом key из локального кэша и из запомненных отсутствующих заказов.
+func (s *CacheService) forgetLocal(key string) {
+	if s.local != nil {
+		s.local.remove(key)
+	}
+	s.loader.forget(key)
+}
+
+// DeleteOrder удаляет заказ из кэша.
+func (s *CacheService) DeleteOrder(ctx context.Context, orderUID string) error {
+	keys, err := s.keyspace(ctx)
+	if err != nil {
+		return err
 	}
+	key := keys.order(orderUID)
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, key)
+		pipe.ZRem(ctx, keys.index(), orderUID)
+		pipe.ZRem(ctx, orderAccessKey, key)
+		pipe.Set(ctx, keys.changed(orderUID), 1, loadTimeout)
+		s.publishInvalidation(ctx, pipe, key)
+		return nil
+	})
+	s.forgetLocal(key)
+	if err != nil {
+		s.logger.Error("Ошибка при удалении заказа из Redis", map[string]interface{}{"error": err})
+		return err
+	}
+	return nil
+}
 
-	var orders []model.Order
-	for _, key := range keys {
-		orderData, err := s.client.Get(ctx, key).Result()
+// reload загружает отсутствующий в Redis заказ из базы данных и кэширует его.
+// Одновременные загрузки одного заказа объединяются в один запрос к базе.
+// Если заказа нет в базе, он удаляется из индекса и возвращается nil без ошибки.
+// Кэш меняется, только если заказ не изменялся во время загрузки (см. fillOrder);
+// undecoded — значение заказа в Redis, которое не удалось декодировать, или nil, если ключа нет.
+func (s *CacheService) reload(ctx context.Context, keys keyspace, orderUID string, undecoded []byte) (*model.Order, error) {
+	return s.loader.load(ctx, keys.order(orderUID), func(ctx context.Context) (*model.Order, error) {
+		order, err := reloadOrder(ctx, s.dbService, orderUID)
 		if err != nil {
-			s.logger.Error("Ошибка при получении заказа из Redis", map[string]interface{}{"error": err})
-			continue
+			s.logger.Error("Ошибка при загрузке заказа из базы данных", map[string]interface{}{"error": err})
+			return nil, err
 		}
+		if order == nil {
+			if s.dbService != nil {
+				if err := s.fillOrder(ctx, keys, orderUID, nil, nil); err != nil {
+					s.logger.Warn("Ошибка при удалении заказа из индекса Redis", map[string]interface{}{"error": err})
+				}
+			}
+			return nil, nil
+		}
+		// Заказ будет загружен заново при следующем обращении
+		if err := s.fillOrder(ctx, keys, orderUID, order, undecoded); err != nil {
+			s.logger.Error("Ошибка при добавлении заказа в Redis", map[string]interface{}{"error": err})
+		}
+		return order, nil
+	})
+}
 
-		var order model.Order
-		if err := json.Unmarshal([]byte(orderData), &order); err != nil {
-			s.logger.Error("Ошибка при декодировании заказа из Redis", map[string]interface{}{"error": err})
-			continue
+// fillOrder записывает в Redis заказ orderUID, загруженный из базы данных, или, если order равен nil,
+// удаляет отсутствующий в базе заказ из индекса. Redis меняется, только если ключа заказа в нем
+// по-прежнему нет (или в нем все еще недекодируемое значение undecoded), а отметки о недавнем
+// изменении заказа нет: иначе событие outbox уже записало или удалило заказ после его чтения из базы,
+// и загруженное состояние устарело. Отметка хранится loadTimeout — дольше, чем может длиться
+// загрузка, — и видна всем экземплярам.
+func (s *CacheService) fillOrder(ctx context.Context, keys keyspace, orderUID string, order *model.Order, undecoded []byte) error {
+	key, changed := keys.order(orderUID), keys.changed(orderUID)
+	var orderData []byte
+	if order != nil {
+		var err error
+		if orderData, err = s.envelope.encode(order); err != nil {
+			return err
 		}
+	}
 
-		orders = append(orders, order)
+	written := false
+	err := s.client.Watch(ctx, func(tx *redis.Tx) error {
+		if s.loader.superseded(key) {
+			return nil
+		}
+		current, err := tx.Get(ctx, key).Bytes()
+		switch {
+		case err == redis.Nil:
+		case err != nil:
+			return err
+		case undecoded == nil || !bytes.Equal(current, undecoded):
+			return nil
+		}
+		exists, err := tx.Exists(ctx, changed).Result()
+		if err != nil || exists > 0 {
+			return err
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			if order == nil {
+				pipe.ZRem(ctx, keys.index(), orderUID)
+				return nil
+			}
+			s.writeOrder(ctx, pipe, keys, order, orderData, time.Now())
+			return nil
+		})
+		written = err == nil && order != nil
+		return err
+	}, key, changed)
+	if errors.Is(err, redis.TxFailedErr) {
+		// Заказ изменился между проверкой и записью
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if written {
+		s.evict(ctx)
 	}
+	return nil
+}
 
-	if len(orders) == 0 {
-		return nil, false
+// touch отмечает обращение к заказам с ключами keys для вытеснения давно не использованных заказов.
+func (s *CacheService) touch(ctx context.Context, keys ...string) {
+	if s.expiry.MaxEntries <= 0 || len(keys) == 0 {
+		return
+	}
+	now := float64(time.Now().UnixMilli())
+	members := make([]*redis.Z, len(keys))
+	for i, key := range keys {
+		members[i] = &redis.Z{Score: now, Member: key}
+	}
+	if err := s.client.ZAddXX(ctx, orderAccessKey, members...).Err(); err != nil {
+		s.logger.Warn("Ошибка при обновлении времени обращения к заказам в Redis", map[string]interface{}{"error": err})
+	}
+}
+
+// evict вытесняет давно не использованные заказы всех пространств имен сверх ограничения MaxEntries.
+// Вытесненные заказы остаются в индексе и при обращении загружаются из базы данных заново.
+func (s *CacheService) evict(ctx context.Context) {
+	if s.expiry.MaxEntries <= 0 {
+		return
+	}
+	count, err := s.client.ZCard(ctx, orderAccessKey).Result()
+	if err != nil {
+		s.logger.Warn("Ошибка при подсчете заказов в Redis", map[string]interface{}{"error": err})
+		return
+	}
+	excess := count - int64(s.expiry.MaxEntries)
+	if excess <= 0 {
+		return
+	}
+	evicted, err := s.client.ZPopMin(ctx, orderAccessKey, excess).Result()
+	if err != nil {
+		s.logger.Warn("Ошибка при вытеснении заказов из Redis", map[string]interface{}{"error": err})
+		return
+	}
+
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, z := range evicted {
+			key, _ := z.Member.(string)
+			pipe.Del(ctx, key)
+			s.publishInvalidation(ctx, pipe, key)
+		}
+		return nil
+	})
+	if s.local != nil {
+		for _, z := range evicted {
+			key, _ := z.Member.(string)
+			s.local.remove(key)
+		}
+	}
+	if err != nil {
+		s.logger.Warn("Ошибка при вытеснении заказов из Redis", map[string]interface{}{"error": err})
+		return
+	}
+	s.logger.Debug("Давно не использованные заказы вытеснены из Redis", map[string]interface{}{"count": len(evicted)})
+}
+
+// ApplyOrderEvent применяет событие об изменении заказа из outbox к кэшу.
+func (s *CacheService) ApplyOrderEvent(ctx context.Context, event model.OrderEvent) error {
+	return applyOrderEvent(ctx, s, event, s.logger)
+}
+
+// Run выполняет фоновую работу кэша — инвалидацию локального кэша — до завершения контекста.
+func (s *CacheService) Run(ctx context.Context) error {
+	return s.RunInvalidation(ctx)
+}
+
+// applyOrderEvent применяет событие об изменении заказа из outbox к кэшу c.
+func applyOrderEvent(ctx context.Context, c OrderStore, event model.OrderEvent, logger logger.Logger) error {
+	switch event.Type {
+	case model.EventOrderUpserted:
+		if event.Order == nil {
+			return fmt.Errorf("событие %d не содержит заказ", event.ID)
+		}
+		return c.AddOrUpdateOrder(ctx, event.Order)
+	case model.EventOrderDeleted:
+		return c.DeleteOrder(ctx, event.OrderUID)
+	default:
+		logger.Warn("Неизвестный тип события заказа", map[string]interface{}{"type": event.Type})
+		return nil
 	}
-	return orders, true
 }
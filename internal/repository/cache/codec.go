@@ -0,0 +1,186 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ArtemZ007/wb-l0/internal/domain/model"
+	"github.com/klauspost/compress/zstd"
+	"github.com/vmihailenco/msgpack"
+)
+
+// Формат значения заказа в Redis (конверт):
+//
+//	байт 0 — envelopeMagic, отличающий конверт от JSON прежних версий, который начинается с '{';
+//	байт 1 — версия конверта;
+//	байт 2 — идентификатор кодека содержимого;
+//	байт 3 — флаги, например сжатие содержимого;
+//	далее — содержимое заказа, закодированное кодеком и, если задан флаг, сжатое.
+//
+// Значения без конверта считаются конвертом версии 0 с содержимым в JSON.
+const (
+	envelopeMagic      byte = 0xCE // Первый байт конверта
+	envelopeVersion    byte = 1    // Версия конверта, записываемая этой версией сервиса
+	envelopeHeaderSize      = 4    // Размер заголовка конверта в байтах
+
+	flagZstd byte = 1 << 0 // Содержимое сжато zstd
+)
+
+// Кодеки содержимого заказа. Идентификаторы записываются в конверт и не должны меняться.
+const (
+	codecJSON    byte = 1 // JSON
+	codecMsgpack byte = 2 // MessagePack
+)
+
+// Поддерживаемые алгоритмы сжатия значений.
+const (
+	CompressionNone = "none" // Без сжатия
+	CompressionZstd = "zstd" // Сжатие zstd
+)
+
+// ErrUnsupportedEnvelope возвращается для значений, записанных в неизвестном формате,
+// например более новой версией сервиса во время поэтапного обновления.
+var ErrUnsupportedEnvelope = errors.New("неподдерживаемый формат значения в кэше")
+
+// Codec кодирует содержимое заказа в конверте.
+type Codec interface {
+	// ID возвращает идентификатор кодека, записываемый в конверт.
+	ID() byte
+	Marshal(order *model.Order) ([]byte, error)
+	Unmarshal(data []byte, order *model.Order) error
+}
+
+// jsonCodec кодирует заказ в JSON.
+type jsonCodec struct{}
+
+func (jsonCodec) ID() byte { return codecJSON }
+
+func (jsonCodec) Marshal(order *model.Order) ([]byte, error) {
+	return json.Marshal(order)
+}
+
+func (jsonCodec) Unmarshal(data []byte, order *model.Order) error {
+	return json.Unmarshal(data, order)
+}
+
+// msgpackCodec кодирует заказ в MessagePack. Поля именуются по тегам json, поэтому
+// добавление и удаление полей заказа не нарушает чтение ранее записанных значений.
+type msgpackCodec struct{}
+
+func (msgpackCodec) ID() byte { return codecMsgpack }
+
+func (msgpackCodec) Marshal(order *model.Order) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := msgpack.NewEncoder(&buf).UseJSONTag(true).Encode(order); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (msgpackCodec) Unmarshal(data []byte, order *model.Order) error {
+	return msgpack.NewDecoder(bytes.NewReader(data)).UseJSONTag(true).Decode(order)
+}
+
+// codecs содержит кодеки по имени, под которым они выбираются в конфигурации.
+var codecs = map[string]Codec{
+	"json":    jsonCodec{},
+	"msgpack": msgpackCodec{},
+}
+
+// ParseCodec возвращает кодек по имени: json или msgpack.
+func ParseCodec(name string) (Codec, error) {
+	codec, ok := codecs[strings.ToLower(name)]
+	if !ok {
+		names := make([]string, 0, len(codecs))
+		for name := range codecs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("неизвестный кодек кэша %q, ожидается одно из: %s", name, strings.Join(names, ", "))
+	}
+	return codec, nil
+}
+
+// ParseCompression проверяет имя алгоритма сжатия значений и сообщает, включено ли сжатие.
+func ParseCompression(name string) (bool, error) {
+	switch strings.ToLower(name) {
+	case CompressionNone, "":
+		return false, nil
+	case CompressionZstd:
+		return true, nil
+	default:
+		return false, fmt.Errorf("неизвестный алгоритм сжатия кэша %q, ожидается %s или %s", name, CompressionNone, CompressionZstd)
+	}
+}
+
+// Кодировщик и декодировщик zstd безопасны для одновременного использования через EncodeAll и DecodeAll.
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil, zstd.WithDecoderConcurrency(0))
+)
+
+// envelope записывает заказы в конверт выбранным кодеком и читает конверты любым известным кодеком.
+type envelope struct {
+	codec    Codec
+	compress bool
+}
+
+// defaultEnvelope возвращает конверт с содержимым в JSON без сжатия.
+func defaultEnvelope() envelope {
+	return envelope{codec: jsonCodec{}}
+}
+
+// encode кодирует заказ в конверт. Содержимое сжимается, только если это уменьшает его размер.
+func (e envelope) encode(order *model.Order) ([]byte, error) {
+	payload, err := e.codec.Marshal(order)
+	if err != nil {
+		return nil, err
+	}
+	header := [envelopeHeaderSize]byte{envelopeMagic, envelopeVersion, e.codec.ID(), 0}
+	if e.compress {
+		compressed := zstdEncoder.EncodeAll(payload, make([]byte, 0, len(payload)))
+		if len(compressed) < len(payload) {
+			payload = compressed
+			header[3] |= flagZstd
+		}
+	}
+	data := make([]byte, 0, envelopeHeaderSize+len(payload))
+	data = append(data, header[:]...)
+	return append(data, payload...), nil
+}
+
+// decode декодирует заказ из конверта любой известной версии или из JSON без конверта.
+func (e envelope) decode(data []byte, order *model.Order) error {
+	if len(data) == 0 || data[0] != envelopeMagic {
+		return json.Unmarshal(data, order)
+	}
+	if len(data) < envelopeHeaderSize || data[1] != envelopeVersion {
+		return ErrUnsupportedEnvelope
+	}
+	codec := codecByID(data[2])
+	if codec == nil || data[3]&^flagZstd != 0 {
+		return ErrUnsupportedEnvelope
+	}
+	payload := data[envelopeHeaderSize:]
+	if data[3]&flagZstd != 0 {
+		var err error
+		if payload, err = zstdDecoder.DecodeAll(payload, nil); err != nil {
+			return fmt.Errorf("ошибка распаковки заказа: %w", err)
+		}
+	}
+	return codec.Unmarshal(payload, order)
+}
+
+// codecByID возвращает кодек по идентификатору из конверта или nil, если он неизвестен.
+func codecByID(id byte) Codec {
+	for _, codec := range codecs {
+		if codec.ID() == id {
+			return codec
+		}
+	}
+	return nil
+}
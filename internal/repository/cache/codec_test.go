@@ -0,0 +1,97 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ArtemZ007/wb-l0/internal/domain/model"
+	"github.com/ArtemZ007/wb-l0/internal/repository/ordertest"
+)
+
+// testOrder возвращает заказ с повторяющимися товарами, который zstd заметно сжимает.
+func testOrder() model.Order {
+	order := ordertest.NewOrder(time.Now().UTC().Truncate(time.Second))
+	for len(order.Items) < 20 {
+		order.Items = append(order.Items, order.Items[0])
+	}
+	return order
+}
+
+func TestEnvelopeRoundTrip(t *testing.T) {
+	tests := []struct {
+		name     string
+		codec    Codec
+		compress bool
+	}{
+		{"json", jsonCodec{}, false},
+		{"json+zstd", jsonCodec{}, true},
+		{"msgpack", msgpackCodec{}, false},
+		{"msgpack+zstd", msgpackCodec{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := testOrder()
+			e := envelope{codec: tt.codec, compress: tt.compress}
+			data, err := e.encode(&order)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var flags byte
+			if tt.compress {
+				flags = flagZstd
+			}
+			want := []byte{envelopeMagic, envelopeVersion, tt.codec.ID(), flags}
+			if len(data) < envelopeHeaderSize || !reflect.DeepEqual(data[:envelopeHeaderSize], want) {
+				t.Fatalf("заголовок конверта % x, ожидался % x", data[:min(len(data), envelopeHeaderSize)], want)
+			}
+
+			// Конверт читается независимо от кодека и сжатия, выбранных для записи
+			var got model.Order
+			if err := defaultEnvelope().decode(data, &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, order) {
+				t.Fatalf("декодирован заказ %+v, ожидался %+v", got, order)
+			}
+		})
+	}
+}
+
+func TestEnvelopeLegacyJSON(t *testing.T) {
+	order := testOrder()
+	data, err := json.Marshal(&order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got model.Order
+	if err := (envelope{codec: msgpackCodec{}, compress: true}).decode(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, order) {
+		t.Fatalf("декодирован заказ %+v, ожидался %+v", got, order)
+	}
+}
+
+func TestEnvelopeUnsupported(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"неизвестная версия", []byte{envelopeMagic, envelopeVersion + 1, codecJSON, 0, '{', '}'}},
+		{"неизвестный кодек", []byte{envelopeMagic, envelopeVersion, 0xFF, 0, '{', '}'}},
+		{"неизвестный флаг", []byte{envelopeMagic, envelopeVersion, codecJSON, 1 << 7, '{', '}'}},
+		{"неполный заголовок", []byte{envelopeMagic, envelopeVersion}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var order model.Order
+			if err := defaultEnvelope().decode(tt.data, &order); !errors.Is(err, ErrUnsupportedEnvelope) {
+				t.Fatalf("ожидалась ErrUnsupportedEnvelope, получено %v", err)
+			}
+		})
+	}
+}
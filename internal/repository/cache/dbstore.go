@@ -0,0 +1,187 @@
+package cache
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ArtemZ007/wb-l0/internal/domain/model"
+	"github.com/ArtemZ007/wb-l0/internal/repository/database"
+	"github.com/ArtemZ007/wb-l0/pkg/logger"
+)
+
+// DatabaseStore читает заказы напрямую из базы данных без кэширования. Изменения заказов
+// видны сразу, поэтому прогрев и события об изменении заказов ему не нужны.
+// Заказы читаются из схемы арендатора, указанного в контексте запроса.
+type DatabaseStore struct {
+	orderWriter
+	dbService OrderService
+	namespace NamespaceFunc // Пространство имен для контекста запроса
+	logger    logger.Logger
+
+	mu      sync.Mutex
+	cursors map[pageBoundary]string // Курсоры, с которых продолжается выдача после уже прочитанных страниц
+}
+
+// maxPageCursors ограничивает число запоминаемых границ страниц.
+const maxPageCursors = 1024
+
+// pageBoundary определяет границу страницы в выдаче заказов арендатора: позицию offset
+// в пространстве имен namespace.
+type pageBoundary struct {
+	namespace string
+	tenant    string
+	offset    int
+}
+
+// NewDatabaseStore создает хранилище, читающее заказы из dbService.
+func NewDatabaseStore(dbService OrderService, logger logger.Logger) *DatabaseStore {
+	return &DatabaseStore{dbService: dbService, logger: logger, cursors: make(map[pageBoundary]string)}
+}
+
+// SetNamespace задает пространство имен, в котором запоминаются границы страниц.
+func (d *DatabaseStore) SetNamespace(namespace NamespaceFunc) {
+	d.namespace = namespace
+}
+
+// InitCacheWithDBOrders ничего не делает: заказы читаются из базы данных по запросу.
+func (d *DatabaseStore) InitCacheWithDBOrders(ctx context.Context, progress *WarmupProgress) error {
+	return nil
+}
+
+// GetOrder возвращает заказ из базы данных или ErrOrderNotFound, если его нет.
+func (d *DatabaseStore) GetOrder(ctx context.Context, orderUID string) (*model.Order, error) {
+	order, err := d.dbService.GetOrder(ctx, orderUID)
+	if err != nil {
+		return nil, err
+	}
+	if order == nil {
+		return nil, notFound(orderUID)
+	}
+	return order, nil
+}
+
+// GetOrders возвращает заказы по идентификаторам в том же порядке; на месте заказов,
+// которых нет в базе данных, возвращается nil. Если часть заказов получить не удалось,
+// вместе с остальными возвращается *PartialError.
+func (d *DatabaseStore) GetOrders(ctx context.Context, orderUIDs []string) ([]*model.Order, error) {
+	found := make([]*model.Order, len(orderUIDs))
+	partial := &PartialError{}
+	for i, orderUID := range orderUIDs {
+		order, err := d.dbService.GetOrder(ctx, orderUID)
+		if err != nil {
+			partial.add(orderUID, err)
+			continue
+		}
+		found[i] = order
+	}
+	return found, partial.errOrNil()
+}
+
+// GetOrderIDs возвращает страницу идентификаторов заказов, от новых заказов к старым.
+func (d *DatabaseStore) GetOrderIDs(ctx context.Context, offset, limit int) ([]string, error) {
+	orders, err := d.GetOrdersPage(ctx, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(orders))
+	for i := range orders {
+		ids[i] = orders[i].OrderUID
+	}
+	return ids, nil
+}
+
+// GetOrdersPage возвращает страницу заказов, от новых заказов к старым. База данных выдает заказы
+// по курсору, поэтому курсор после каждой прочитанной страницы запоминается, и следующая страница
+// читается с него. Если курсора для offset нет, заказы читаются с ближайшей запомненной границы
+// перед offset, а до offset отбрасываются. Страница по запомненной границе продолжает выдачу
+// с того же заказа, даже если с тех пор добавились более новые заказы.
+func (d *DatabaseStore) GetOrdersPage(ctx context.Context, offset, limit int) ([]model.Order, error) {
+	orders := make([]model.Order, 0, max(limit, 0))
+	if offset < 0 || limit <= 0 {
+		return orders, nil
+	}
+	namespace, err := d.namespace.of(ctx)
+	if err != nil {
+		return nil, err
+	}
+	boundary := pageBoundary{namespace: namespace, tenant: database.TenantFromContext(ctx), offset: offset}
+	skipped, cursor := d.cursorBefore(boundary)
+	query := model.ListQuery{Limit: min(offset-skipped+limit, warmupPageSize), Cursor: cursor, Descending: true}
+	for len(orders) < limit {
+		page, err := d.dbService.ListOrders(ctx, query)
+		if err != nil {
+			d.logger.Error("Ошибка при получении заказов из базы данных", map[string]interface{}{"error": err})
+			return nil, err
+		}
+		for _, order := range page.Orders {
+			if skipped < offset {
+				skipped++
+				continue
+			}
+			if len(orders) < limit {
+				orders = append(orders, order)
+			}
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		query.Cursor = page.NextCursor
+		// Последняя страница заканчивается ровно на границе offset+limit, и ее курсор запоминается
+		query.Limit = min(offset-skipped+limit-len(orders), warmupPageSize)
+	}
+	if len(orders) == limit && query.Cursor != cursor {
+		boundary.offset += limit
+		d.rememberCursor(boundary, query.Cursor)
+	}
+	return orders, nil
+}
+
+// cursorBefore возвращает ближайшую запомненную границу страницы не дальше boundary и курсор,
+// с которого продолжается выдача после нее. Без запомненных границ выдача начинается с начала.
+func (d *DatabaseStore) cursorBefore(boundary pageBoundary) (int, string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if cursor, ok := d.cursors[boundary]; ok {
+		return boundary.offset, cursor
+	}
+	offset, cursor := 0, ""
+	for known, knownCursor := range d.cursors {
+		if known.namespace == boundary.namespace && known.tenant == boundary.tenant &&
+			known.offset <= boundary.offset && known.offset > offset {
+			offset, cursor = known.offset, knownCursor
+		}
+	}
+	return offset, cursor
+}
+
+// rememberCursor запоминает курсор, с которого продолжается выдача после границы boundary.
+// При переполнении запомненные границы забываются, и страницы снова читаются с начала выдачи.
+func (d *DatabaseStore) rememberCursor(boundary pageBoundary, cursor string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.cursors) >= maxPageCursors {
+		clear(d.cursors)
+	}
+	d.cursors[boundary] = cursor
+}
+
+// AddOrUpdateOrder ничего не делает: заказы записываются в базу данных ее сервисом.
+func (d *DatabaseStore) AddOrUpdateOrder(ctx context.Context, order *model.Order) error {
+	return nil
+}
+
+// DeleteOrder ничего не делает: заказы удаляются из базы данных ее сервисом.
+func (d *DatabaseStore) DeleteOrder(ctx context.Context, orderUID string) error {
+	return nil
+}
+
+// ApplyOrderEvent ничего не делает: изменения заказов уже записаны в базу данных.
+func (d *DatabaseStore) ApplyOrderEvent(ctx context.Context, event model.OrderEvent) error {
+	return nil
+}
+
+// Run ожидает завершения контекста: фоновой работы у хранилища нет.
+func (d *DatabaseStore) Run(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
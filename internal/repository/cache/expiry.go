@@ -0,0 +1,60 @@
+package cache
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ArtemZ007/wb-l0/internal/domain/model"
+)
+
+const minExpiry = time.Second // Минимальное время жизни заказа в кэше при ограниченном сроке хранения
+
+// ExpiryMode определяет, как вычисляется время жизни заказа в кэше.
+type ExpiryMode string
+
+const (
+	// ExpiryNone хранит заказы в кэше бессрочно.
+	ExpiryNone ExpiryMode = "none"
+	// ExpiryFixed хранит каждый заказ в течение фиксированного времени после записи в кэш.
+	ExpiryFixed ExpiryMode = "fixed"
+	// ExpiryAge хранит заказ, пока он моложе заданного возраста по date_created.
+	ExpiryAge ExpiryMode = "age"
+)
+
+// ParseExpiryMode разбирает режим времени жизни заказов из строки конфигурации.
+func ParseExpiryMode(value string) (ExpiryMode, error) {
+	switch mode := ExpiryMode(value); mode {
+	case ExpiryNone, ExpiryFixed, ExpiryAge:
+		return mode, nil
+	case "":
+		return ExpiryNone, nil
+	default:
+		return "", fmt.Errorf("неизвестный режим времени жизни заказов в кэше %q", value)
+	}
+}
+
+// ExpiryPolicy задает время жизни заказов в кэше и ограничение их числа.
+// Истекшие и вытесненные заказы остаются в индексе и при обращении загружаются из базы данных заново.
+type ExpiryPolicy struct {
+	Mode       ExpiryMode
+	TTL        time.Duration // Для ExpiryFixed — время жизни заказа; для ExpiryAge — время жизни заказа старше MaxAge
+	MaxAge     time.Duration // Для ExpiryAge — возраст заказа, после которого он хранится не дольше TTL
+	MaxEntries int           // Максимальное число заказов; при превышении вытесняются давно не использованные. 0 — без ограничения
+}
+
+// ttl возвращает время жизни заказа в кэше начиная с момента now; 0 означает бессрочное хранение.
+func (p ExpiryPolicy) ttl(order *model.Order, now time.Time) time.Duration {
+	var ttl time.Duration
+	switch p.Mode {
+	case ExpiryFixed:
+		ttl = p.TTL
+	case ExpiryAge:
+		ttl = p.TTL
+		if created, err := time.Parse(time.RFC3339Nano, order.DateCreated); err == nil {
+			ttl = max(created.Add(p.MaxAge).Sub(now), p.TTL)
+		}
+	default:
+		return 0
+	}
+	return max(ttl, minExpiry)
+}
@@ -0,0 +1,110 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	invalidationChannel = "orders:invalidate" // Канал Redis для инвалидации локальных кэшей экземпляров
+	resubscribeDelay    = time.Second         // Пауза перед повторной подпиской после ошибки
+)
+
+// invalidation описывает сообщение об изменении заказа, после которого другие экземпляры
+// должны удалить его из локального кэша.
+type invalidation struct {
+	Instance string `json:"instance"` // Экземпляр, изменивший заказ
+	Key      string `json:"key"`      // Ключ заказа в Redis, включающий пространство имен
+}
+
+// newInstanceID возвращает случайный идентификатор экземпляра сервиса.
+func newInstanceID() string {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return time.Now().Format("20060102150405.000000000")
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// SetLocalCache включает локальный кэш декодированных заказов перед Redis, ограниченный
+// maxEntries заказами и maxBytes байтами закодированных заказов. Нулевые ограничения отключают кэш.
+// Локальный кэш используется, только пока работает RunInvalidation.
+func (s *CacheService) SetLocalCache(maxEntries, maxBytes int) {
+	if maxEntries <= 0 && maxBytes <= 0 {
+		s.local = nil
+		return
+	}
+	s.local = newLRU(maxEntries, maxBytes)
+}
+
+// localCache возвращает локальный кэш, если он включен и подписка на инвалидацию активна.
+// Без подписки изменения других экземпляров не видны, поэтому чтение идет из Redis.
+func (s *CacheService) localCache() *lru {
+	if s.local == nil || !s.subscribed.Load() {
+		return nil
+	}
+	return s.local
+}
+
+// RunInvalidation подписывается на сообщения об изменении заказов другими экземплярами
+// и удаляет такие заказы из локального кэша. Блокирует выполнение до завершения контекста.
+// При потере подписки локальный кэш очищается, так как сообщения за это время могли быть пропущены.
+func (s *CacheService) RunInvalidation(ctx context.Context) error {
+	if s.local == nil {
+		return nil
+	}
+
+	pubsub := s.client.Subscribe(ctx, invalidationChannel)
+	stop := context.AfterFunc(ctx, func() { _ = pubsub.Close() })
+	defer stop()
+	defer s.subscribed.Store(false)
+
+	for {
+		msg, err := pubsub.Receive(ctx)
+		if err != nil {
+			if s.subscribed.Swap(false) {
+				s.local.clear()
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			s.logger.Warn("Подписка на инвалидацию локального кэша прервана", map[string]interface{}{"error": err})
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(resubscribeDelay):
+			}
+			continue
+		}
+
+		switch m := msg.(type) {
+		case *redis.Subscription:
+			if m.Kind == "subscribe" && !s.subscribed.Load() {
+				s.local.clear()
+				s.subscribed.Store(true)
+				s.logger.Info("Подписка на инвалидацию локального кэша активна", map[string]interface{}{"instance": s.instanceID})
+			}
+		case *redis.Message:
+			var inv invalidation
+			if err := json.Unmarshal([]byte(m.Payload), &inv); err != nil {
+				s.logger.Warn("Некорректное сообщение инвалидации", map[string]interface{}{"error": err})
+				continue
+			}
+			if inv.Instance != s.instanceID {
+				s.local.remove(inv.Key)
+				s.loader.forget(inv.Key)
+			}
+		}
+	}
+}
+
+// publishInvalidation добавляет в транзакцию pipe сообщение об изменении заказа с ключом key для других экземпляров.
+func (s *CacheService) publishInvalidation(ctx context.Context, pipe redis.Pipeliner, key string) {
+	data, _ := json.Marshal(invalidation{Instance: s.instanceID, Key: key})
+	pipe.Publish(ctx, invalidationChannel, data)
+}
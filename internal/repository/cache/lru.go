@@ -0,0 +1,108 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/ArtemZ007/wb-l0/internal/domain/model"
+)
+
+// lruEntry описывает заказ в локальном кэше.
+type lruEntry struct {
+	key   string // Ключ заказа в Redis
+	order *model.Order
+	size  int // Размер закодированного заказа в байтах, по которому учитывается занятая память
+}
+
+// lru — ограниченный по числу заказов и объему кэш декодированных заказов в памяти процесса.
+// При превышении любого из ограничений вытесняются давно не использованные заказы.
+// Заказы попадают в кэш только при чтении из Redis; любое изменение заказа удаляет его из кэша.
+type lru struct {
+	mu         sync.Mutex
+	maxEntries int
+	maxBytes   int
+	bytes      int
+	order      *list.List // От недавно использованных заказов к давно не использованным
+	items      map[string]*list.Element
+	epoch      uint64 // Увеличивается при каждой инвалидации
+}
+
+// newLRU создает локальный кэш. Нулевое ограничение не действует.
+func newLRU(maxEntries, maxBytes int) *lru {
+	return &lru{
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		order:      list.New(),
+		items:      make(map[string]*list.Element),
+	}
+}
+
+// get возвращает заказ и отмечает его как недавно использованный.
+func (c *lru) get(key string) (*model.Order, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*lruEntry).order, true
+}
+
+// currentEpoch возвращает номер текущей инвалидации. Его нужно получить до чтения заказа из Redis
+// и передать в add, чтобы не закэшировать заказ, измененный во время чтения.
+func (c *lru) currentEpoch() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.epoch
+}
+
+// add добавляет или заменяет заказ, прочитанный из Redis в эпоху epoch, и вытесняет лишние заказы.
+// Если с тех пор произошла инвалидация, заказ мог устареть и не кэшируется.
+// Заказ, который больше ограничения по объему, также не кэшируется.
+func (c *lru) add(key string, order *model.Order, size int, epoch uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if epoch != c.epoch {
+		return
+	}
+	if el, ok := c.items[key]; ok {
+		c.removeElement(el)
+	}
+	if c.maxBytes > 0 && size > c.maxBytes {
+		return
+	}
+
+	c.items[key] = c.order.PushFront(&lruEntry{key: key, order: order, size: size})
+	c.bytes += size
+	for (c.maxEntries > 0 && c.order.Len() > c.maxEntries) || (c.maxBytes > 0 && c.bytes > c.maxBytes) {
+		c.removeElement(c.order.Back())
+	}
+}
+
+// remove удаляет заказ из локального кэша.
+func (c *lru) remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.epoch++
+	if el, ok := c.items[key]; ok {
+		c.removeElement(el)
+	}
+}
+
+// clear удаляет все заказы из локального кэша.
+func (c *lru) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.epoch++
+	c.order.Init()
+	c.items = make(map[string]*list.Element)
+	c.bytes = 0
+}
+
+// removeElement удаляет элемент списка. Вызывается под блокировкой.
+func (c *lru) removeElement(el *list.Element) {
+	entry := c.order.Remove(el).(*lruEntry)
+	delete(c.items, entry.key)
+	c.bytes -= entry.size
+}
@@ -0,0 +1,517 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ArtemZ007/wb-l0/internal/domain/model"
+	"github.com/ArtemZ007/wb-l0/pkg/logger"
+)
+
+const (
+	defaultSnapshotInterval = 5 * time.Minute // Периодичность снимков по умолчанию
+	expirySweepInterval     = time.Minute     // Периодичность удаления истекших заказов из памяти
+)
+
+// memorySpace содержит заказы одного пространства имен кэша в памяти процесса.
+type memorySpace struct {
+	orders map[string]*memoryEntry
+	index  []indexKey // Идентификаторы заказов от новых к старым
+}
+
+// memoryEntry описывает заказ в кэше в памяти процесса.
+type memoryEntry struct {
+	order     *model.Order  // nil, если заказ истек или вытеснен и при обращении загружается из базы данных
+	score     float64       // Дата создания в миллисекундах, по которой упорядочен индекс
+	expiresAt time.Time     // Время истечения заказа; нулевое — бессрочно
+	element   *list.Element // Позиция загруженного заказа в списке использования
+}
+
+// loaded сообщает, загружен ли заказ и не истек ли он к моменту now.
+func (e *memoryEntry) loaded(now time.Time) bool {
+	return e.order != nil && (e.expiresAt.IsZero() || now.Before(e.expiresAt))
+}
+
+// indexKey — позиция заказа в индексе, упорядоченном от новых заказов к старым.
+type indexKey struct {
+	score    float64
+	orderUID string
+}
+
+// before сообщает, что заказ k в индексе стоит раньше заказа other.
+func (k indexKey) before(other indexKey) bool {
+	if k.score != other.score {
+		return k.score > other.score
+	}
+	return k.orderUID > other.orderUID
+}
+
+// snapshotOrder описывает заказ в файле снимка кэша вместе с его пространством имен.
+type snapshotOrder struct {
+	Namespace string `json:"namespace,omitempty"` // Пространство имен; пустое в снимках прежних версий
+	model.Order
+}
+
+// snapshotFile описывает содержимое файла снимка кэша.
+type snapshotFile struct {
+	SavedAt time.Time       `json:"saved_at"` // Время создания снимка
+	Orders  []snapshotOrder `json:"orders"`   // Заказы кэша
+}
+
+// MemoryCache — кэш заказов в памяти процесса, не требующий Redis.
+// Содержимое периодически и при завершении работы сохраняется в файл снимка,
+// из которого кэш восстанавливается при следующем запуске.
+// Заказы, возвращаемые кэшем, общие для всех вызывающих и не должны изменяться.
+type MemoryCache struct {
+	orderWriter
+	mu               sync.Mutex
+	spaces           map[string]*memorySpace // Заказы по пространствам имен
+	recent           *list.List              // Загруженные заказы всех пространств имен от недавно использованных к давно не использованным
+	expiry           ExpiryPolicy
+	loader           *loader // Загрузчик отсутствующих в памяти заказов из базы данных
+	dbService        OrderService
+	namespace        NamespaceFunc // Пространство имен для контекста запроса
+	snapshotPath     string
+	snapshotInterval time.Duration
+	snapshotMaxAge   time.Duration
+	logger           logger.Logger
+}
+
+// NewMemoryCache создает пустой кэш заказов в памяти процесса.
+func NewMemoryCache(logger logger.Logger) *MemoryCache {
+	return &MemoryCache{
+		spaces: make(map[string]*memorySpace),
+		recent: list.New(),
+		loader: newLoader(),
+		logger: logger,
+	}
+}
+
+// SetSnapshot задает файл снимка кэша и периодичность его записи.
+// Снимок старше maxAge не восстанавливается; нулевой maxAge снимает ограничение.
+// Пустой path отключает снимки.
+func (c *MemoryCache) SetSnapshot(path string, interval, maxAge time.Duration) {
+	if interval <= 0 {
+		interval = defaultSnapshotInterval
+	}
+	c.snapshotPath, c.snapshotInterval, c.snapshotMaxAge = path, interval, maxAge
+}
+
+// SetDBService устанавливает сервис базы данных, реализующий интерфейс OrderService.
+func (c *MemoryCache) SetDBService(dbService OrderService) {
+	c.dbService = dbService
+}
+
+// SetNamespace задает пространство имен кэша для контекста запроса.
+func (c *MemoryCache) SetNamespace(namespace NamespaceFunc) {
+	c.namespace = namespace
+}
+
+// space возвращает заказы пространства имен namespace, создавая его при необходимости.
+// Вызывается под блокировкой.
+func (c *MemoryCache) space(namespace string) *memorySpace {
+	space, ok := c.spaces[namespace]
+	if !ok {
+		space = &memorySpace{orders: make(map[string]*memoryEntry)}
+		c.spaces[namespace] = space
+	}
+	return space
+}
+
+// SetExpiryPolicy задает время жизни заказов в памяти и ограничение их числа.
+func (c *MemoryCache) SetExpiryPolicy(policy ExpiryPolicy) {
+	c.expiry = policy
+}
+
+// SetNegativeTTL задает, сколько помнить об отсутствии заказа в базе данных; 0 отключает запоминание.
+func (c *MemoryCache) SetNegativeTTL(ttl time.Duration) {
+	c.loader.setNegativeTTL(ttl)
+}
+
+// InitCacheWithDBOrders инициализирует кэш заказами из базы данных, загружая их постранично.
+// Ход прогрева отражается в progress, если он задан.
+func (c *MemoryCache) InitCacheWithDBOrders(ctx context.Context, progress *WarmupProgress) error {
+	return loadOrders(ctx, c.dbService, c.addOrders, progress, c.logger)
+}
+
+// GetOrder извлекает заказ из кэша по его уникальному идентификатору.
+// Отсутствующий в памяти заказ загружается из базы данных, а если его нет и там, возвращается ErrOrderNotFound.
+func (c *MemoryCache) GetOrder(ctx context.Context, orderUID string) (*model.Order, error) {
+	namespace, err := c.namespace.of(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	order := c.getLocked(c.space(namespace), orderUID, time.Now())
+	c.mu.Unlock()
+	if order != nil {
+		return order, nil
+	}
+
+	order, err = c.reload(ctx, namespace, orderUID)
+	if err != nil {
+		return nil, err
+	}
+	if order == nil {
+		return nil, notFound(orderUID)
+	}
+	return order, nil
+}
+
+// getLocked возвращает загруженный заказ и отмечает его как недавно использованный.
+// Истекший заказ выгружается. Вызывается под блокировкой.
+func (c *MemoryCache) getLocked(space *memorySpace, orderUID string, now time.Time) *model.Order {
+	entry, ok := space.orders[orderUID]
+	if !ok {
+		return nil
+	}
+	if !entry.loaded(now) {
+		c.unload(entry)
+		return nil
+	}
+	c.recent.MoveToFront(entry.element)
+	return entry.order
+}
+
+// GetAllOrderIDs возвращает идентификаторы всех загруженных заказов в кэше пространства имен контекста ctx.
+func (c *MemoryCache) GetAllOrderIDs(ctx context.Context) ([]string, error) {
+	namespace, err := c.namespace.of(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	space := c.space(namespace)
+	ids := make([]string, 0, len(space.index))
+	for _, key := range space.index {
+		if space.orders[key.orderUID].loaded(now) {
+			ids = append(ids, key.orderUID)
+		}
+	}
+	return ids, nil
+}
+
+// GetOrderIDs возвращает страницу идентификаторов заказов из индекса, от новых заказов к старым.
+func (c *MemoryCache) GetOrderIDs(ctx context.Context, offset, limit int) ([]string, error) {
+	namespace, err := c.namespace.of(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	keys := pageOf(c.space(namespace).index, offset, limit)
+	ids := make([]string, len(keys))
+	for i, key := range keys {
+		ids[i] = key.orderUID
+	}
+	return ids, nil
+}
+
+// GetOrders возвращает заказы по идентификаторам в том же порядке; на месте заказов, которых нет
+// ни в кэше, ни в базе данных, возвращается nil. Истекшие и вытесненные заказы загружаются из базы данных.
+// Если часть заказов получить не удалось, вместе с остальными возвращается *PartialError.
+func (c *MemoryCache) GetOrders(ctx context.Context, orderUIDs []string) ([]*model.Order, error) {
+	namespace, err := c.namespace.of(ctx)
+	if err != nil {
+		return nil, err
+	}
+	found := make([]*model.Order, len(orderUIDs))
+	c.mu.Lock()
+	now := time.Now()
+	space := c.space(namespace)
+	for i, orderUID := range orderUIDs {
+		found[i] = c.getLocked(space, orderUID, now)
+	}
+	c.mu.Unlock()
+
+	partial := &PartialError{}
+	for i, order := range found {
+		if order != nil {
+			continue
+		}
+		order, err := c.reload(ctx, namespace, orderUIDs[i])
+		if err != nil {
+			partial.add(orderUIDs[i], err)
+			continue
+		}
+		found[i] = order
+	}
+	return found, partial.errOrNil()
+}
+
+// GetOrdersPage возвращает страницу заказов из кэша, от новых заказов к старым, получая их через GetOrders.
+// Если часть заказов получить не удалось, вместе с остальными возвращается *PartialError.
+func (c *MemoryCache) GetOrdersPage(ctx context.Context, offset, limit int) ([]model.Order, error) {
+	return ordersPage(ctx, c, offset, limit)
+}
+
+// AddOrUpdateOrder добавляет или обновляет заказ в кэше.
+func (c *MemoryCache) AddOrUpdateOrder(ctx context.Context, order *model.Order) error {
+	return c.addOrders(ctx, []model.Order{*order})
+}
+
+// addOrders добавляет или обновляет пакет заказов под одной блокировкой.
+func (c *MemoryCache) addOrders(ctx context.Context, orders []model.Order) error {
+	namespace, err := c.namespace.of(ctx)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	now := time.Now()
+	space := c.space(namespace)
+	keys := newKeyspace(namespace)
+	for i := range orders {
+		c.put(space, &orders[i], now)
+		c.loader.forget(keys.order(orders[i].OrderUID))
+	}
+	c.mu.Unlock()
+	return nil
+}
+
+// put добавляет или заменяет заказ в пространстве имен space и вытесняет давно не использованные
+// заказы всех пространств имен сверх ограничения. Вызывается под блокировкой.
+func (c *MemoryCache) put(space *memorySpace, order *model.Order, now time.Time) {
+	c.removeLocked(space, order.OrderUID)
+	key := indexKey{score: orderScore(order), orderUID: order.OrderUID}
+	i := sort.Search(len(space.index), func(i int) bool { return !space.index[i].before(key) })
+	space.index = append(space.index, indexKey{})
+	copy(space.index[i+1:], space.index[i:])
+	space.index[i] = key
+
+	entry := &memoryEntry{order: order, score: key.score}
+	entry.element = c.recent.PushFront(entry)
+	if ttl := c.expiry.ttl(order, now); ttl > 0 {
+		entry.expiresAt = now.Add(ttl)
+	}
+	space.orders[order.OrderUID] = entry
+
+	for c.expiry.MaxEntries > 0 && c.recent.Len() > c.expiry.MaxEntries {
+		c.unload(c.recent.Back().Value.(*memoryEntry))
+	}
+}
+
+// unload выгружает заказ, оставляя его в индексе. Вызывается под блокировкой.
+func (c *MemoryCache) unload(entry *memoryEntry) {
+	entry.order = nil
+	if entry.element != nil {
+		c.recent.Remove(entry.element)
+		entry.element = nil
+	}
+}
+
+// reload загружает отсутствующий в памяти заказ пространства имен namespace из базы данных и кэширует его.
+// Одновременные загрузки одного заказа объединяются в один запрос к базе.
+// Если заказа нет в базе, он удаляется из индекса и возвращается nil без ошибки.
+// Кэш меняется, только если заказ не изменялся во время загрузки (см. fillOrder).
+func (c *MemoryCache) reload(ctx context.Context, namespace, orderUID string) (*model.Order, error) {
+	return c.loader.load(ctx, newKeyspace(namespace).order(orderUID), func(ctx context.Context) (*model.Order, error) {
+		order, err := reloadOrder(ctx, c.dbService, orderUID)
+		if err != nil {
+			c.logger.Error("Ошибка при загрузке заказа из базы данных", map[string]interface{}{"error": err})
+			return nil, err
+		}
+		if order != nil || c.dbService != nil {
+			c.fillOrder(namespace, orderUID, order)
+		}
+		return order, nil
+	})
+}
+
+// fillOrder кэширует заказ orderUID, загруженный из базы данных, или, если order равен nil,
+// удаляет отсутствующий в базе заказ из индекса. Кэш меняется, только если заказ по-прежнему
+// не загружен в память и не изменялся во время загрузки: иначе событие outbox уже записало
+// или удалило заказ после его чтения из базы, и загруженное состояние устарело.
+func (c *MemoryCache) fillOrder(namespace, orderUID string, order *model.Order) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.loader.superseded(newKeyspace(namespace).order(orderUID)) {
+		return
+	}
+	space := c.space(namespace)
+	now := time.Now()
+	if entry, ok := space.orders[orderUID]; ok && entry.loaded(now) {
+		return
+	}
+	if order == nil {
+		c.removeLocked(space, orderUID)
+		return
+	}
+	c.put(space, order, now)
+}
+
+// DeleteOrder удаляет заказ из кэша.
+func (c *MemoryCache) DeleteOrder(ctx context.Context, orderUID string) error {
+	namespace, err := c.namespace.of(ctx)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.removeLocked(c.space(namespace), orderUID)
+	c.loader.forget(newKeyspace(namespace).order(orderUID))
+	return nil
+}
+
+// removeLocked удаляет заказ и его позицию в индексе пространства имен space. Вызывается под блокировкой записи.
+func (c *MemoryCache) removeLocked(space *memorySpace, orderUID string) {
+	entry, ok := space.orders[orderUID]
+	if !ok {
+		return
+	}
+	c.unload(entry)
+	delete(space.orders, orderUID)
+	key := indexKey{score: entry.score, orderUID: orderUID}
+	i := sort.Search(len(space.index), func(i int) bool { return !space.index[i].before(key) })
+	if i < len(space.index) && space.index[i] == key {
+		space.index = append(space.index[:i], space.index[i+1:]...)
+	}
+}
+
+// ApplyOrderEvent применяет событие об изменении заказа из outbox к кэшу.
+func (c *MemoryCache) ApplyOrderEvent(ctx context.Context, event model.OrderEvent) error {
+	return applyOrderEvent(ctx, c, event, c.logger)
+}
+
+// Run периодически сохраняет снимок кэша и выгружает истекшие заказы из памяти.
+// При завершении контекста снимок сохраняется последний раз.
+func (c *MemoryCache) Run(ctx context.Context) error {
+	var snapshots, sweeps <-chan time.Time
+	if c.snapshotPath != "" {
+		ticker := time.NewTicker(c.snapshotInterval)
+		defer ticker.Stop()
+		snapshots = ticker.C
+	}
+	if c.expiry.Mode == ExpiryFixed || c.expiry.Mode == ExpiryAge {
+		ticker := time.NewTicker(expirySweepInterval)
+		defer ticker.Stop()
+		sweeps = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			if err := c.SaveSnapshot(); err != nil {
+				return err
+			}
+			return ctx.Err()
+		case <-snapshots:
+			if err := c.SaveSnapshot(); err != nil {
+				c.logger.Error("Ошибка при сохранении снимка кэша", map[string]interface{}{"error": err})
+			}
+		case <-sweeps:
+			c.sweep()
+		}
+	}
+}
+
+// sweep выгружает из памяти истекшие заказы, к которым не было обращений.
+func (c *MemoryCache) sweep() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for _, space := range c.spaces {
+		for _, entry := range space.orders {
+			if entry.order != nil && !entry.loaded(now) {
+				c.unload(entry)
+			}
+		}
+	}
+}
+
+// SaveSnapshot атомарно записывает загруженные заказы кэша в файл снимка:
+// сначала во временный файл, затем переименованием поверх прежнего снимка.
+func (c *MemoryCache) SaveSnapshot() error {
+	if c.snapshotPath == "" {
+		return nil
+	}
+
+	c.mu.Lock()
+	now := time.Now()
+	snapshot := snapshotFile{SavedAt: now.UTC(), Orders: make([]snapshotOrder, 0, c.recent.Len())}
+	for namespace, space := range c.spaces {
+		for _, key := range space.index {
+			if entry := space.orders[key.orderUID]; entry.loaded(now) {
+				snapshot.Orders = append(snapshot.Orders, snapshotOrder{Namespace: namespace, Order: *entry.order})
+			}
+		}
+	}
+	c.mu.Unlock()
+
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("ошибка при сериализации снимка кэша: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(c.snapshotPath), filepath.Base(c.snapshotPath)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("ошибка при создании файла снимка кэша: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("ошибка при записи снимка кэша: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("ошибка при записи снимка кэша: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), c.snapshotPath); err != nil {
+		return fmt.Errorf("ошибка при замене снимка кэша: %w", err)
+	}
+
+	c.logger.Debug("Снимок кэша сохранен", map[string]interface{}{"count": len(snapshot.Orders)})
+	return nil
+}
+
+// RestoreSnapshot загружает заказы из файла снимка. Возвращает false, если снимка нет
+// или он старше допустимого возраста; тогда кэш нужно заполнить из базы данных.
+func (c *MemoryCache) RestoreSnapshot() (bool, error) {
+	if c.snapshotPath == "" {
+		return false, nil
+	}
+	data, err := os.ReadFile(c.snapshotPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("ошибка при чтении снимка кэша: %w", err)
+	}
+
+	var snapshot snapshotFile
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return false, fmt.Errorf("ошибка при разборе снимка кэша: %w", err)
+	}
+	if age := time.Since(snapshot.SavedAt); c.snapshotMaxAge > 0 && age > c.snapshotMaxAge {
+		c.logger.Warn("Снимок кэша устарел и не восстановлен", map[string]interface{}{"age": age.Round(time.Second).String()})
+		return false, nil
+	}
+
+	c.mu.Lock()
+	now := time.Now()
+	for i := range snapshot.Orders {
+		c.put(c.space(snapshot.Orders[i].Namespace), &snapshot.Orders[i].Order, now)
+	}
+	c.mu.Unlock()
+
+	c.logger.Info("Кэш восстановлен из снимка", map[string]interface{}{"count": len(snapshot.Orders), "saved_at": snapshot.SavedAt})
+	return true, nil
+}
+
+// pageOf возвращает часть индекса, начиная с offset, длиной не более limit.
+func pageOf(index []indexKey, offset, limit int) []indexKey {
+	if offset < 0 || limit <= 0 || offset >= len(index) {
+		return nil
+	}
+	index = index[offset:]
+	if limit < len(index) {
+		index = index[:limit]
+	}
+	return index
+}
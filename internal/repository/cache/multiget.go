@@ -0,0 +1,166 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ArtemZ007/wb-l0/internal/domain/model"
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	multiGetBatchSize   = 500 // Число ключей в одной команде MGET
+	maxReportedFailures = 5   // Число заказов, перечисляемых в тексте PartialError
+)
+
+// FailedOrder описывает заказ, который не удалось получить при пакетном чтении.
+type FailedOrder struct {
+	OrderUID string
+	Err      error
+}
+
+// PartialError возвращается пакетным чтением заказов, если часть заказов получить не удалось.
+// Остальные заказы при этом возвращаются вместе с ошибкой.
+type PartialError struct {
+	Failed []FailedOrder
+}
+
+// Error перечисляет первые заказы, которые не удалось получить.
+func (e *PartialError) Error() string {
+	parts := make([]string, 0, maxReportedFailures)
+	for _, failed := range e.Failed[:min(len(e.Failed), maxReportedFailures)] {
+		parts = append(parts, fmt.Sprintf("%s: %v", failed.OrderUID, failed.Err))
+	}
+	return fmt.Sprintf("не удалось получить заказов: %d (%s)", len(e.Failed), strings.Join(parts, "; "))
+}
+
+// Unwrap возвращает ошибки получения отдельных заказов.
+func (e *PartialError) Unwrap() []error {
+	errs := make([]error, len(e.Failed))
+	for i, failed := range e.Failed {
+		errs[i] = failed.Err
+	}
+	return errs
+}
+
+// add учитывает заказ, который не удалось получить.
+func (e *PartialError) add(orderUID string, err error) {
+	e.Failed = append(e.Failed, FailedOrder{OrderUID: orderUID, Err: err})
+}
+
+// errOrNil возвращает e, если хотя бы один заказ не удалось получить, и nil в противном случае.
+func (e *PartialError) errOrNil() error {
+	if len(e.Failed) == 0 {
+		return nil
+	}
+	return e
+}
+
+// compactOrders возвращает найденные заказы из результата пакетного чтения, сохраняя порядок.
+func compactOrders(found []*model.Order) []model.Order {
+	orders := make([]model.Order, 0, len(found))
+	for _, order := range found {
+		if order != nil {
+			orders = append(orders, *order)
+		}
+	}
+	return orders
+}
+
+// ordersPage возвращает страницу заказов из кэша c, получая их одним пакетным чтением.
+// Если часть заказов получить не удалось, вместе с остальными возвращается *PartialError.
+func ordersPage(ctx context.Context, c OrderStore, offset, limit int) ([]model.Order, error) {
+	ids, err := c.GetOrderIDs(ctx, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	found, err := c.GetOrders(ctx, ids)
+	var partial *PartialError
+	if err != nil && !errors.As(err, &partial) {
+		return nil, err
+	}
+	return compactOrders(found), err
+}
+
+// GetOrders возвращает заказы по идентификаторам в том же порядке; на месте заказов, которых нет
+// ни в кэше, ни в базе данных, возвращается nil. Заказы из локального кэша не запрашиваются из Redis,
+// остальные запрашиваются командами MGET по multiGetBatchSize ключей, отправляемыми одним конвейером.
+// Истекшие, вытесненные и не декодированные заказы загружаются из базы данных.
+// Если часть заказов получить не удалось, вместе с остальными возвращается *PartialError.
+func (s *CacheService) GetOrders(ctx context.Context, orderUIDs []string) ([]*model.Order, error) {
+	keys, err := s.keyspace(ctx)
+	if err != nil {
+		return nil, err
+	}
+	found := make([]*model.Order, len(orderUIDs))
+	local := s.localCache()
+	var epoch uint64
+	pending := make([]int, 0, len(orderUIDs)) // Позиции заказов, запрашиваемых из Redis
+	if local != nil {
+		epoch = local.currentEpoch()
+	}
+	for i, orderUID := range orderUIDs {
+		if local != nil {
+			if order, ok := local.get(keys.order(orderUID)); ok {
+				found[i] = order
+				continue
+			}
+		}
+		pending = append(pending, i)
+	}
+	if len(pending) == 0 {
+		return found, nil
+	}
+
+	batches := make([]*redis.SliceCmd, 0, (len(pending)+multiGetBatchSize-1)/multiGetBatchSize)
+	_, err = s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for start := 0; start < len(pending); start += multiGetBatchSize {
+			batch := pending[start:min(start+multiGetBatchSize, len(pending))]
+			batchKeys := make([]string, len(batch))
+			for j, i := range batch {
+				batchKeys[j] = keys.order(orderUIDs[i])
+			}
+			batches = append(batches, pipe.MGet(ctx, batchKeys...))
+		}
+		return nil
+	})
+	if err != nil {
+		s.logger.Error("Ошибка при получении заказов из Redis", map[string]interface{}{"error": err})
+		return nil, err
+	}
+
+	partial := &PartialError{}
+	touched := make([]string, 0, len(pending))
+	for b, cmd := range batches {
+		for j, value := range cmd.Val() {
+			i := pending[b*multiGetBatchSize+j]
+			orderUID := orderUIDs[i]
+			var undecoded []byte
+			if data, ok := value.(string); ok {
+				var order model.Order
+				err := s.envelope.decode([]byte(data), &order)
+				if err == nil {
+					found[i] = &order
+					touched = append(touched, keys.order(orderUID))
+					if local != nil {
+						local.add(keys.order(orderUID), &order, len(data), epoch)
+					}
+					continue
+				}
+				s.logger.Warn("Ошибка при декодировании заказа из Redis, заказ будет загружен заново",
+					map[string]interface{}{"error": err, "orderUID": orderUID})
+				undecoded = []byte(data)
+			}
+			order, err := s.reload(ctx, keys, orderUID, undecoded)
+			if err != nil {
+				partial.add(orderUID, err)
+				continue
+			}
+			found[i] = order
+		}
+	}
+	s.touch(ctx, touched...)
+	return found, partial.errOrNil()
+}
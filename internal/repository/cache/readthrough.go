@@ -0,0 +1,163 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ArtemZ007/wb-l0/internal/domain/model"
+	"github.com/ArtemZ007/wb-l0/internal/repository/database"
+)
+
+const (
+	maxMissingEntries = 100000           // Максимальное число запомненных отсутствующих заказов
+	loadTimeout       = 30 * time.Second // Предельная длительность общей загрузки заказа из базы данных
+)
+
+// ErrOrderNotFound возвращается, когда заказа нет ни в кэше, ни в базе данных.
+var ErrOrderNotFound = errors.New("заказ не найден")
+
+// loadCall описывает выполняющуюся загрузку заказа, результат которой ждут все запросившие его.
+type loadCall struct {
+	done  chan struct{}
+	order *model.Order
+	err   error
+	stale bool // Заказ изменился во время загрузки, и загруженный заказ нельзя записывать в кэш
+}
+
+// loader загружает отсутствующие в кэше заказы из базы данных. Одновременные загрузки
+// одного заказа объединяются в один запрос к базе, а отсутствие заказа в базе запоминается
+// на negativeTTL, чтобы повторные запросы несуществующих заказов не нагружали базу.
+// Заказы обозначаются ключами, включающими пространство имен кэша (см. keyspace.order),
+// чтобы заказы разных схем арендаторов с одинаковыми идентификаторами не смешивались.
+type loader struct {
+	mu          sync.Mutex
+	calls       map[string]*loadCall
+	missing     map[string]time.Time // Время, до которого заказ считается отсутствующим
+	negativeTTL time.Duration
+}
+
+// newLoader создает загрузчик заказов без запоминания отсутствующих заказов.
+func newLoader() *loader {
+	return &loader{
+		calls:   make(map[string]*loadCall),
+		missing: make(map[string]time.Time),
+	}
+}
+
+// setNegativeTTL задает, сколько помнить об отсутствии заказа в базе; 0 отключает запоминание.
+func (l *loader) setNegativeTTL(ttl time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.negativeTTL = ttl
+	clear(l.missing)
+}
+
+// load возвращает результат fn для заказа с ключом key. Пока fn выполняется, остальные вызовы
+// для того же заказа ждут ее результат. Если fn вернула nil без ошибки и заказ не изменился
+// во время загрузки, заказ запоминается как отсутствующий, и до истечения negativeTTL load
+// возвращает nil без вызова fn.
+// Загрузка общая для всех ожидающих, поэтому fn получает контекст, который не отменяется вместе с ctx
+// первого вызова и ограничен loadTimeout; каждый вызов прекращает ожидание при отмене своего ctx.
+func (l *loader) load(ctx context.Context, key string, fn func(ctx context.Context) (*model.Order, error)) (*model.Order, error) {
+	l.mu.Lock()
+	if until, ok := l.missing[key]; ok {
+		if time.Now().Before(until) {
+			l.mu.Unlock()
+			return nil, nil
+		}
+		delete(l.missing, key)
+	}
+	call, ok := l.calls[key]
+	if !ok {
+		call = &loadCall{done: make(chan struct{})}
+		l.calls[key] = call
+		go l.run(ctx, key, call, fn)
+	}
+	l.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.order, call.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// run выполняет общую загрузку call заказа с ключом key и сообщает ее результат ожидающим.
+// Контекст загрузки сохраняет значения ctx, например арендатора, но не его отмену.
+func (l *loader) run(ctx context.Context, key string, call *loadCall, fn func(ctx context.Context) (*model.Order, error)) {
+	loadCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), loadTimeout)
+	defer cancel()
+	call.order, call.err = fn(loadCtx)
+
+	l.mu.Lock()
+	delete(l.calls, key)
+	if call.order == nil && call.err == nil && !call.stale {
+		l.rememberMissing(key)
+	}
+	l.mu.Unlock()
+	close(call.done)
+}
+
+// rememberMissing запоминает отсутствие заказа в базе. Вызывается под блокировкой.
+// При переполнении сначала удаляются истекшие записи, а если их нет, заказ не запоминается.
+func (l *loader) rememberMissing(key string) {
+	if l.negativeTTL <= 0 {
+		return
+	}
+	now := time.Now()
+	if len(l.missing) >= maxMissingEntries {
+		for missing, until := range l.missing {
+			if !now.Before(until) {
+				delete(l.missing, missing)
+			}
+		}
+		if len(l.missing) >= maxMissingEntries {
+			return
+		}
+	}
+	l.missing[key] = now.Add(l.negativeTTL)
+}
+
+// forget удаляет заказ из запомненных отсутствующих, например когда он появился в кэше.
+// Выполняющаяся загрузка заказа отмечается устаревшей: прочитанный ею заказ мог измениться.
+func (l *loader) forget(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.missing, key)
+	if call, ok := l.calls[key]; ok {
+		call.stale = true
+	}
+}
+
+// superseded сообщает, что заказ с ключом key изменился во время его загрузки. Вызывается из функции
+// загрузки перед записью заказа в кэш.
+func (l *loader) superseded(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	call, ok := l.calls[key]
+	return ok && call.stale
+}
+
+// reloadOrder загружает заказ, отсутствующий в кэше, из базы данных.
+// Заказ читается с основной базы: заказ с отстающей реплики мог бы оказаться в кэше устаревшим,
+// а отсутствие на ней нового заказа привело бы к его удалению из индекса.
+// Возвращает nil без ошибки, если заказа нет в базе или сервис базы данных не задан.
+func reloadOrder(ctx context.Context, dbService OrderService, orderUID string) (*model.Order, error) {
+	if dbService == nil {
+		return nil, nil
+	}
+	order, err := dbService.GetOrder(database.WithPrimary(ctx), orderUID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при загрузке заказа %s из базы данных: %w", orderUID, err)
+	}
+	return order, nil
+}
+
+// notFound возвращает ошибку об отсутствии заказа orderUID.
+func notFound(orderUID string) error {
+	return fmt.Errorf("%w: %s", ErrOrderNotFound, orderUID)
+}
@@ -0,0 +1,149 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ArtemZ007/wb-l0/internal/domain/model"
+)
+
+func TestLoaderCoalescesConcurrentLoads(t *testing.T) {
+	l := newLoader()
+	release := make(chan struct{})
+	var calls atomic.Int32
+	fn := func(ctx context.Context) (*model.Order, error) {
+		calls.Add(1)
+		<-release
+		return &model.Order{OrderUID: "a"}, nil
+	}
+
+	const waiters = 10
+	var wg sync.WaitGroup
+	results := make([]*model.Order, waiters)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			order, err := l.load(context.Background(), "order:a", fn)
+			if err != nil {
+				t.Error(err)
+			}
+			results[i] = order
+		}()
+	}
+	// Ждем, пока все вызовы присоединятся к загрузке
+	for calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("заказ загружен %d раз вместо одного", n)
+	}
+	for i, order := range results {
+		if order == nil || order != results[0] {
+			t.Fatalf("вызов %d получил другой результат: %+v", i, order)
+		}
+	}
+}
+
+func TestLoaderWaiterCancellation(t *testing.T) {
+	l := newLoader()
+	release := make(chan struct{})
+	defer close(release)
+	fn := func(ctx context.Context) (*model.Order, error) {
+		<-release
+		return nil, ctx.Err()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := l.load(ctx, "order:a", fn); !errors.Is(err, context.Canceled) {
+		t.Fatalf("ожидалась отмена ожидания, получено %v", err)
+	}
+}
+
+func TestLoaderNegativeCache(t *testing.T) {
+	l := newLoader()
+	l.setNegativeTTL(50 * time.Millisecond)
+	var calls atomic.Int32
+	missing := func(ctx context.Context) (*model.Order, error) {
+		calls.Add(1)
+		return nil, nil
+	}
+
+	for i := 0; i < 3; i++ {
+		if order, err := l.load(context.Background(), "order:a", missing); order != nil || err != nil {
+			t.Fatalf("ожидалось отсутствие заказа, получено %+v, %v", order, err)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("отсутствующий заказ запрошен %d раз вместо одного", n)
+	}
+
+	// Ошибки не запоминаются
+	failing := func(ctx context.Context) (*model.Order, error) {
+		calls.Add(1)
+		return nil, errors.New("boom")
+	}
+	calls.Store(0)
+	for i := 0; i < 2; i++ {
+		if _, err := l.load(context.Background(), "order:b", failing); err == nil {
+			t.Fatal("ожидалась ошибка загрузки")
+		}
+	}
+	if n := calls.Load(); n != 2 {
+		t.Fatalf("после ошибки заказ запрошен %d раз вместо двух", n)
+	}
+
+	// По истечении negativeTTL заказ запрашивается снова
+	calls.Store(0)
+	time.Sleep(60 * time.Millisecond)
+	l.load(context.Background(), "order:a", missing)
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("после истечения negativeTTL заказ запрошен %d раз вместо одного", n)
+	}
+
+	// forget сбрасывает запомненное отсутствие, например когда заказ записан событием
+	calls.Store(0)
+	l.forget("order:a")
+	l.load(context.Background(), "order:a", missing)
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("после forget заказ запрошен %d раз вместо одного", n)
+	}
+}
+
+func TestLoaderSupersededLoad(t *testing.T) {
+	l := newLoader()
+	l.setNegativeTTL(time.Minute)
+	var superseded bool
+	fn := func(ctx context.Context) (*model.Order, error) {
+		// Заказ изменился, пока загрузка читала его из базы данных
+		l.forget("order:a")
+		superseded = l.superseded("order:a")
+		return nil, nil
+	}
+	l.load(context.Background(), "order:a", fn)
+	if !superseded {
+		t.Fatal("загрузка не отмечена устаревшей после изменения заказа")
+	}
+
+	// Отсутствие, прочитанное устаревшей загрузкой, не запоминается
+	var calls atomic.Int32
+	l.load(context.Background(), "order:a", func(ctx context.Context) (*model.Order, error) {
+		calls.Add(1)
+		return nil, nil
+	})
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("отсутствие из устаревшей загрузки запомнено: повторных запросов %d", n)
+	}
+	if l.superseded("order:a") {
+		t.Fatal("завершенная загрузка считается устаревшей")
+	}
+}
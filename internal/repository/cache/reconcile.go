@@ -0,0 +1,494 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ArtemZ007/wb-l0/internal/domain/model"
+	"github.com/ArtemZ007/wb-l0/pkg/logger"
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	maxReportedOrders = 100       // Максимальное число идентификаторов заказов в каждом разделе отчета
+	invalidHash       = "invalid" // Хеш заказа, который не удалось декодировать из кэша
+)
+
+// ErrReconcileUnsupported возвращается NewReconciler для хранилищ заказов, которые нечего сверять
+// с базой данных, например для чтения напрямую из базы.
+var ErrReconcileUnsupported = errors.New("хранилище заказов не поддерживает сверку с базой данных")
+
+// cachedState описывает заказ в кэше при сверке с базой данных.
+type cachedState struct {
+	hash    string // Хеш содержимого заказа; пустой, если заказа нет в кэше
+	indexed bool   // Заказ есть в индексе
+}
+
+// inspector реализуется кэшами, которые можно сверить с базой данных.
+type inspector interface {
+	// inspect возвращает состояние заказов в кэше, не загружая отсутствующие из базы данных
+	// и не отмечая обращение к ним.
+	inspect(ctx context.Context, orderUIDs []string) ([]cachedState, error)
+	// scanCached перебирает идентификаторы заказов в кэше и его индексе в пространстве имен контекста ctx
+	// и передает их fn пакетами не больше scanBatchSize.
+	scanCached(ctx context.Context, fn func(orderUIDs []string) error) error
+	// evicts сообщает, что заказы могут законно отсутствовать в кэше из-за истечения или вытеснения.
+	evicts() bool
+}
+
+// ReconcileService определяет чтение заказов из базы данных, необходимое для сверки.
+type ReconcileService interface {
+	OrderService
+	ExistingOrders(ctx context.Context, orderUIDs []string) ([]string, error)
+}
+
+// orderHash возвращает хеш содержимого заказа. Хешируется нормализованный заказ: без поля ID,
+// которое база данных не хранит, и с датой создания в UTC, чтобы заказ, записанный в кэш из события,
+// совпадал с тем же заказом, прочитанным из базы данных.
+func orderHash(order *model.Order) (string, error) {
+	normalized := *order
+	normalized.ID = ""
+	if created, err := time.Parse(time.RFC3339Nano, order.DateCreated); err == nil {
+		normalized.DateCreated = created.UTC().Format(time.RFC3339Nano)
+	}
+	data, err := json.Marshal(&normalized)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// ReconcileDrift описывает расхождения одного вида.
+type ReconcileDrift struct {
+	Count  int      `json:"count"`  // Число заказов
+	Orders []string `json:"orders"` // Идентификаторы первых заказов, не более maxReportedOrders
+}
+
+// add учитывает заказ с расхождением.
+func (d *ReconcileDrift) add(orderUID string) {
+	d.Count++
+	if len(d.Orders) < maxReportedOrders {
+		d.Orders = append(d.Orders, orderUID)
+	}
+}
+
+// ReconcileReport описывает результат сверки кэша с базой данных.
+type ReconcileReport struct {
+	StartedAt  time.Time      `json:"started_at"`
+	FinishedAt time.Time      `json:"finished_at"`
+	Checked    int            `json:"checked"`  // Число проверенных заказов из базы данных
+	Expired    int            `json:"expired"`  // Истекшие или вытесненные заказы, которые загрузятся по запросу
+	Missing    ReconcileDrift `json:"missing"`  // Заказы, которые есть в базе, но отсутствуют в кэше
+	Stale      ReconcileDrift `json:"stale"`    // Заказы, содержимое которых в кэше отличается от базы
+	Orphaned   ReconcileDrift `json:"orphaned"` // Заказы в кэше, которых нет в базе
+	Repaired   int            `json:"repaired"` // Число исправленных заказов
+	Failed     int            `json:"failed"`   // Число заказов, которые не удалось исправить
+}
+
+// Drifted сообщает, найдены ли расхождения.
+func (r *ReconcileReport) Drifted() bool {
+	return r.Missing.Count > 0 || r.Stale.Count > 0 || r.Orphaned.Count > 0
+}
+
+// Reconciler сверяет кэш с базой данных по хешам содержимого заказов и при необходимости
+// исправляет расхождения: записывает в кэш отсутствующие и устаревшие заказы из базы
+// и удаляет из кэша заказы, которых нет в базе.
+type Reconciler struct {
+	cache     OrderStore
+	inspector inspector
+	dbService ReconcileService
+	repair    bool
+	logger    logger.Logger
+}
+
+// NewReconciler создает сверку кэша c с базой данных dbService. По умолчанию расхождения только
+// попадают в отчет; исправление включается SetRepair.
+func NewReconciler(c OrderStore, dbService ReconcileService, logger logger.Logger) (*Reconciler, error) {
+	inspector, ok := c.(inspector)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T", ErrReconcileUnsupported, c)
+	}
+	return &Reconciler{cache: c, inspector: inspector, dbService: dbService, logger: logger}, nil
+}
+
+// SetRepair включает исправление найденных расхождений.
+func (r *Reconciler) SetRepair(repair bool) {
+	r.repair = repair
+}
+
+// Reconcile сверяет кэш с заказами базы данных, доступными в контекстах tenants
+// (по одному контексту на схему арендатора), и возвращает отчет. Заказы каждой схемы
+// сверяются с пространством имен кэша этой схемы.
+// Каждое расхождение перед попаданием в отчет перепроверяется по свежему чтению заказа,
+// чтобы не принять за расхождение заказ, измененный во время сверки.
+func (r *Reconciler) Reconcile(ctx context.Context, tenants []context.Context) (*ReconcileReport, error) {
+	report := &ReconcileReport{StartedAt: time.Now().UTC()}
+	for _, tenantCtx := range tenants {
+		if err := r.reconcileTenant(tenantCtx, report); err != nil {
+			return nil, err
+		}
+	}
+	report.FinishedAt = time.Now().UTC()
+	return report, nil
+}
+
+// reconcileTenant сверяет заказы схемы арендатора контекста ctx с кэшем и дополняет отчет report.
+// Заказы базы данных и кэша перебираются страницами, поэтому сверка не держит в памяти
+// идентификаторы всех заказов.
+func (r *Reconciler) reconcileTenant(ctx context.Context, report *ReconcileReport) error {
+	query := model.ListQuery{Limit: warmupPageSize}
+	for {
+		page, err := r.dbService.ListOrders(ctx, query)
+		if err != nil {
+			return fmt.Errorf("ошибка при получении заказов из базы данных: %w", err)
+		}
+		if err := r.checkPage(ctx, page.Orders, report); err != nil {
+			return err
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		query.Cursor = page.NextCursor
+	}
+
+	err := r.inspector.scanCached(ctx, func(orderUIDs []string) error {
+		return r.checkOrphans(ctx, orderUIDs, report)
+	})
+	if err != nil {
+		return fmt.Errorf("ошибка при переборе заказов в кэше: %w", err)
+	}
+	return nil
+}
+
+// checkPage сверяет страницу заказов из базы данных с кэшем.
+func (r *Reconciler) checkPage(ctx context.Context, orders []model.Order, report *ReconcileReport) error {
+	if len(orders) == 0 {
+		return nil
+	}
+	ids := make([]string, len(orders))
+	for i := range orders {
+		ids[i] = orders[i].OrderUID
+	}
+	states, err := r.inspector.inspect(ctx, ids)
+	if err != nil {
+		return fmt.Errorf("ошибка при чтении заказов из кэша: %w", err)
+	}
+
+	for i := range orders {
+		report.Checked++
+		drift, err := r.classify(report, &orders[i], states[i])
+		if err != nil {
+			return err
+		}
+		if drift == nil {
+			if states[i].hash == "" {
+				report.Expired++
+			}
+			continue
+		}
+
+		// Перепроверка по свежему чтению: заказ мог измениться после чтения страницы
+		fresh, err := r.dbService.GetOrder(ctx, orders[i].OrderUID)
+		if err != nil {
+			return fmt.Errorf("ошибка при получении заказа %s из базы данных: %w", orders[i].OrderUID, err)
+		}
+		if fresh == nil {
+			continue // Заказ удален во время сверки
+		}
+		recheck, err := r.inspector.inspect(ctx, []string{fresh.OrderUID})
+		if err != nil {
+			return fmt.Errorf("ошибка при чтении заказов из кэша: %w", err)
+		}
+		if drift, err = r.classify(report, fresh, recheck[0]); err != nil {
+			return err
+		}
+		if drift == nil {
+			continue
+		}
+		drift.add(fresh.OrderUID)
+		if r.repair {
+			r.record(report, r.cache.AddOrUpdateOrder(ctx, fresh), fresh.OrderUID)
+		}
+	}
+	return nil
+}
+
+// classify возвращает раздел отчета report для расхождения заказа из базы с его состоянием в кэше
+// или nil, если расхождения нет.
+func (r *Reconciler) classify(report *ReconcileReport, order *model.Order, state cachedState) (*ReconcileDrift, error) {
+	switch {
+	case state.hash == "" && state.indexed && r.inspector.evicts():
+		return nil, nil
+	case state.hash == "":
+		return &report.Missing, nil
+	}
+	hash, err := orderHash(order)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при вычислении хеша заказа %s: %w", order.OrderUID, err)
+	}
+	if hash != state.hash || !state.indexed {
+		return &report.Stale, nil
+	}
+	return nil, nil
+}
+
+// checkOrphans проверяет пакет заказов из кэша одним запросом к базе данных и учитывает
+// как лишние те, которых нет в схеме арендатора контекста ctx.
+func (r *Reconciler) checkOrphans(ctx context.Context, orderUIDs []string, report *ReconcileReport) error {
+	existing, err := r.dbService.ExistingOrders(ctx, orderUIDs)
+	if err != nil {
+		return fmt.Errorf("ошибка при проверке заказов в базе данных: %w", err)
+	}
+	known := make(map[string]struct{}, len(existing))
+	for _, orderUID := range existing {
+		known[orderUID] = struct{}{}
+	}
+	for _, orderUID := range orderUIDs {
+		if _, ok := known[orderUID]; ok {
+			continue
+		}
+		report.Orphaned.add(orderUID)
+		if r.repair {
+			r.record(report, r.cache.DeleteOrder(ctx, orderUID), orderUID)
+		}
+	}
+	return nil
+}
+
+// record учитывает результат исправления заказа.
+func (r *Reconciler) record(report *ReconcileReport, err error, orderUID string) {
+	if err != nil {
+		report.Failed++
+		r.logger.Error("Ошибка при исправлении заказа в кэше", map[string]interface{}{"error": err, "orderUID": orderUID})
+		return
+	}
+	report.Repaired++
+}
+
+// Run сверяет кэш с базой данных каждые interval до завершения контекста и записывает отчеты в журнал.
+func (r *Reconciler) Run(ctx context.Context, interval time.Duration, tenants []context.Context) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		report, err := r.Reconcile(ctx, tenants)
+		if err != nil {
+			if errors.Is(err, context.Canceled) {
+				return err
+			}
+			r.logger.Error("Ошибка сверки кэша с базой данных", map[string]interface{}{"error": err})
+			continue
+		}
+		fields := map[string]interface{}{
+			"checked":  report.Checked,
+			"missing":  report.Missing.Count,
+			"stale":    report.Stale.Count,
+			"orphaned": report.Orphaned.Count,
+			"repaired": report.Repaired,
+			"failed":   report.Failed,
+		}
+		if report.Drifted() {
+			r.logger.Warn("Кэш расходится с базой данных", fields)
+		} else {
+			r.logger.Info("Кэш соответствует базе данных", fields)
+		}
+	}
+}
+
+// inspect возвращает состояние заказов в Redis, читая их одним конвейером.
+func (s *CacheService) inspect(ctx context.Context, orderUIDs []string) ([]cachedState, error) {
+	keys, err := s.keyspace(ctx)
+	if err != nil {
+		return nil, err
+	}
+	orderKeys := make([]string, len(orderUIDs))
+	for i, orderUID := range orderUIDs {
+		orderKeys[i] = keys.order(orderUID)
+	}
+	var values *redis.SliceCmd
+	scores := make([]*redis.FloatCmd, len(orderUIDs))
+	_, err = s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		values = pipe.MGet(ctx, orderKeys...)
+		for i, orderUID := range orderUIDs {
+			scores[i] = pipe.ZScore(ctx, keys.index(), orderUID)
+		}
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	states := make([]cachedState, len(orderUIDs))
+	for i, value := range values.Val() {
+		states[i].indexed = scores[i].Err() == nil
+		data, ok := value.(string)
+		if !ok {
+			continue
+		}
+		var order model.Order
+		if err := s.envelope.decode([]byte(data), &order); err != nil {
+			states[i].hash = invalidHash
+			continue
+		}
+		if states[i].hash, err = orderHash(&order); err != nil {
+			states[i].hash = invalidHash
+		}
+	}
+	return states, nil
+}
+
+// scanCached перебирает заказы, у которых в Redis есть ключ, командой SCAN, а затем участников индекса
+// без ключа командой ZSCAN. Оба перебора идут по курсору и не блокируют Redis.
+func (s *CacheService) scanCached(ctx context.Context, fn func(orderUIDs []string) error) error {
+	keys, err := s.keyspace(ctx)
+	if err != nil {
+		return err
+	}
+	prefix := keys.order("")
+	for cursor := uint64(0); ; {
+		var found []string
+		found, cursor, err = s.client.Scan(ctx, cursor, prefix+"*", scanBatchSize).Result()
+		if err != nil {
+			return err
+		}
+		if len(found) > 0 {
+			orderUIDs := make([]string, len(found))
+			for i, key := range found {
+				orderUIDs[i] = strings.TrimPrefix(key, prefix)
+			}
+			if err := fn(orderUIDs); err != nil {
+				return err
+			}
+		}
+		if cursor == 0 {
+			break
+		}
+	}
+
+	for cursor := uint64(0); ; {
+		var found []string
+		found, cursor, err = s.client.ZScan(ctx, keys.index(), cursor, "", scanBatchSize).Result()
+		if err != nil {
+			return err
+		}
+		// ZSCAN возвращает участников множества вперемешку с их весами
+		members := make([]string, 0, len(found)/2)
+		for i := 0; i < len(found); i += 2 {
+			members = append(members, found[i])
+		}
+		// Участники с ключом уже перебраны командой SCAN
+		exists := make([]*redis.IntCmd, len(members))
+		_, err := s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for i, orderUID := range members {
+				exists[i] = pipe.Exists(ctx, keys.order(orderUID))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		var orderUIDs []string
+		for i, orderUID := range members {
+			if exists[i].Val() == 0 {
+				orderUIDs = append(orderUIDs, orderUID)
+			}
+		}
+		if len(orderUIDs) > 0 {
+			if err := fn(orderUIDs); err != nil {
+				return err
+			}
+		}
+		if cursor == 0 {
+			return nil
+		}
+	}
+}
+
+// evicts сообщает, что заказы в Redis истекают или вытесняются.
+func (s *CacheService) evicts() bool {
+	return s.expiry.Mode != ExpiryNone || s.expiry.MaxEntries > 0
+}
+
+// inspect возвращает состояние заказов в памяти.
+func (c *MemoryCache) inspect(ctx context.Context, orderUIDs []string) ([]cachedState, error) {
+	namespace, err := c.namespace.of(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	space := c.space(namespace)
+	states := make([]cachedState, len(orderUIDs))
+	for i, orderUID := range orderUIDs {
+		entry, ok := space.orders[orderUID]
+		if !ok {
+			continue
+		}
+		states[i].indexed = true
+		if !entry.loaded(now) {
+			continue
+		}
+		hash, err := orderHash(entry.order)
+		if err != nil {
+			hash = invalidHash
+		}
+		states[i].hash = hash
+	}
+	return states, nil
+}
+
+// scanCached перебирает заказы индекса страницами, продолжая каждую после последнего заказа предыдущей,
+// чтобы удаление заказов при исправлении не сдвигало страницы. Блокировка кэша удерживается только
+// на время чтения страницы.
+func (c *MemoryCache) scanCached(ctx context.Context, fn func(orderUIDs []string) error) error {
+	namespace, err := c.namespace.of(ctx)
+	if err != nil {
+		return err
+	}
+	var after *indexKey
+	for {
+		c.mu.Lock()
+		index := c.space(namespace).index
+		start := 0
+		if after != nil {
+			start = sort.Search(len(index), func(i int) bool { return after.before(index[i]) })
+		}
+		page := pageOf(index, start, scanBatchSize)
+		orderUIDs := make([]string, len(page))
+		for i, key := range page {
+			orderUIDs[i] = key.orderUID
+		}
+		c.mu.Unlock()
+
+		if len(page) == 0 {
+			return nil
+		}
+		last := page[len(page)-1]
+		after = &last
+		if err := fn(orderUIDs); err != nil {
+			return err
+		}
+		if len(page) < scanBatchSize {
+			return nil
+		}
+	}
+}
+
+// evicts сообщает, что заказы в памяти истекают или вытесняются.
+func (c *MemoryCache) evicts() bool {
+	return c.expiry.Mode != ExpiryNone || c.expiry.MaxEntries > 0
+}
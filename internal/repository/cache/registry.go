@@ -0,0 +1,133 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ArtemZ007/wb-l0/pkg/logger"
+)
+
+// Имена встроенных хранилищ заказов.
+const (
+	StoreRedis    = "redis"    // Redis с локальным кэшем каждого экземпляра
+	StoreMemory   = "memory"   // Кэш в памяти процесса со снимками на диске
+	StoreDatabase = "database" // Чтение напрямую из базы данных без кэширования
+)
+
+// StoreConfig описывает настройки хранилищ заказов. Каждое хранилище использует только свои настройки.
+type StoreConfig struct {
+	DBService   OrderService  // Источник заказов
+	Writer      OrderWriter   // Запись новых заказов; nil — хранилище только читает заказы
+	Logger      logger.Logger // Логгер для регистрации событий
+	Namespace   NamespaceFunc // Пространство имен кэша для контекста запроса; nil — общий кэш
+	Expiry      ExpiryPolicy  // Время жизни заказов и ограничение их числа
+	NegativeTTL time.Duration // Сколько помнить об отсутствии заказа в базе данных
+
+	RedisAddr       string // Адрес Redis
+	RedisPassword   string // Пароль Redis
+	RedisDB         int    // Номер базы Redis
+	LocalMaxEntries int    // Ограничение числа заказов в локальном кэше перед Redis
+	LocalMaxBytes   int    // Ограничение размера локального кэша перед Redis
+	Codec           Codec  // Кодек заказов в Redis; nil — JSON
+	Compress        bool   // Сжимать заказы в Redis
+
+	SnapshotPath     string        // Файл снимка кэша в памяти; пустой отключает снимки
+	SnapshotInterval time.Duration // Периодичность записи снимка
+	SnapshotMaxAge   time.Duration // Предельный возраст восстанавливаемого снимка
+}
+
+// StoreFactory создает хранилище заказов с настройками cfg.
+type StoreFactory func(cfg StoreConfig) (OrderStore, error)
+
+var (
+	storesMu sync.RWMutex
+	stores   = make(map[string]StoreFactory)
+)
+
+func init() {
+	RegisterStore(StoreRedis, newRedisStore)
+	RegisterStore(StoreMemory, newMemoryStore)
+	RegisterStore(StoreDatabase, newDatabaseStore)
+}
+
+// RegisterStore регистрирует хранилище заказов под именем name.
+// Повторная регистрация того же имени приводит к панике.
+func RegisterStore(name string, factory StoreFactory) {
+	storesMu.Lock()
+	defer storesMu.Unlock()
+	if factory == nil {
+		panic("cache: RegisterStore с пустой фабрикой для " + name)
+	}
+	if _, exists := stores[name]; exists {
+		panic("cache: хранилище " + name + " уже зарегистрировано")
+	}
+	stores[name] = factory
+}
+
+// StoreNames возвращает отсортированные имена зарегистрированных хранилищ.
+func StoreNames() []string {
+	storesMu.RLock()
+	defer storesMu.RUnlock()
+	names := make([]string, 0, len(stores))
+	for name := range stores {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewStore создает хранилище заказов, зарегистрированное под именем name.
+func NewStore(name string, cfg StoreConfig) (OrderStore, error) {
+	storesMu.RLock()
+	factory, ok := stores[strings.ToLower(name)]
+	storesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("неизвестное хранилище заказов %q, ожидается одно из: %s", name, strings.Join(StoreNames(), ", "))
+	}
+	return factory(cfg)
+}
+
+// newRedisStore создает хранилище заказов в Redis.
+func newRedisStore(cfg StoreConfig) (OrderStore, error) {
+	redisCache := NewCacheService(cfg.RedisAddr, cfg.RedisPassword, cfg.RedisDB, cfg.Logger)
+	if redisCache == nil {
+		return nil, errors.New("не удалось создать сервис кэша")
+	}
+	redisCache.SetDBService(cfg.DBService)
+	redisCache.SetOrderWriter(cfg.Writer)
+	redisCache.SetNamespace(cfg.Namespace)
+	redisCache.SetExpiryPolicy(cfg.Expiry)
+	redisCache.SetNegativeTTL(cfg.NegativeTTL)
+	redisCache.SetLocalCache(cfg.LocalMaxEntries, cfg.LocalMaxBytes)
+	if cfg.Codec != nil {
+		redisCache.SetCodec(cfg.Codec, cfg.Compress)
+	}
+	return redisCache, nil
+}
+
+// newMemoryStore создает хранилище заказов в памяти процесса.
+func newMemoryStore(cfg StoreConfig) (OrderStore, error) {
+	memoryCache := NewMemoryCache(cfg.Logger)
+	memoryCache.SetDBService(cfg.DBService)
+	memoryCache.SetOrderWriter(cfg.Writer)
+	memoryCache.SetNamespace(cfg.Namespace)
+	memoryCache.SetExpiryPolicy(cfg.Expiry)
+	memoryCache.SetNegativeTTL(cfg.NegativeTTL)
+	memoryCache.SetSnapshot(cfg.SnapshotPath, cfg.SnapshotInterval, cfg.SnapshotMaxAge)
+	return memoryCache, nil
+}
+
+// newDatabaseStore создает хранилище, читающее заказы напрямую из базы данных.
+func newDatabaseStore(cfg StoreConfig) (OrderStore, error) {
+	if cfg.DBService == nil {
+		return nil, errors.New("для хранилища database не задан сервис базы данных")
+	}
+	store := NewDatabaseStore(cfg.DBService, cfg.Logger)
+	store.SetNamespace(cfg.Namespace)
+	store.SetOrderWriter(cfg.Writer)
+	return store, nil
+}
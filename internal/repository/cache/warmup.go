@@ -0,0 +1,106 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ArtemZ007/wb-l0/internal/domain/model"
+	"github.com/ArtemZ007/wb-l0/pkg/logger"
+)
+
+const warmupLogInterval = 5 * time.Second // Периодичность записи хода прогрева в журнал
+
+// WarmupStatus описывает состояние прогрева кэша.
+type WarmupStatus struct {
+	Loaded     int        `json:"loaded"`                // Число загруженных заказов
+	Done       bool       `json:"done"`                  // Прогрев завершен успешно
+	Error      string     `json:"error,omitempty"`       // Ошибка последней попытки прогрева
+	StartedAt  time.Time  `json:"started_at"`            // Начало последней попытки прогрева
+	FinishedAt *time.Time `json:"finished_at,omitempty"` // Окончание последней попытки прогрева
+}
+
+// WarmupProgress отслеживает ход прогрева кэша. Безопасен для одновременного использования.
+type WarmupProgress struct {
+	mu     sync.Mutex
+	status WarmupStatus
+}
+
+// NewWarmupProgress создает состояние прогрева, который еще не завершен.
+func NewWarmupProgress() *WarmupProgress {
+	return &WarmupProgress{status: WarmupStatus{StartedAt: time.Now().UTC()}}
+}
+
+// Start отмечает начало новой попытки прогрева и сбрасывает счетчик загруженных заказов.
+func (p *WarmupProgress) Start() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.status = WarmupStatus{StartedAt: time.Now().UTC()}
+}
+
+// Finish отмечает окончание попытки прогрева с ошибкой err или успешное завершение, если err равна nil.
+func (p *WarmupProgress) Finish(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	finished := time.Now().UTC()
+	p.status.FinishedAt = &finished
+	p.status.Done = err == nil
+	if err != nil {
+		p.status.Error = err.Error()
+	}
+}
+
+// Status возвращает текущее состояние прогрева.
+func (p *WarmupProgress) Status() WarmupStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.status
+}
+
+// add увеличивает число загруженных заказов на n. Ничего не делает, если p равен nil.
+func (p *WarmupProgress) add(n int) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.status.Loaded += n
+}
+
+// loadOrders постранично загружает заказы из базы данных и передает каждую страницу в addBatch
+// вместе с контекстом ctx, определяющим пространство имен кэша,
+// не держа в памяти больше одной страницы. Ход загрузки отражается в progress и периодически
+// записывается в журнал.
+func loadOrders(ctx context.Context, dbService OrderService, addBatch func(context.Context, []model.Order) error,
+	progress *WarmupProgress, logger logger.Logger) error {
+	query := model.ListQuery{Limit: warmupPageSize}
+	count := 0
+	lastLog := time.Now()
+	for {
+		page, err := dbService.ListOrders(ctx, query)
+		if err != nil {
+			logger.Error("Ошибка при получении заказов из базы данных", map[string]interface{}{"error": err})
+			return err
+		}
+
+		if err := addBatch(ctx, page.Orders); err != nil {
+			logger.Error("Ошибка при добавлении заказов в кэш", map[string]interface{}{"error": err})
+			return err
+		}
+		count += len(page.Orders)
+		progress.add(len(page.Orders))
+
+		if page.NextCursor == "" {
+			break
+		}
+		query.Cursor = page.NextCursor
+
+		if time.Since(lastLog) >= warmupLogInterval {
+			logger.Info("Прогрев кэша продолжается", map[string]interface{}{"count": count})
+			lastLog = time.Now()
+		}
+	}
+	logger.Info("Кэш инициализирован заказами", map[string]interface{}{"count": count})
+
+	return nil
+}
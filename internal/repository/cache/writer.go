@@ -0,0 +1,45 @@
+package cache
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ArtemZ007/wb-l0/internal/domain/model"
+	"github.com/ArtemZ007/wb-l0/internal/repository/database"
+)
+
+// ErrReadOnlyStore возвращается при записи заказов в хранилище, для которого не задана их запись.
+var ErrReadOnlyStore = errors.New("хранилище заказов доступно только для чтения")
+
+// OrderWriter определяет запись новых заказов. Ей пользуется NATS слушатель.
+type OrderWriter interface {
+	SaveOrder(ctx context.Context, order *model.Order) (database.SaveOutcome, error)
+	SaveOrders(ctx context.Context, orders []model.Order) (*database.BulkResult, error)
+}
+
+// orderWriter передает заказы на запись в хранилище заказов. Кэш при записи не меняется:
+// его обновляют события об изменении заказов, доставляемые после записи.
+type orderWriter struct {
+	writer OrderWriter
+}
+
+// SetOrderWriter устанавливает хранилище, в которое записываются новые заказы.
+func (w *orderWriter) SetOrderWriter(writer OrderWriter) {
+	w.writer = writer
+}
+
+// SaveOrder записывает заказ или возвращает ErrReadOnlyStore, если запись не задана.
+func (w *orderWriter) SaveOrder(ctx context.Context, order *model.Order) (database.SaveOutcome, error) {
+	if w.writer == nil {
+		return "", ErrReadOnlyStore
+	}
+	return w.writer.SaveOrder(ctx, order)
+}
+
+// SaveOrders записывает пакет заказов или возвращает ErrReadOnlyStore, если запись не задана.
+func (w *orderWriter) SaveOrders(ctx context.Context, orders []model.Order) (*database.BulkResult, error) {
+	if w.writer == nil {
+		return nil, ErrReadOnlyStore
+	}
+	return w.writer.SaveOrders(ctx, orders)
+}
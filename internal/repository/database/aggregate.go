@@ -0,0 +1,431 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/ArtemZ007/wb-l0/internal/domain/model"
+	"github.com/lib/pq"
+)
+
+// ErrOrderNotFound возвращается, когда заказ отсутствует в базе данных.
+var ErrOrderNotFound = errors.New("заказ не найден")
+
+// VersionConflictError возвращается UpdateOrder, когда заказ был изменен после того,
+// как вызывающий прочитал его версию.
+type VersionConflictError struct {
+	OrderUID string // Уникальный идентификатор заказа
+	Expected int    // Версия, на основе которой сделано изменение
+	Actual   int    // Текущая версия заказа в базе
+}
+
+// Error возвращает описание конфликта версий.
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("заказ %s изменен: ожидалась версия %d, текущая версия %d", e.OrderUID, e.Expected, e.Actual)
+}
+
+// querier объединяет методы, общие для *sql.DB и *sql.Tx.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// readOnlyTx задает параметры транзакции для согласованного чтения агрегата.
+var readOnlyTx = &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}
+
+// selectOrdersQuery выбирает заказ вместе с доставкой и оплатой.
+const selectOrdersQuery = `
+        SELECT
+            o.order_uid, o.track_number, o.entry, o.locale, o.internal_signature, o.customer_id,
+            o.delivery_service, o.shardkey, o.sm_id, o.date_created, o.oof_shard, o.version,
+            d.id, d.name, d.phone, d.zip, d.city, d.address, d.region, d.email,
+            p.id, p.transaction, p.request_id, p.currency, p.provider, p.amount, p.payment_dt,
+            p.bank, p.delivery_cost, p.goods_total, p.custom_fee
+        FROM
+            orders o
+            LEFT JOIN deliveries d ON d.id = o.delivery_id
+            LEFT JOIN payments p ON p.id = o.payment_id`
+
+// withTx выполняет fn в транзакции на основной базе, фиксируя ее при успехе и откатывая при ошибке.
+// Если основная база недоступна и выключатель разомкнут, возвращает *CircuitOpenError без обращения к базе.
+func (s *Service) withTx(ctx context.Context, opts *sql.TxOptions, fn func(tx *sql.Tx) error) error {
+	if err := s.breaker.allow(); err != nil {
+		return err
+	}
+	err := s.runTx(ctx, s.db, opts, fn)
+	s.breaker.record(err)
+	return err
+}
+
+// runTx выполняет fn в транзакции на базе db в схеме арендатора из ctx,
+// фиксируя ее при успехе и откатывая при ошибке.
+func (s *Service) runTx(ctx context.Context, db *sql.DB, opts *sql.TxOptions, fn func(tx *sql.Tx) error) error {
+	schema, err := s.Schema(ctx)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.BeginTx(ctx, opts)
+	if err != nil {
+		return fmt.Errorf("не удалось начать транзакцию: %w", err)
+	}
+
+	if err := setSearchPath(ctx, tx, schema); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			s.logger.WithError(rbErr).Error("Ошибка при откате транзакции")
+		}
+		return err
+	}
+	if err := fn(tx); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			s.logger.WithError(rbErr).Error("Ошибка при откате транзакции")
+		}
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("не удалось зафиксировать транзакцию: %w", err)
+	}
+	return nil
+}
+
+// insertOrder записывает новый заказ со всеми вложенными сущностями.
+// Если заказ с таким order_uid уже существует, ничего не меняет и возвращает false.
+// Уникальность order_uid и track_number во всех секциях orders проверяется по таблице order_keys.
+func insertOrder(ctx context.Context, tx *sql.Tx, order *model.Order) (bool, error) {
+	res, err := tx.ExecContext(ctx,
+		"INSERT INTO order_keys (order_uid, track_number, date_created) VALUES ($1, $2, $3) ON CONFLICT (order_uid) DO NOTHING",
+		order.OrderUID, order.TrackNumber, order.DateCreated)
+	if err != nil {
+		return false, fmt.Errorf("ошибка при сохранении заказа: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return false, err
+	}
+
+	deliveryID, err := saveDelivery(ctx, tx, sql.NullString{}, order.Delivery)
+	if err != nil {
+		return false, err
+	}
+	paymentID, err := savePayment(ctx, tx, sql.NullString{}, order.Payment)
+	if err != nil {
+		return false, err
+	}
+
+	query := `
+        INSERT INTO orders (
+            order_uid, track_number, entry, delivery_id, payment_id, locale, internal_signature,
+            customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard
+        ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`
+	if _, err := tx.ExecContext(ctx, query, order.OrderUID, order.TrackNumber, order.Entry, deliveryID, paymentID,
+		order.Locale, order.InternalSignature, order.CustomerID, order.DeliveryService, order.Shardkey,
+		order.SMID, order.DateCreated, order.OofShard); err != nil {
+		return false, fmt.Errorf("ошибка при сохранении заказа: %w", err)
+	}
+	order.Version = 1
+
+	return true, insertItems(ctx, tx, order)
+}
+
+// replaceOrder перезаписывает существующий заказ со всеми вложенными сущностями
+// и записывает в order новую версию заказа. Удаленный заказ при перезаписи восстанавливается.
+func replaceOrder(ctx context.Context, tx *sql.Tx, order *model.Order) error {
+	var oldDeliveryID, oldPaymentID sql.NullString
+	err := tx.QueryRowContext(ctx,
+		"SELECT delivery_id, payment_id FROM orders WHERE order_uid = $1 FOR UPDATE",
+		order.OrderUID).Scan(&oldDeliveryID, &oldPaymentID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrOrderNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка при блокировке заказа: %w", err)
+	}
+
+	deliveryID, err := saveDelivery(ctx, tx, oldDeliveryID, order.Delivery)
+	if err != nil {
+		return err
+	}
+	paymentID, err := savePayment(ctx, tx, oldPaymentID, order.Payment)
+	if err != nil {
+		return err
+	}
+
+	query := `
+        UPDATE orders SET
+            track_number = $2, entry = $3, delivery_id = $4, payment_id = $5, locale = $6,
+            internal_signature = $7, customer_id = $8, delivery_service = $9, shardkey = $10,
+            sm_id = $11, date_created = $12, oof_shard = $13, deleted_at = NULL, version = version + 1
+        WHERE order_uid = $1
+        RETURNING version`
+	if err := tx.QueryRowContext(ctx, query, order.OrderUID, order.TrackNumber, order.Entry, deliveryID, paymentID,
+		order.Locale, order.InternalSignature, order.CustomerID, order.DeliveryService, order.Shardkey,
+		order.SMID, order.DateCreated, order.OofShard).Scan(&order.Version); err != nil {
+		return fmt.Errorf("ошибка при обновлении заказа: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE order_keys SET track_number = $2, date_created = $3 WHERE order_uid = $1",
+		order.OrderUID, order.TrackNumber, order.DateCreated); err != nil {
+		return fmt.Errorf("ошибка при обновлении ключа заказа: %w", err)
+	}
+
+	// Удаление доставки и оплаты, которые больше не связаны с заказом.
+	// Заказ уже отвязан от них, поэтому каскадное удаление его не затронет.
+	if oldDeliveryID.Valid && !deliveryID.Valid {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM deliveries WHERE id = $1", oldDeliveryID); err != nil {
+			return fmt.Errorf("ошибка при удалении доставки: %w", err)
+		}
+	}
+	if oldPaymentID.Valid && !paymentID.Valid {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM payments WHERE id = $1", oldPaymentID); err != nil {
+			return fmt.Errorf("ошибка при удалении оплаты: %w", err)
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM items WHERE order_uid = $1", order.OrderUID); err != nil {
+		return fmt.Errorf("ошибка при удалении товаров заказа: %w", err)
+	}
+	return insertItems(ctx, tx, order)
+}
+
+// softDeleteOrder помечает заказ удаленным.
+func softDeleteOrder(ctx context.Context, tx *sql.Tx, orderUID string) error {
+	res, err := tx.ExecContext(ctx,
+		"UPDATE orders SET deleted_at = NOW(), version = version + 1 WHERE order_uid = $1 AND deleted_at IS NULL", orderUID)
+	if err != nil {
+		return fmt.Errorf("ошибка при удалении заказа: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		if err == nil {
+			err = ErrOrderNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// restoreOrder снимает с заказа отметку об удалении.
+func restoreOrder(ctx context.Context, tx *sql.Tx, orderUID string) error {
+	res, err := tx.ExecContext(ctx,
+		"UPDATE orders SET deleted_at = NULL, version = version + 1 WHERE order_uid = $1 AND deleted_at IS NOT NULL", orderUID)
+	if err != nil {
+		return fmt.Errorf("ошибка при восстановлении заказа: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		if err == nil {
+			err = ErrOrderNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// deleteOrder окончательно удаляет заказ вместе с товарами, доставкой и оплатой.
+func deleteOrder(ctx context.Context, tx *sql.Tx, orderUID string) error {
+	var deliveryID, paymentID sql.NullString
+	err := tx.QueryRowContext(ctx,
+		"DELETE FROM orders WHERE order_uid = $1 RETURNING delivery_id, payment_id",
+		orderUID).Scan(&deliveryID, &paymentID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrOrderNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка при удалении заказа: %w", err)
+	}
+
+	// Товары и ключ заказа не связаны с секционированной таблицей внешними ключами
+	if _, err := tx.ExecContext(ctx, "DELETE FROM items WHERE order_uid = $1", orderUID); err != nil {
+		return fmt.Errorf("ошибка при удалении товаров заказа: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM order_keys WHERE order_uid = $1", orderUID); err != nil {
+		return fmt.Errorf("ошибка при удалении ключа заказа: %w", err)
+	}
+
+	if deliveryID.Valid {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM deliveries WHERE id = $1", deliveryID); err != nil {
+			return fmt.Errorf("ошибка при удалении доставки: %w", err)
+		}
+	}
+	if paymentID.Valid {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM payments WHERE id = $1", paymentID); err != nil {
+			return fmt.Errorf("ошибка при удалении оплаты: %w", err)
+		}
+	}
+	return nil
+}
+
+// saveDelivery обновляет доставку с идентификатором id или создает новую, если id пуст.
+// Возвращает идентификатор доставки, связанной с заказом.
+func saveDelivery(ctx context.Context, tx *sql.Tx, id sql.NullString, d *model.Delivery) (sql.NullString, error) {
+	if d == nil {
+		return sql.NullString{}, nil
+	}
+
+	if id.Valid {
+		query := "UPDATE deliveries SET name = $2, phone = $3, zip = $4, city = $5, address = $6, region = $7, email = $8 WHERE id = $1"
+		if _, err := tx.ExecContext(ctx, query, id, d.Name, d.Phone, d.Zip, d.City, d.Address, d.Region, d.Email); err != nil {
+			return sql.NullString{}, fmt.Errorf("ошибка при обновлении доставки: %w", err)
+		}
+		return id, nil
+	}
+
+	query := "INSERT INTO deliveries (name, phone, zip, city, address, region, email) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id"
+	var newID sql.NullString
+	if err := tx.QueryRowContext(ctx, query, d.Name, d.Phone, d.Zip, d.City, d.Address, d.Region, d.Email).Scan(&newID); err != nil {
+		return sql.NullString{}, fmt.Errorf("ошибка при сохранении доставки: %w", err)
+	}
+	return newID, nil
+}
+
+// savePayment обновляет оплату с идентификатором id или создает новую, если id пуст.
+// Возвращает идентификатор оплаты, связанной с заказом.
+func savePayment(ctx context.Context, tx *sql.Tx, id sql.NullString, p *model.Payment) (sql.NullString, error) {
+	if p == nil {
+		return sql.NullString{}, nil
+	}
+
+	if id.Valid {
+		query := `
+            UPDATE payments SET
+                transaction = $2, request_id = $3, currency = $4, provider = $5, amount = $6, payment_dt = $7,
+                bank = $8, delivery_cost = $9, goods_total = $10, custom_fee = $11
+            WHERE id = $1`
+		if _, err := tx.ExecContext(ctx, query, id, p.Transaction, p.RequestID, p.Currency, p.Provider, p.Amount,
+			p.PaymentDt, p.Bank, p.DeliveryCost, p.GoodsTotal, p.CustomFee); err != nil {
+			return sql.NullString{}, fmt.Errorf("ошибка при обновлении оплаты: %w", err)
+		}
+		return id, nil
+	}
+
+	query := `
+        INSERT INTO payments (
+            transaction, request_id, currency, provider, amount, payment_dt, bank, delivery_cost, goods_total, custom_fee
+        ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id`
+	var newID sql.NullString
+	if err := tx.QueryRowContext(ctx, query, p.Transaction, p.RequestID, p.Currency, p.Provider, p.Amount,
+		p.PaymentDt, p.Bank, p.DeliveryCost, p.GoodsTotal, p.CustomFee).Scan(&newID); err != nil {
+		return sql.NullString{}, fmt.Errorf("ошибка при сохранении оплаты: %w", err)
+	}
+	return newID, nil
+}
+
+// insertItems сохраняет товары заказа, сохраняя их порядок.
+// Товары размещаются в секции, соответствующей дате создания заказа.
+func insertItems(ctx context.Context, tx *sql.Tx, order *model.Order) error {
+	if len(order.Items) == 0 {
+		return nil
+	}
+
+	stmt, err := tx.PrepareContext(ctx, `
+        INSERT INTO items (
+            order_uid, date_created, position, chrt_id, track_number, price, rid, name, sale, size,
+            total_price, nm_id, brand, status
+        ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)`)
+	if err != nil {
+		return fmt.Errorf("ошибка при подготовке вставки товаров: %w", err)
+	}
+	defer stmt.Close()
+
+	for i, item := range order.Items {
+		if _, err := stmt.ExecContext(ctx, order.OrderUID, order.DateCreated, i, item.ChrtID, item.TrackNumber,
+			item.Price, item.RID, item.Name, item.Sale, item.Size, item.TotalPrice, item.NmID, item.Brand,
+			item.Status); err != nil {
+			return fmt.Errorf("ошибка при сохранении товара: %w", err)
+		}
+	}
+	return nil
+}
+
+// queryOrders выполняет выборку заказов и дозагружает их товары.
+// Условие where и аргументы добавляются к selectOrdersQuery как есть.
+func queryOrders(ctx context.Context, q querier, where string, args ...interface{}) ([]model.Order, error) {
+	rows, err := q.QueryContext(ctx, selectOrdersQuery+" "+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var orders []model.Order
+	for rows.Next() {
+		order, err := scanOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, *order)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := loadItems(ctx, q, orders); err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+// scanOrder считывает строку selectOrdersQuery в модель заказа.
+func scanOrder(rows *sql.Rows) (*model.Order, error) {
+	order := model.Order{
+		Delivery: &model.Delivery{},
+		Payment:  &model.Payment{},
+		Items:    []model.Item{},
+	}
+	d, p := order.Delivery, order.Payment
+	var deliveryID, paymentID sql.NullString
+
+	if err := rows.Scan(
+		&order.OrderUID, &order.TrackNumber, &order.Entry, &order.Locale, &order.InternalSignature, &order.CustomerID,
+		&order.DeliveryService, &order.Shardkey, &order.SMID, &order.DateCreated, &order.OofShard, &order.Version,
+		&deliveryID, &d.Name, &d.Phone, &d.Zip, &d.City, &d.Address, &d.Region, &d.Email,
+		&paymentID, &p.Transaction, &p.RequestID, &p.Currency, &p.Provider, &p.Amount, &p.PaymentDt,
+		&p.Bank, &p.DeliveryCost, &p.GoodsTotal, &p.CustomFee,
+	); err != nil {
+		return nil, err
+	}
+
+	if !deliveryID.Valid {
+		order.Delivery = nil
+	}
+	if !paymentID.Valid {
+		order.Payment = nil
+	}
+	return &order, nil
+}
+
+// loadItems загружает товары для всех переданных заказов одним запросом.
+func loadItems(ctx context.Context, q querier, orders []model.Order) error {
+	if len(orders) == 0 {
+		return nil
+	}
+
+	index := make(map[string]int, len(orders))
+	uids := make([]string, len(orders))
+	for i, order := range orders {
+		index[order.OrderUID] = i
+		uids[i] = order.OrderUID
+	}
+
+	rows, err := q.QueryContext(ctx, `
+        SELECT order_uid, chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status
+        FROM items
+        WHERE order_uid = ANY($1::uuid[])
+        ORDER BY order_uid, position`, pq.Array(uids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var orderUID string
+		var item model.Item
+		if err := rows.Scan(&orderUID, &item.ChrtID, &item.TrackNumber, &item.Price, &item.RID, &item.Name,
+			&item.Sale, &item.Size, &item.TotalPrice, &item.NmID, &item.Brand, &item.Status); err != nil {
+			return err
+		}
+		if i, ok := index[orderUID]; ok {
+			orders[i].Items = append(orders[i].Items, item)
+		}
+	}
+	return rows.Err()
+}
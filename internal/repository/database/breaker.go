@@ -0,0 +1,129 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const defaultBreakerCooldown = 30 * time.Second // Время до пробного запроса по умолчанию
+
+// ErrCircuitOpen возвращается, когда основная база данных считается недоступной
+// и запросы к ней не выполняются до истечения паузы.
+var ErrCircuitOpen = errors.New("база данных недоступна, запросы временно не выполняются")
+
+// CircuitOpenError сообщает, что запрос отклонен без обращения к базе данных.
+// Сравнивается с ErrCircuitOpen через errors.Is.
+type CircuitOpenError struct {
+	RetryAfter time.Duration // Время до следующей попытки обращения к базе
+	Cause      error         // Последняя ошибка, после которой запросы были приостановлены
+}
+
+// Error возвращает описание отклоненного запроса.
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("%s, повтор через %s: %v", ErrCircuitOpen, e.RetryAfter.Round(time.Second), e.Cause)
+}
+
+// Is позволяет сравнивать ошибку с ErrCircuitOpen.
+func (e *CircuitOpenError) Is(target error) bool {
+	return target == ErrCircuitOpen
+}
+
+// breakerState описывает состояние автоматического выключателя.
+type breakerState int
+
+const (
+	breakerClosed   breakerState = iota // Запросы выполняются
+	breakerOpen                         // Запросы отклоняются до истечения паузы
+	breakerHalfOpen                     // Выполняется пробный запрос
+)
+
+// circuitBreaker приостанавливает запросы к основной базе после серии ошибок недоступности,
+// чтобы не ждать таймаутов соединения в каждом запросе. По истечении паузы пропускается
+// один пробный запрос: при успехе запросы возобновляются, при ошибке пауза начинается заново.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	state     breakerState
+	failures  int
+	openedAt  time.Time
+	lastErr   error
+	logger    *logrus.Logger
+}
+
+// SetCircuitBreaker включает автоматический выключатель запросов к основной базе:
+// после threshold подряд ошибок недоступности запросы отклоняются с *CircuitOpenError
+// в течение cooldown. Нулевой threshold отключает выключатель.
+func (s *Service) SetCircuitBreaker(threshold int, cooldown time.Duration) {
+	if threshold <= 0 {
+		s.breaker = nil
+		return
+	}
+	if cooldown <= 0 {
+		cooldown = defaultBreakerCooldown
+	}
+	s.breaker = &circuitBreaker{threshold: threshold, cooldown: cooldown, logger: s.logger}
+}
+
+// allow сообщает, можно ли выполнить запрос. Выключенный выключатель пропускает все запросы.
+func (b *circuitBreaker) allow() error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if wait := b.cooldown - time.Since(b.openedAt); wait > 0 {
+			return &CircuitOpenError{RetryAfter: wait, Cause: b.lastErr}
+		}
+		b.state = breakerHalfOpen
+		return nil
+	case breakerHalfOpen:
+		// Пока выполняется пробный запрос, остальные отклоняются
+		return &CircuitOpenError{RetryAfter: time.Second, Cause: b.lastErr}
+	default:
+		return nil
+	}
+}
+
+// record учитывает результат запроса. Ошибками считается только недоступность базы:
+// ошибки в данных запроса означают, что база отвечает, а отмена запроса вызывающим не учитывается.
+func (b *circuitBreaker) record(err error) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch {
+	case err != nil && !isUnavailable(err) && (errors.Is(err, context.Canceled) ||
+		errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrUnknownTenant)):
+		// Прерванный или не дошедший до базы запрос ничего не говорит о ее доступности;
+		// если он был пробным, следующий запрос станет новым пробным
+		if b.state == breakerHalfOpen {
+			b.state, b.openedAt = breakerOpen, time.Now().Add(-b.cooldown)
+		}
+		return
+	case err == nil || !isUnavailable(err):
+		if b.state != breakerClosed {
+			b.logger.Info("Основная база данных снова доступна, запросы возобновлены")
+		}
+		b.state, b.failures, b.lastErr = breakerClosed, 0, nil
+		return
+	}
+
+	b.failures++
+	b.lastErr = err
+	if b.state == breakerHalfOpen || b.failures >= b.threshold {
+		b.state, b.openedAt = breakerOpen, time.Now()
+		b.logger.WithError(err).WithField("cooldown", b.cooldown.String()).
+			Warn("Основная база данных недоступна, запросы приостановлены")
+	}
+}
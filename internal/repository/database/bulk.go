@@ -0,0 +1,386 @@
+package database
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/ArtemZ007/wb-l0/internal/domain/model"
+	"github.com/lib/pq"
+)
+
+// BulkResult описывает результат пакетного сохранения заказов.
+type BulkResult struct {
+	Inserted    int // Сохранено новых заказов
+	Overwritten int // Перезаписано существующих заказов
+	Skipped     int // Пропущено существующих заказов (дубликаты и устаревшие версии)
+}
+
+// uniqueViolation — код ошибки PostgreSQL о нарушении ограничения уникальности.
+const uniqueViolation = "23505"
+
+// stagingTables создает временные таблицы, в которые COPY загружает пакет заказов.
+// Таблицы удаляются при завершении транзакции.
+const stagingTables = `
+    CREATE TEMP TABLE bulk_orders (
+        order_uid UUID NOT NULL,
+        track_number TEXT,
+        entry TEXT,
+        delivery_id UUID,
+        payment_id UUID,
+        locale TEXT,
+        internal_signature TEXT,
+        customer_id TEXT,
+        delivery_service TEXT,
+        shardkey TEXT,
+        sm_id BIGINT,
+        date_created TEXT,
+        oof_shard TEXT,
+        document JSONB NOT NULL,
+        diff JSONB NOT NULL
+    ) ON COMMIT DROP;
+    CREATE TEMP TABLE bulk_deliveries (
+        id UUID NOT NULL,
+        name TEXT, phone TEXT, zip TEXT, city TEXT, address TEXT, region TEXT, email TEXT
+    ) ON COMMIT DROP;
+    CREATE TEMP TABLE bulk_payments (
+        id UUID NOT NULL,
+        transaction TEXT, request_id TEXT, currency TEXT, provider TEXT, amount BIGINT, payment_dt BIGINT,
+        bank TEXT, delivery_cost BIGINT, goods_total BIGINT, custom_fee BIGINT
+    ) ON COMMIT DROP;
+    CREATE TEMP TABLE bulk_items (
+        order_uid UUID NOT NULL,
+        position INT NOT NULL,
+        chrt_id BIGINT, track_number TEXT, price BIGINT, rid TEXT, name TEXT, sale INT, size TEXT,
+        total_price BIGINT, nm_id BIGINT, brand TEXT, status INT
+    ) ON COMMIT DROP;
+    CREATE TEMP TABLE bulk_inserted (order_uid UUID PRIMARY KEY) ON COMMIT DROP;`
+
+// mergeStaging переносит новые заказы из временных таблиц в нормализованные.
+// Новизна заказа определяется по order_keys, так как секционированная таблица orders
+// не может обеспечить уникальность order_uid и track_number. Существующие заказы не изменяются,
+// а заказы, номер отслеживания которых занят другим заказом, не вставляются.
+const mergeStaging = `
+    WITH inserted AS (
+        INSERT INTO order_keys (order_uid, track_number, date_created)
+        SELECT order_uid, track_number::uuid, date_created::timestamp
+        FROM bulk_orders
+        ON CONFLICT DO NOTHING
+        RETURNING order_uid
+    )
+    INSERT INTO bulk_inserted SELECT order_uid FROM inserted;
+
+    INSERT INTO deliveries (id, name, phone, zip, city, address, region, email)
+    SELECT d.id, d.name, d.phone, d.zip, d.city, d.address, d.region, d.email
+    FROM bulk_deliveries d
+    JOIN bulk_orders o ON o.delivery_id = d.id
+    JOIN bulk_inserted i ON i.order_uid = o.order_uid;
+
+    INSERT INTO payments (
+        id, transaction, request_id, currency, provider, amount, payment_dt, bank, delivery_cost, goods_total, custom_fee
+    )
+    SELECT p.id, p.transaction, p.request_id, p.currency, p.provider, p.amount, p.payment_dt, p.bank,
+        p.delivery_cost, p.goods_total, p.custom_fee
+    FROM bulk_payments p
+    JOIN bulk_orders o ON o.payment_id = p.id
+    JOIN bulk_inserted i ON i.order_uid = o.order_uid;
+
+    INSERT INTO orders (
+        order_uid, track_number, entry, delivery_id, payment_id, locale, internal_signature,
+        customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard
+    )
+    SELECT o.order_uid, o.track_number::uuid, o.entry, o.delivery_id, o.payment_id, o.locale, o.internal_signature,
+        o.customer_id::uuid, o.delivery_service, o.shardkey, o.sm_id, o.date_created::timestamp, o.oof_shard
+    FROM bulk_orders o
+    JOIN bulk_inserted i ON i.order_uid = o.order_uid;
+
+    INSERT INTO items (
+        order_uid, date_created, position, chrt_id, track_number, price, rid, name, sale, size,
+        total_price, nm_id, brand, status
+    )
+    SELECT it.order_uid, o.date_created::timestamp, it.position, it.chrt_id, it.track_number, it.price, it.rid,
+        it.name, it.sale, it.size, it.total_price, it.nm_id, it.brand, it.status
+    FROM bulk_items it
+    JOIN bulk_orders o ON o.order_uid = it.order_uid
+    JOIN bulk_inserted i ON i.order_uid = it.order_uid;`
+
+// SaveOrders сохраняет пакет заказов, загружая его через COPY во временные таблицы
+// и объединяя с нормализованными таблицами. Заказы каждого арендатора записываются
+// в его схему отдельной транзакцией вместе с обработкой существующих заказов по политике конфликтов;
+// заказы неизвестных арендаторов и заказы с занятым другим заказом номером отслеживания пропускаются.
+// При ошибке вместе с ней возвращается результат уже зафиксированных схем.
+func (s *Service) SaveOrders(ctx context.Context, orders []model.Order) (*BulkResult, error) {
+	orders = dedupeOrders(orders)
+	result := &BulkResult{}
+	if len(orders) == 0 {
+		return result, nil
+	}
+
+	var schemas []string
+	groups := make(map[string][]model.Order)
+	for _, order := range orders {
+		schema, err := s.tenants.Schema(orderTenant(&order))
+		if err != nil {
+			s.logger.WithError(err).WithField("orderUID", order.OrderUID).Warn("Заказ пропущен")
+			result.Skipped++
+			continue
+		}
+		if _, ok := groups[schema]; !ok {
+			schemas = append(schemas, schema)
+		}
+		groups[schema] = append(groups[schema], order)
+	}
+
+	for _, schema := range schemas {
+		if err := s.saveOrdersInSchema(WithSchema(ctx, schema), groups[schema], result); err != nil {
+			return result, err
+		}
+	}
+
+	s.logger.WithField("inserted", result.Inserted).WithField("overwritten", result.Overwritten).
+		WithField("skipped", result.Skipped).Info("Пакет заказов сохранен")
+	return result, nil
+}
+
+// saveOrdersInSchema сохраняет заказы одного арендатора в одной транзакции и добавляет итоги в result.
+func (s *Service) saveOrdersInSchema(ctx context.Context, orders []model.Order, result *BulkResult) error {
+	var inserted, overwritten, skipped int
+	err := s.withTx(ctx, nil, func(tx *sql.Tx) error {
+		inserted, overwritten, skipped = 0, 0, 0
+		if _, err := tx.ExecContext(ctx, stagingTables); err != nil {
+			return fmt.Errorf("ошибка при создании временных таблиц: %w", err)
+		}
+		if err := copyStaging(ctx, tx, orders); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, mergeStaging); err != nil {
+			return fmt.Errorf("ошибка при объединении пакета заказов: %w", err)
+		}
+
+		source := RevisionSourceFromContext(ctx)
+		if _, err := tx.ExecContext(ctx, `
+            INSERT INTO order_revisions (order_uid, revision, operation, source, source_ref, diff)
+            SELECT o.order_uid,
+                COALESCE((SELECT MAX(r.revision) FROM order_revisions r WHERE r.order_uid = o.order_uid), 0) + 1,
+                $1, $2, NULLIF($3, ''), o.diff
+            FROM bulk_orders o
+            JOIN bulk_inserted i ON i.order_uid = o.order_uid`,
+			model.OperationCreate, source.Kind, source.Ref); err != nil {
+			return fmt.Errorf("ошибка при записи ревизий пакета заказов: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, `
+            INSERT INTO outbox (event_type, order_uid, payload)
+            SELECT $1, o.order_uid, o.document
+            FROM bulk_orders o
+            JOIN bulk_inserted i ON i.order_uid = o.order_uid`,
+			model.EventOrderUpserted); err != nil {
+			return fmt.Errorf("ошибка при записи событий пакета заказов: %w", err)
+		}
+
+		existing, err := existingStaged(ctx, tx)
+		if err != nil {
+			return err
+		}
+		// Существующие заказы обрабатываются согласно политике конфликтов в той же транзакции
+		for i := range orders {
+			order := &orders[i]
+			known, ok := existing[order.OrderUID]
+			switch {
+			case !ok:
+				inserted++
+				continue
+			case !known || s.conflictPolicy == ConflictReject:
+				// Номер отслеживания заказа занят другим заказом либо дубликаты отклоняются
+				skipped++
+				continue
+			}
+			outcome, err := s.mergeStaged(ctx, tx, order)
+			if err != nil {
+				return err
+			}
+			if outcome.Changed() {
+				overwritten++
+			} else {
+				skipped++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		s.logger.WithError(err).Error("Ошибка при пакетном сохранении заказов")
+		return err
+	}
+	result.Inserted += inserted
+	result.Overwritten += overwritten
+	result.Skipped += skipped
+	return nil
+}
+
+// existingStaged возвращает заказы пакета, которые не были вставлены, с признаком того, что заказ
+// с таким order_uid уже сохранен. Заказ без такого признака не вставлен из-за занятого номера отслеживания.
+func existingStaged(ctx context.Context, tx *sql.Tx) (map[string]bool, error) {
+	rows, err := tx.QueryContext(ctx, `
+        SELECT o.order_uid, k.order_uid IS NOT NULL
+        FROM bulk_orders o
+        LEFT JOIN order_keys k ON k.order_uid = o.order_uid
+        WHERE NOT EXISTS (SELECT 1 FROM bulk_inserted i WHERE i.order_uid = o.order_uid)`)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при чтении существующих заказов пакета: %w", err)
+	}
+	defer rows.Close()
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var orderUID string
+		var known bool
+		if err := rows.Scan(&orderUID, &known); err != nil {
+			return nil, err
+		}
+		existing[orderUID] = known
+	}
+	return existing, rows.Err()
+}
+
+// mergeStaged применяет политику конфликтов к существующему заказу пакета внутри точки сохранения.
+// Если новый номер отслеживания заказа занят другим заказом, изменения заказа откатываются,
+// а заказ считается пропущенным, чтобы не прерывать весь пакет.
+func (s *Service) mergeStaged(ctx context.Context, tx *sql.Tx, order *model.Order) (SaveOutcome, error) {
+	if _, err := tx.ExecContext(ctx, "SAVEPOINT bulk_merge"); err != nil {
+		return "", fmt.Errorf("ошибка при создании точки сохранения: %w", err)
+	}
+	outcome, err := mergeOrder(ctx, tx, s.conflictPolicy, order)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+		s.logger.WithError(err).WithField("orderUID", order.OrderUID).Warn("Заказ пакета пропущен")
+		if _, err := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT bulk_merge"); err != nil {
+			return "", fmt.Errorf("ошибка при откате к точке сохранения: %w", err)
+		}
+		return OutcomeDuplicate, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if _, err := tx.ExecContext(ctx, "RELEASE SAVEPOINT bulk_merge"); err != nil {
+		return "", fmt.Errorf("ошибка при освобождении точки сохранения: %w", err)
+	}
+	return outcome, nil
+}
+
+// dedupeOrders оставляет последнюю версию каждого заказа, сохраняя порядок первого появления.
+func dedupeOrders(orders []model.Order) []model.Order {
+	index := make(map[string]int, len(orders))
+	unique := make([]model.Order, 0, len(orders))
+	for _, order := range orders {
+		if i, ok := index[order.OrderUID]; ok {
+			unique[i] = order
+			continue
+		}
+		index[order.OrderUID] = len(unique)
+		unique = append(unique, order)
+	}
+	return unique
+}
+
+// copyStaging загружает заказы во временные таблицы командой COPY.
+// Соединение поддерживает только одну активную команду COPY, поэтому таблицы загружаются по очереди.
+func copyStaging(ctx context.Context, tx *sql.Tx, orders []model.Order) error {
+	var orderRows, deliveryRows, paymentRows, itemRows [][]interface{}
+
+	for i := range orders {
+		order := &orders[i]
+		// Новый заказ получает первую версию; документ нужен только для новых заказов
+		order.Version = 1
+		document, err := json.Marshal(order)
+		if err != nil {
+			return fmt.Errorf("ошибка при сериализации заказа %s: %w", order.OrderUID, err)
+		}
+		diff, err := json.Marshal(model.DiffOrders(nil, order))
+		if err != nil {
+			return fmt.Errorf("ошибка при сериализации изменений заказа %s: %w", order.OrderUID, err)
+		}
+
+		var deliveryID, paymentID sql.NullString
+		if d := order.Delivery; d != nil {
+			deliveryID = sql.NullString{String: newUUID(), Valid: true}
+			deliveryRows = append(deliveryRows, []interface{}{
+				deliveryID.String, d.Name, d.Phone, d.Zip, d.City, d.Address, d.Region, d.Email,
+			})
+		}
+		if p := order.Payment; p != nil {
+			paymentID = sql.NullString{String: newUUID(), Valid: true}
+			paymentRows = append(paymentRows, []interface{}{
+				paymentID.String, p.Transaction, p.RequestID, p.Currency, p.Provider, p.Amount, p.PaymentDt, p.Bank,
+				p.DeliveryCost, p.GoodsTotal, p.CustomFee,
+			})
+		}
+		for position, item := range order.Items {
+			itemRows = append(itemRows, []interface{}{
+				order.OrderUID, position, item.ChrtID, item.TrackNumber, item.Price, item.RID, item.Name, item.Sale,
+				item.Size, item.TotalPrice, item.NmID, item.Brand, item.Status,
+			})
+		}
+		orderRows = append(orderRows, []interface{}{
+			order.OrderUID, order.TrackNumber, order.Entry, deliveryID, paymentID, order.Locale, order.InternalSignature,
+			order.CustomerID, order.DeliveryService, order.Shardkey, order.SMID, order.DateCreated, order.OofShard,
+			string(document), string(diff),
+		})
+	}
+
+	if err := copyRows(ctx, tx, "bulk_orders", []string{
+		"order_uid", "track_number", "entry", "delivery_id", "payment_id", "locale", "internal_signature",
+		"customer_id", "delivery_service", "shardkey", "sm_id", "date_created", "oof_shard", "document", "diff",
+	}, orderRows); err != nil {
+		return err
+	}
+	if err := copyRows(ctx, tx, "bulk_deliveries", []string{
+		"id", "name", "phone", "zip", "city", "address", "region", "email",
+	}, deliveryRows); err != nil {
+		return err
+	}
+	if err := copyRows(ctx, tx, "bulk_payments", []string{
+		"id", "transaction", "request_id", "currency", "provider", "amount", "payment_dt", "bank",
+		"delivery_cost", "goods_total", "custom_fee",
+	}, paymentRows); err != nil {
+		return err
+	}
+	return copyRows(ctx, tx, "bulk_items", []string{
+		"order_uid", "position", "chrt_id", "track_number", "price", "rid", "name", "sale", "size",
+		"total_price", "nm_id", "brand", "status",
+	}, itemRows)
+}
+
+// copyRows загружает строки в таблицу командой COPY.
+func copyRows(ctx context.Context, tx *sql.Tx, table string, columns []string, rows [][]interface{}) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	stmt, err := tx.PrepareContext(ctx, pq.CopyIn(table, columns...))
+	if err != nil {
+		return fmt.Errorf("ошибка при подготовке COPY в %s: %w", table, err)
+	}
+	defer stmt.Close()
+
+	for _, row := range rows {
+		if _, err := stmt.ExecContext(ctx, row...); err != nil {
+			return fmt.Errorf("ошибка при COPY в %s: %w", table, err)
+		}
+	}
+
+	// Вызов Exec без аргументов завершает передачу данных COPY
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		return fmt.Errorf("ошибка при завершении COPY в %s: %w", table, err)
+	}
+	return nil
+}
+
+// newUUID генерирует случайный UUID версии 4.
+func newUUID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
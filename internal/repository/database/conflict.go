@@ -0,0 +1,100 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/ArtemZ007/wb-l0/internal/domain/model"
+)
+
+// ConflictPolicy определяет поведение SaveOrder, когда заказ с таким order_uid уже существует.
+type ConflictPolicy string
+
+const (
+	// ConflictReject считает повторный заказ дубликатом и оставляет сохраненный без изменений.
+	ConflictReject ConflictPolicy = "reject"
+	// ConflictOverwrite перезаписывает сохраненный заказ новым.
+	ConflictOverwrite ConflictPolicy = "overwrite"
+	// ConflictLatestWins перезаписывает сохраненный заказ, только если новый создан позже.
+	ConflictLatestWins ConflictPolicy = "latest"
+)
+
+// ParseConflictPolicy разбирает политику разрешения конфликтов из строки конфигурации.
+func ParseConflictPolicy(value string) (ConflictPolicy, error) {
+	switch policy := ConflictPolicy(value); policy {
+	case ConflictReject, ConflictOverwrite, ConflictLatestWins:
+		return policy, nil
+	case "":
+		return ConflictReject, nil
+	default:
+		return "", fmt.Errorf("неизвестная политика конфликтов %q", value)
+	}
+}
+
+// SaveOutcome описывает результат SaveOrder.
+type SaveOutcome string
+
+const (
+	// OutcomeInserted — заказ сохранен впервые.
+	OutcomeInserted SaveOutcome = "inserted"
+	// OutcomeOverwritten — существующий заказ перезаписан.
+	OutcomeOverwritten SaveOutcome = "overwritten"
+	// OutcomeDuplicate — заказ уже существует и отклонен как дубликат.
+	OutcomeDuplicate SaveOutcome = "duplicate"
+	// OutcomeStale — существующий заказ новее полученного и оставлен без изменений.
+	OutcomeStale SaveOutcome = "stale"
+)
+
+// Changed сообщает, изменил ли SaveOrder данные в базе.
+func (o SaveOutcome) Changed() bool {
+	return o == OutcomeInserted || o == OutcomeOverwritten
+}
+
+// mergeOrder применяет политику p к заказу, который уже сохранен в базе, и при изменении заказа
+// записывает его ревизию и событие outbox.
+func mergeOrder(ctx context.Context, tx *sql.Tx, p ConflictPolicy, order *model.Order) (SaveOutcome, error) {
+	previous, err := lockOrder(ctx, tx, order.OrderUID)
+	if err != nil {
+		return "", err
+	}
+	outcome, err := resolveConflict(ctx, tx, p, order)
+	if err != nil || !outcome.Changed() {
+		return outcome, err
+	}
+	// Перезапись удаленного заказа восстанавливает его
+	operation := model.OperationUpdate
+	if previous == nil {
+		operation = model.OperationRestore
+	}
+	if err := recordRevision(ctx, tx, operation, order.OrderUID, previous, order); err != nil {
+		return "", err
+	}
+	return outcome, enqueueEvent(ctx, tx, model.EventOrderUpserted, order.OrderUID, order)
+}
+
+// resolveConflict применяет политику p к заказу, который уже сохранен в базе.
+func resolveConflict(ctx context.Context, tx *sql.Tx, p ConflictPolicy, order *model.Order) (SaveOutcome, error) {
+	switch p {
+	case ConflictOverwrite:
+		return OutcomeOverwritten, replaceOrder(ctx, tx, order)
+	case ConflictLatestWins:
+		var newer bool
+		err := tx.QueryRowContext(ctx,
+			"SELECT date_created < $2::timestamp FROM orders WHERE order_uid = $1 FOR UPDATE",
+			order.OrderUID, order.DateCreated).Scan(&newer)
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrOrderNotFound
+		}
+		if err != nil {
+			return "", fmt.Errorf("ошибка при сравнении даты создания заказа: %w", err)
+		}
+		if !newer {
+			return OutcomeStale, nil
+		}
+		return OutcomeOverwritten, replaceOrder(ctx, tx, order)
+	default:
+		return OutcomeDuplicate, nil
+	}
+}
@@ -0,0 +1,80 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultConnectBackoff    = time.Second      // Пауза перед второй попыткой подключения по умолчанию
+	defaultConnectMaxBackoff = 30 * time.Second // Максимальная пауза между попытками подключения по умолчанию
+)
+
+// PoolConfig задает параметры пула соединений с базой данных. Нулевые значения оставляют настройки database/sql.
+type PoolConfig struct {
+	MaxOpenConns    int           // Максимальное число открытых соединений
+	MaxIdleConns    int           // Максимальное число простаивающих соединений
+	ConnMaxLifetime time.Duration // Максимальное время жизни соединения
+	ConnMaxIdleTime time.Duration // Максимальное время простоя соединения
+}
+
+// Apply применяет параметры к пулу соединений db.
+func (c PoolConfig) Apply(db *sql.DB) {
+	if c.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(c.MaxOpenConns)
+	}
+	if c.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(c.MaxIdleConns)
+	}
+	if c.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(c.ConnMaxLifetime)
+	}
+	if c.ConnMaxIdleTime > 0 {
+		db.SetConnMaxIdleTime(c.ConnMaxIdleTime)
+	}
+}
+
+// RetryPolicy задает повторные попытки подключения к базе данных при старте.
+type RetryPolicy struct {
+	Attempts   int           // Число попыток; 0 и 1 означают одну попытку
+	Backoff    time.Duration // Пауза перед второй попыткой, удваивается после каждой неудачи
+	MaxBackoff time.Duration // Максимальная пауза между попытками
+}
+
+// Connect проверяет соединение с базой данных, повторяя попытки по политике policy,
+// пока база недоступна, например еще запускается. Ошибки, не связанные с доступностью
+// базы (неверный пароль, несуществующая база), возвращаются сразу.
+func Connect(ctx context.Context, db *sql.DB, policy RetryPolicy, logger *logrus.Logger) error {
+	backoff, maxBackoff := policy.Backoff, policy.MaxBackoff
+	if backoff <= 0 {
+		backoff = defaultConnectBackoff
+	}
+	if maxBackoff <= 0 {
+		maxBackoff = defaultConnectMaxBackoff
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := db.PingContext(ctx)
+		if err == nil {
+			return nil
+		}
+		if attempt >= policy.Attempts || !isUnavailable(err) {
+			return fmt.Errorf("не удалось подключиться к базе данных за %d попыток: %w", attempt, err)
+		}
+
+		logger.WithError(err).WithFields(logrus.Fields{"attempt": attempt, "backoff": backoff.String()}).
+			Warn("База данных недоступна, повтор подключения")
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
@@ -3,37 +3,52 @@ package database
 import (
 	"context"
 	"database/sql"
-	"fmt"
-	"os"
-	"path/filepath"
+	"time"
 
 	"github.com/ArtemZ007/wb-l0/internal/domain/model"
-	"github.com/ArtemZ007/wb-l0/internal/repository/cache"
+	"github.com/lib/pq"
 	"github.com/sirupsen/logrus"
 )
 
 // IOrderService определяет интерфейс для работы с заказами.
 type IOrderService interface {
 	GetOrder(ctx context.Context, orderUID string) (*model.Order, error)
-	SaveOrder(ctx context.Context, order *model.Order) error
+	ExistingOrders(ctx context.Context, orderUIDs []string) ([]string, error)
+	SaveOrder(ctx context.Context, order *model.Order) (SaveOutcome, error)
+	SaveOrders(ctx context.Context, orders []model.Order) (*BulkResult, error)
 	UpdateOrder(ctx context.Context, order *model.Order) error
 	DeleteOrder(ctx context.Context, orderUID string) error
-	ListOrders(ctx context.Context) ([]model.Order, error)
+	RestoreOrder(ctx context.Context, orderUID string) error
+	ListOrders(ctx context.Context, query model.ListQuery) (*model.OrderPage, error)
+	SearchOrders(ctx context.Context, query model.SearchQuery) ([]model.Order, error)
+	OrderHistory(ctx context.Context, orderUID string) ([]model.OrderRevision, error)
+	GetOrderAsOf(ctx context.Context, orderUID string, at time.Time) (*model.Order, error)
 	Start(ctx context.Context) error
 }
 
 // Service представляет собой реализацию IOrderService.
 type Service struct {
-	db     *sql.DB
-	cache  cache.Cache
-	logger *logrus.Logger
+	db             *sql.DB
+	logger         *logrus.Logger
+	conflictPolicy ConflictPolicy
+	replicas       *replicaPool
+	retention      *retentionPolicy
+	partitions     *partitionPolicy
+	tenants        *TenantSchemas
+	breaker        *circuitBreaker
 }
 
 // NewService создает новый экземпляр Service.
 func NewService(db *sql.DB, logger *logrus.Logger) (*Service, error) {
+	tenants, err := NewTenantSchemas(DefaultSchema)
+	if err != nil {
+		return nil, err
+	}
 	s := &Service{
-		db:     db,
-		logger: logger,
+		db:             db,
+		logger:         logger,
+		conflictPolicy: ConflictReject,
+		tenants:        tenants,
 	}
 
 	// Инициализация базы данных
@@ -44,118 +59,155 @@ func NewService(db *sql.DB, logger *logrus.Logger) (*Service, error) {
 	return s, nil
 }
 
-// SetCache устанавливает кэш для сервиса.
-func (s *Service) SetCache(cacheService cache.Cache) {
-	s.cache = cacheService
+// SetConflictPolicy устанавливает политику разрешения конфликтов для SaveOrder.
+func (s *Service) SetConflictPolicy(policy ConflictPolicy) {
+	s.conflictPolicy = policy
 }
 
-// initDB инициализирует базу данных, выполняя миграции.
+// initDB инициализирует базу данных, применяя встроенные миграции к схеме по умолчанию.
+// Схемы остальных арендаторов создаются в ProvisionTenants.
 func (s *Service) initDB() error {
-	cwd, err := os.Getwd()
-	if err != nil {
-		return fmt.Errorf("не удалось получить текущий рабочий каталог: %w", err)
-	}
-
-	// Построение абсолютного пути к файлу миграции
-	migrationFilePath := filepath.Join(cwd, "../../migrations/setup_db.sql")
-
-	// Выполнение миграции
-	if err := s.executeMigration(migrationFilePath); err != nil {
-		return fmt.Errorf("ошибка при выполнении миграции: %w", err)
-	}
-
-	return nil
-}
-
-// executeMigration выполняет миграцию базы данных из указанного файла.
-func (s *Service) executeMigration(filePath string) error {
-	content, err := os.ReadFile(filePath)
-	if err != nil {
-		s.logger.WithError(err).Error("Ошибка при чтении файла миграции")
+	if err := s.provisionSchema(context.Background(), DefaultSchema); err != nil {
 		return err
 	}
 
-	if _, err = s.db.Exec(string(content)); err != nil {
-		s.logger.WithError(err).Error("Ошибка при выполнении миграции")
-		return err
-	}
-
-	s.logger.Info("Миграция успешно выполнена")
+	s.logger.Info("Миграции успешно выполнены")
 	return nil
 }
 
 // GetOrder возвращает заказ по его уникальному идентификатору.
 func (s *Service) GetOrder(ctx context.Context, orderUID string) (*model.Order, error) {
-	// Проверка наличия заказа в кэше
-	if s.cache != nil {
-		if cachedOrder, found := s.cache.Get(orderUID); found {
-			s.logger.Info("Заказ найден в кэше", orderUID)
-			return cachedOrder, nil
-		}
-	}
-
-	query := "SELECT * FROM orders WHERE order_uid = $1"
-	row := s.db.QueryRowContext(ctx, query, orderUID)
-
-	var order model.Order
-	if err := row.Scan(&order.OrderUID, &order.TrackNumber, &order.Entry, &order.DeliveryService, &order.Shardkey, &order.SMID, &order.DateCreated, &order.OofShard, &order.CustomerID, &order.Locale); err != nil {
-		if err == sql.ErrNoRows {
-			s.logger.WithError(err).Error("Заказ не найден")
-			return nil, nil
-		}
+	var orders []model.Order
+	err := s.withReadTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		orders, err = queryOrders(ctx, tx, "WHERE o.order_uid = $1 AND o.deleted_at IS NULL", orderUID)
+		return err
+	})
+	if err != nil {
 		s.logger.WithError(err).Error("Ошибка при получении заказа")
 		return nil, err
 	}
-
-	// Сохранение заказа в кэше
-	if s.cache != nil {
-		s.cache.Set(order.OrderUID, &order)
+	if len(orders) == 0 {
+		s.logger.Info("Заказ не найден", orderUID)
+		return nil, nil
 	}
+	order := &orders[0]
 
 	s.logger.Info("Заказ успешно получен", order.OrderUID)
-	return &order, nil
+	return order, nil
 }
 
-// SaveOrder сохраняет заказ в базе данных.
-func (s *Service) SaveOrder(ctx context.Context, order *model.Order) error {
-	query := "INSERT INTO orders (order_uid, track_number, entry, delivery_service, shardkey, sm_id, date_created, oof_shard, customer_id, locale) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)"
-	_, err := s.db.ExecContext(ctx, query, order.OrderUID, order.TrackNumber, order.Entry, order.DeliveryService, order.Shardkey, order.SMID, order.DateCreated, order.OofShard, order.CustomerID, order.Locale)
+// ExistingOrders возвращает идентификаторы неудаленных заказов из orderUIDs.
+func (s *Service) ExistingOrders(ctx context.Context, orderUIDs []string) ([]string, error) {
+	var existing []string
+	err := s.withReadTx(ctx, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx,
+			"SELECT order_uid FROM orders WHERE order_uid = ANY($1) AND deleted_at IS NULL", pq.Array(orderUIDs))
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var orderUID string
+			if err := rows.Scan(&orderUID); err != nil {
+				return err
+			}
+			existing = append(existing, orderUID)
+		}
+		return rows.Err()
+	})
 	if err != nil {
-		s.logger.WithError(err).Error("Ошибка при сохранении заказа")
-		return err
+		s.logger.WithError(err).Error("Ошибка при проверке существования заказов")
+		return nil, err
 	}
+	return existing, nil
+}
 
-	// Сохранение заказа в кэше
-	if s.cache != nil {
-		s.cache.Set(order.OrderUID, order)
+// SaveOrder сохраняет заказ вместе с доставкой, оплатой и товарами в одной транзакции
+// в схеме арендатора, указанного в поле entry заказа.
+// Если заказ уже существует, он обрабатывается согласно политике конфликтов,
+// а результат сообщает вызывающему, что именно произошло.
+func (s *Service) SaveOrder(ctx context.Context, order *model.Order) (SaveOutcome, error) {
+	ctx = orderContext(ctx, order)
+	var outcome SaveOutcome
+	err := s.withTx(ctx, nil, func(tx *sql.Tx) error {
+		inserted, err := insertOrder(ctx, tx, order)
+		if err != nil {
+			return err
+		}
+		if inserted {
+			outcome = OutcomeInserted
+			if err := recordRevision(ctx, tx, model.OperationCreate, order.OrderUID, nil, order); err != nil {
+				return err
+			}
+			return enqueueEvent(ctx, tx, model.EventOrderUpserted, order.OrderUID, order)
+		}
+
+		outcome, err = mergeOrder(ctx, tx, s.conflictPolicy, order)
+		return err
+	})
+	if err != nil {
+		s.logger.WithError(err).Error("Ошибка при сохранении заказа")
+		return "", err
 	}
 
-	s.logger.Info("Заказ успешно сохранен", order.OrderUID)
-	return nil
+	s.logger.WithField("outcome", outcome).Info("Заказ успешно сохранен", order.OrderUID)
+	return outcome, nil
 }
 
-// UpdateOrder обновляет информацию о заказе.
+// UpdateOrder обновляет заказ вместе с доставкой, оплатой и товарами в одной транзакции
+// в схеме арендатора, указанного в поле entry заказа.
+// Если order.Version задана и не совпадает с текущей версией заказа, возвращает *VersionConflictError.
+// После успешного обновления order.Version содержит новую версию.
 func (s *Service) UpdateOrder(ctx context.Context, order *model.Order) error {
-	query := "UPDATE orders SET track_number = $2, entry = $3, delivery_service = $4, shardkey = $5, sm_id = $6, date_created = $7, oof_shard = $8, customer_id = $9, locale = $10 WHERE order_uid = $1"
-	_, err := s.db.ExecContext(ctx, query, order.OrderUID, order.TrackNumber, order.Entry, order.DeliveryService, order.Shardkey, order.SMID, order.DateCreated, order.OofShard, order.CustomerID, order.Locale)
+	ctx = orderContext(ctx, order)
+	err := s.withTx(ctx, nil, func(tx *sql.Tx) error {
+		previous, err := lockOrder(ctx, tx, order.OrderUID)
+		if err != nil {
+			return err
+		}
+		if previous == nil {
+			return ErrOrderNotFound
+		}
+		if order.Version != 0 && order.Version != previous.Version {
+			return &VersionConflictError{OrderUID: order.OrderUID, Expected: order.Version, Actual: previous.Version}
+		}
+		if err := replaceOrder(ctx, tx, order); err != nil {
+			return err
+		}
+		if err := recordRevision(ctx, tx, model.OperationUpdate, order.OrderUID, previous, order); err != nil {
+			return err
+		}
+		return enqueueEvent(ctx, tx, model.EventOrderUpserted, order.OrderUID, order)
+	})
 	if err != nil {
 		s.logger.WithError(err).Error("Ошибка при обновлении заказа")
 		return err
 	}
 
-	// Обновление заказа в кэше
-	if s.cache != nil {
-		s.cache.Set(order.OrderUID, order)
-	}
-
 	s.logger.Info("Заказ успешно обновлен", order.OrderUID)
 	return nil
 }
 
-// DeleteOrder удаляет заказ по его уникальному идентификатору.
+// DeleteOrder помечает заказ удаленным. Удаленный заказ исключается из чтения и кэша,
+// но остается в базе до окончательной очистки и может быть восстановлен через RestoreOrder.
 func (s *Service) DeleteOrder(ctx context.Context, orderUID string) error {
-	query := "DELETE FROM orders WHERE order_uid = $1"
-	_, err := s.db.ExecContext(ctx, query, orderUID)
+	err := s.withTx(ctx, nil, func(tx *sql.Tx) error {
+		previous, err := lockOrder(ctx, tx, orderUID)
+		if err != nil {
+			return err
+		}
+		if previous == nil {
+			return ErrOrderNotFound
+		}
+		if err := softDeleteOrder(ctx, tx, orderUID); err != nil {
+			return err
+		}
+		if err := recordRevision(ctx, tx, model.OperationDelete, orderUID, previous, nil); err != nil {
+			return err
+		}
+		return enqueueEvent(ctx, tx, model.EventOrderDeleted, orderUID, nil)
+	})
 	if err != nil {
 		s.logger.WithError(err).Error("Ошибка при удалении заказа")
 		return err
@@ -165,41 +217,70 @@ func (s *Service) DeleteOrder(ctx context.Context, orderUID string) error {
 	return nil
 }
 
-// ListOrders возвращает список всех заказов из базы данных.
-func (s *Service) ListOrders(ctx context.Context) ([]model.Order, error) {
-	query := `
-        SELECT
-            order_uid, track_number, entry, delivery_service, shardkey, sm_id, date_created, oof_shard, customer_id, locale
-        FROM
-            orders`
-	rows, err := s.db.QueryContext(ctx, query)
+// RestoreOrder восстанавливает заказ, удаленный через DeleteOrder.
+// Если удаленного заказа с таким идентификатором нет, возвращает ErrOrderNotFound.
+func (s *Service) RestoreOrder(ctx context.Context, orderUID string) error {
+	var order *model.Order
+	err := s.withTx(ctx, nil, func(tx *sql.Tx) error {
+		if err := restoreOrder(ctx, tx, orderUID); err != nil {
+			return err
+		}
+		var err error
+		order, err = lockOrder(ctx, tx, orderUID)
+		if err != nil {
+			return err
+		}
+		if err := recordRevision(ctx, tx, model.OperationRestore, orderUID, nil, order); err != nil {
+			return err
+		}
+		return enqueueEvent(ctx, tx, model.EventOrderUpserted, orderUID, order)
+	})
+	if err != nil {
+		s.logger.WithError(err).Error("Ошибка при восстановлении заказа")
+		return err
+	}
+
+	s.logger.Info("Заказ успешно восстановлен", orderUID)
+	return nil
+}
+
+// ListOrders возвращает страницу заказов, отобранных и упорядоченных согласно запросу.
+// Пагинация выполняется по ключу (date_created, order_uid), поэтому стоимость
+// выборки не зависит от номера страницы.
+func (s *Service) ListOrders(ctx context.Context, query model.ListQuery) (*model.OrderPage, error) {
+	where, args, err := buildListWhere(query)
 	if err != nil {
-		s.logger.WithError(err).Error("Ошибка при получении списка заказов")
 		return nil, err
 	}
-	defer rows.Close()
 
 	var orders []model.Order
-	for rows.Next() {
-		var order model.Order
-		if err := rows.Scan(&order.OrderUID, &order.TrackNumber, &order.Entry, &order.DeliveryService, &order.Shardkey, &order.SMID, &order.DateCreated, &order.OofShard, &order.CustomerID, &order.Locale); err != nil {
-			s.logger.WithError(err).Error("Ошибка при сканировании заказа")
-			return nil, err
-		}
-		orders = append(orders, order)
-	}
-
-	if err := rows.Err(); err != nil {
-		s.logger.WithError(err).Error("Ошибка при итерации по строкам")
+	err = s.withReadTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		orders, err = queryOrders(ctx, tx, where, args...)
+		return err
+	})
+	if err != nil {
+		s.logger.WithError(err).Error("Ошибка при получении списка заказов")
 		return nil, err
 	}
 
-	s.logger.Info("Список заказов успешно получен")
-	return orders, nil
+	page := NewOrderPage(orders, PageLimit(query.Limit))
+	s.logger.WithField("count", len(page.Orders)).Debug("Страница заказов успешно получена")
+	return page, nil
 }
 
-// Start запускает основную логику сервиса в фоновом режиме.
+// Start запускает фоновые процессы сервиса: проверку реплик для чтения, очистку устаревших заказов
+// и обслуживание секций.
 func (s *Service) Start(ctx context.Context) error {
+	if s.replicas != nil && len(s.replicas.replicas) > 0 {
+		go s.replicas.run(ctx)
+	}
+	if s.retention.enabled() {
+		go s.runRetention(ctx)
+	}
+	if s.partitions != nil {
+		go s.runPartitionMaintenance(ctx)
+	}
 	s.logger.Info("Сервис успешно запущен")
 	return nil
 }
@@ -0,0 +1,120 @@
+package database
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ArtemZ007/wb-l0/internal/domain/model"
+)
+
+const (
+	defaultPageSize = 100  // Размер страницы по умолчанию
+	maxPageSize     = 1000 // Максимальный размер страницы
+)
+
+// ErrInvalidCursor возвращается, когда курсор страницы не удается разобрать.
+var ErrInvalidCursor = errors.New("некорректный курсор страницы")
+
+// PageCursor хранит позицию последнего заказа страницы для keyset-пагинации.
+type PageCursor struct {
+	DateCreated string `json:"d"`
+	OrderUID    string `json:"u"`
+}
+
+// EncodeCursor кодирует позицию заказа в непрозрачную строку.
+func EncodeCursor(order model.Order) string {
+	data, _ := json.Marshal(PageCursor{DateCreated: order.DateCreated, OrderUID: order.OrderUID})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor восстанавливает позицию заказа из строки курсора.
+func DecodeCursor(cursor string) (*PageCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c PageCursor
+	if err := json.Unmarshal(data, &c); err != nil || c.DateCreated == "" || c.OrderUID == "" {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// PageLimit возвращает допустимый размер страницы.
+func PageLimit(limit int) int {
+	switch {
+	case limit <= 0:
+		return defaultPageSize
+	case limit > maxPageSize:
+		return maxPageSize
+	default:
+		return limit
+	}
+}
+
+// buildListWhere формирует условие WHERE, сортировку и ограничение выборки для ListQuery.
+// Выбирается на один заказ больше лимита, чтобы определить наличие следующей страницы.
+func buildListWhere(q model.ListQuery) (string, []interface{}, error) {
+	conditions := []string{"o.deleted_at IS NULL"}
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if q.Entry != "" {
+		conditions = append(conditions, "o.entry = "+arg(q.Entry))
+	}
+	if q.DeliveryService != "" {
+		conditions = append(conditions, "o.delivery_service = "+arg(q.DeliveryService))
+	}
+	if q.Locale != "" {
+		conditions = append(conditions, "o.locale = "+arg(q.Locale))
+	}
+	if q.CustomerID != "" {
+		conditions = append(conditions, "o.customer_id = "+arg(q.CustomerID))
+	}
+	if !q.CreatedFrom.IsZero() {
+		conditions = append(conditions, "o.date_created >= "+arg(q.CreatedFrom.UTC().Format(time.RFC3339Nano)))
+	}
+	if !q.CreatedTo.IsZero() {
+		conditions = append(conditions, "o.date_created < "+arg(q.CreatedTo.UTC().Format(time.RFC3339Nano)))
+	}
+
+	direction, op := "ASC", ">"
+	if q.Descending {
+		direction, op = "DESC", "<"
+	}
+
+	if q.Cursor != "" {
+		c, err := DecodeCursor(q.Cursor)
+		if err != nil {
+			return "", nil, err
+		}
+		conditions = append(conditions, fmt.Sprintf("(o.date_created, o.order_uid) %s (%s::timestamp, %s::uuid)",
+			op, arg(c.DateCreated), arg(c.OrderUID)))
+	}
+
+	var sb strings.Builder
+	sb.WriteString("WHERE ")
+	sb.WriteString(strings.Join(conditions, " AND "))
+	fmt.Fprintf(&sb, " ORDER BY o.date_created %s, o.order_uid %s LIMIT %s", direction, direction, arg(PageLimit(q.Limit)+1))
+	return sb.String(), args, nil
+}
+
+// NewOrderPage формирует страницу из выборки, содержащей до limit+1 заказов.
+func NewOrderPage(orders []model.Order, limit int) *model.OrderPage {
+	page := &model.OrderPage{Orders: orders}
+	if len(orders) > limit {
+		page.Orders = orders[:limit]
+		page.NextCursor = EncodeCursor(page.Orders[limit-1])
+	}
+	if page.Orders == nil {
+		page.Orders = []model.Order{}
+	}
+	return page
+}
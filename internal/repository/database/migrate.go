@@ -0,0 +1,359 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultSchema — схема, в которой по умолчанию хранятся заказы.
+const DefaultSchema = "ecommerce"
+
+// migrationLockID — ключ advisory-блокировки, исключающей параллельный запуск миграций.
+const migrationLockID = 7_294_001
+
+// migrationFilePattern описывает имя файла миграции: 0001_name.up.sql или 0001_name.down.sql.
+var migrationFilePattern = regexp.MustCompile(`^(\d+)_([a-zA-Z0-9_]+)\.(up|down)\.sql$`)
+
+// Migration описывает одну версию схемы.
+type Migration struct {
+	Version  int    // Номер версии
+	Name     string // Название миграции
+	Up       string // SQL применения
+	Down     string // SQL отката
+	Checksum string // Контрольная сумма SQL применения
+}
+
+// MigrationStatus описывает состояние миграции в базе данных.
+type MigrationStatus struct {
+	Migration
+	Applied   bool      // Миграция применена
+	AppliedAt time.Time // Время применения
+	Modified  bool      // Файл миграции изменился после применения
+}
+
+// Migrator применяет встроенные миграции к схеме и ведет учет в таблице schema_migrations.
+type Migrator struct {
+	db         *sql.DB
+	schema     string
+	migrations []Migration
+	logger     *logrus.Logger
+}
+
+// appliedMigration описывает строку таблицы schema_migrations.
+type appliedMigration struct {
+	checksum  string
+	appliedAt time.Time
+}
+
+// NewMigrator создает исполнитель миграций из файлов fsys для указанной схемы.
+func NewMigrator(db *sql.DB, fsys fs.FS, schema string, logger *logrus.Logger) (*Migrator, error) {
+	migrations, err := loadMigrations(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{
+		db:         db,
+		schema:     schema,
+		migrations: migrations,
+		logger:     logger,
+	}, nil
+}
+
+// loadMigrations читает и упорядочивает миграции по номеру версии.
+func loadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать каталог миграций: %w", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		match := migrationFilePattern.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+
+		version, err := strconv.Atoi(match[1])
+		if err != nil {
+			return nil, fmt.Errorf("некорректный номер миграции %s: %w", entry.Name(), err)
+		}
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("не удалось прочитать миграцию %s: %w", entry.Name(), err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("миграция %d объявлена под разными именами: %s и %s", version, m.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			m.Up = string(content)
+			sum := sha256.Sum256(content)
+			m.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("у миграции %d_%s отсутствует файл up", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Latest возвращает номер последней известной миграции.
+func (m *Migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Status возвращает состояние всех известных миграций.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	var statuses []MigrationStatus
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			status := MigrationStatus{Migration: migration}
+			if a, ok := applied[migration.Version]; ok {
+				status.Applied = true
+				status.AppliedAt = a.appliedAt
+				status.Modified = a.checksum != migration.Checksum
+			}
+			statuses = append(statuses, status)
+		}
+		return nil
+	})
+	return statuses, err
+}
+
+// Up применяет все неприменённые миграции.
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.To(ctx, m.Latest())
+}
+
+// Down откатывает steps последних примененных миграций.
+func (m *Migrator) Down(ctx context.Context, steps int) error {
+	if steps <= 0 {
+		return nil
+	}
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0 && steps > 0; i-- {
+			migration := m.migrations[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+			if err := m.revert(ctx, conn, migration); err != nil {
+				return err
+			}
+			steps--
+		}
+		return nil
+	})
+}
+
+// To приводит схему к указанной версии, применяя или откатывая миграции.
+func (m *Migrator) To(ctx context.Context, version int) error {
+	if version != 0 && m.find(version) == nil {
+		return fmt.Errorf("миграция %d не найдена", version)
+	}
+
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if err := m.verify(applied); err != nil {
+			return err
+		}
+
+		// Откат миграций выше целевой версии в обратном порядке
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			migration := m.migrations[i]
+			if _, ok := applied[migration.Version]; ok && migration.Version > version {
+				if err := m.revert(ctx, conn, migration); err != nil {
+					return err
+				}
+			}
+		}
+
+		// Применение недостающих миграций до целевой версии
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; !ok && migration.Version <= version {
+				if err := m.apply(ctx, conn, migration); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// find возвращает миграцию по номеру версии.
+func (m *Migrator) find(version int) *Migration {
+	for i := range m.migrations {
+		if m.migrations[i].Version == version {
+			return &m.migrations[i]
+		}
+	}
+	return nil
+}
+
+// verify проверяет, что примененные миграции не были изменены и известны исполнителю.
+func (m *Migrator) verify(applied map[int]appliedMigration) error {
+	for version, a := range applied {
+		migration := m.find(version)
+		if migration == nil {
+			return fmt.Errorf("в схеме %s применена неизвестная миграция %d", m.schema, version)
+		}
+		if migration.Checksum != a.checksum {
+			return fmt.Errorf("контрольная сумма миграции %d_%s не совпадает с примененной", version, migration.Name)
+		}
+	}
+	return nil
+}
+
+// withLock выполняет fn на выделенном соединении под advisory-блокировкой,
+// предварительно создавая схему и таблицу учета миграций.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("не удалось получить соединение для миграций: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLockID); err != nil {
+		return fmt.Errorf("не удалось получить блокировку миграций: %w", err)
+	}
+	defer func() {
+		if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLockID); err != nil {
+			m.logger.WithError(err).Error("Ошибка при снятии блокировки миграций")
+		}
+	}()
+
+	schema := pq.QuoteIdentifier(m.schema)
+	if _, err := conn.ExecContext(ctx, "CREATE SCHEMA IF NOT EXISTS "+schema); err != nil {
+		return fmt.Errorf("не удалось создать схему %s: %w", m.schema, err)
+	}
+	query := `
+        CREATE TABLE IF NOT EXISTS ` + schema + `.schema_migrations (
+            version BIGINT PRIMARY KEY,
+            name TEXT NOT NULL,
+            checksum TEXT NOT NULL,
+            applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+        )`
+	if _, err := conn.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("не удалось создать таблицу schema_migrations: %w", err)
+	}
+
+	return fn(conn)
+}
+
+// applied возвращает примененные миграции, индексированные по версии.
+func (m *Migrator) applied(ctx context.Context, conn *sql.Conn) (map[int]appliedMigration, error) {
+	rows, err := conn.QueryContext(ctx,
+		"SELECT version, checksum, applied_at FROM "+pq.QuoteIdentifier(m.schema)+".schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]appliedMigration)
+	for rows.Next() {
+		var version int
+		var a appliedMigration
+		if err := rows.Scan(&version, &a.checksum, &a.appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = a
+	}
+	return applied, rows.Err()
+}
+
+// apply применяет миграцию и фиксирует ее в schema_migrations в одной транзакции.
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, migration Migration) error {
+	err := m.inSchemaTx(ctx, conn, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, migration.Up); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx,
+			"INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)",
+			migration.Version, migration.Name, migration.Checksum)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("ошибка при применении миграции %d_%s: %w", migration.Version, migration.Name, err)
+	}
+
+	m.logger.WithFields(logrus.Fields{"schema": m.schema, "version": migration.Version, "name": migration.Name}).
+		Info("Миграция применена")
+	return nil
+}
+
+// revert откатывает миграцию и удаляет ее из schema_migrations в одной транзакции.
+func (m *Migrator) revert(ctx context.Context, conn *sql.Conn, migration Migration) error {
+	if migration.Down == "" {
+		return fmt.Errorf("у миграции %d_%s отсутствует файл down", migration.Version, migration.Name)
+	}
+
+	err := m.inSchemaTx(ctx, conn, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, migration.Down); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = $1", migration.Version)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("ошибка при откате миграции %d_%s: %w", migration.Version, migration.Name, err)
+	}
+
+	m.logger.WithFields(logrus.Fields{"schema": m.schema, "version": migration.Version, "name": migration.Name}).
+		Info("Миграция откачена")
+	return nil
+}
+
+// inSchemaTx выполняет fn в транзакции с search_path, указывающим на целевую схему.
+func (m *Migrator) inSchemaTx(ctx context.Context, conn *sql.Conn, fn func(tx *sql.Tx) error) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := setSearchPath(ctx, tx, m.schema); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,291 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/ArtemZ007/wb-l0/internal/domain/model"
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultOutboxBatchSize = 100              // Число событий, обрабатываемых за один проход
+	defaultOutboxAttempts  = 10               // Число попыток доставки события по умолчанию
+	outboxMaxBackoff       = 5 * time.Minute  // Максимальная задержка перед повторной попыткой
+	outboxRetention        = 24 * time.Hour   // Срок хранения обработанных событий
+	outboxCleanupInterval  = 10 * time.Minute // Периодичность удаления обработанных событий
+)
+
+// OutboxHandler применяет событие outbox, например обновляет кэш или публикует событие.
+// Обработчик должен быть идемпотентным: событие может быть доставлено повторно.
+type OutboxHandler func(ctx context.Context, event model.OrderEvent) error
+
+// enqueueEvent записывает событие об изменении заказа в outbox в рамках транзакции tx.
+func enqueueEvent(ctx context.Context, tx *sql.Tx, eventType, orderUID string, order *model.Order) error {
+	var payload sql.NullString
+	if order != nil {
+		data, err := json.Marshal(order)
+		if err != nil {
+			return fmt.Errorf("ошибка при сериализации события outbox: %w", err)
+		}
+		payload = sql.NullString{String: string(data), Valid: true}
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		"INSERT INTO outbox (event_type, order_uid, payload) VALUES ($1, $2, $3)",
+		eventType, orderUID, payload); err != nil {
+		return fmt.Errorf("ошибка при записи события outbox: %w", err)
+	}
+	return nil
+}
+
+// OutboxRelay доставляет события outbox обработчикам с повторными попытками.
+// Событие помечается обработанным только после успешного выполнения всех обработчиков,
+// что гарантирует доставку хотя бы один раз. События одного заказа доставляются по порядку.
+// Событие, исчерпавшее попытки доставки, откладывается (dead_at) с последней ошибкой в last_error,
+// чтобы не блокировать последующие события заказа.
+type OutboxRelay struct {
+	db           *sql.DB
+	logger       *logrus.Logger
+	handlers     []OutboxHandler
+	tenants      *TenantSchemas
+	pollInterval time.Duration
+	batchSize    int
+	maxAttempts  int // Число попыток доставки события; 0 снимает ограничение
+}
+
+// claimedEvent описывает выбранное для доставки событие outbox и ошибку его декодирования.
+type claimedEvent struct {
+	model.OrderEvent
+	err error // Ошибка декодирования события; такое событие не доставляется
+}
+
+// NewOutboxRelay создает новый экземпляр OutboxRelay.
+func NewOutboxRelay(db *sql.DB, logger *logrus.Logger, pollInterval time.Duration, batchSize int) *OutboxRelay {
+	if batchSize <= 0 {
+		batchSize = defaultOutboxBatchSize
+	}
+	if pollInterval <= 0 {
+		pollInterval = time.Second
+	}
+	return &OutboxRelay{
+		db:           db,
+		logger:       logger,
+		pollInterval: pollInterval,
+		batchSize:    batchSize,
+		maxAttempts:  defaultOutboxAttempts,
+	}
+}
+
+// SetMaxAttempts задает число попыток доставки события, после которого оно откладывается;
+// 0 снимает ограничение.
+func (r *OutboxRelay) SetMaxAttempts(attempts int) {
+	r.maxAttempts = max(attempts, 0)
+}
+
+// AddHandler добавляет обработчик событий.
+func (r *OutboxRelay) AddHandler(handler OutboxHandler) {
+	r.handlers = append(r.handlers, handler)
+}
+
+// SetTenants задает арендаторов, outbox схем которых обрабатывается.
+// По умолчанию обрабатывается только схема DefaultSchema.
+func (r *OutboxRelay) SetTenants(tenants *TenantSchemas) {
+	r.tenants = tenants
+}
+
+// schemas возвращает схемы, outbox которых обрабатывается.
+func (r *OutboxRelay) schemas() []string {
+	if r.tenants == nil {
+		return []string{DefaultSchema}
+	}
+	return r.tenants.All()
+}
+
+// Run обрабатывает события outbox до завершения контекста.
+func (r *OutboxRelay) Run(ctx context.Context) error {
+	r.logger.Info("Запущена доставка событий outbox")
+	ticker := time.NewTicker(r.pollInterval)
+	defer ticker.Stop()
+	lastCleanup := time.Now()
+
+	for {
+		cleanup := time.Since(lastCleanup) >= outboxCleanupInterval
+		for _, schema := range r.schemas() {
+			// Пакеты обрабатываются подряд, пока в outbox есть готовые события
+			for {
+				processed, err := r.ProcessBatch(ctx, schema)
+				if err != nil && ctx.Err() == nil {
+					r.logger.WithError(err).WithField("schema", schema).Error("Ошибка при обработке событий outbox")
+				}
+				if err != nil || processed < r.batchSize {
+					break
+				}
+			}
+
+			if cleanup {
+				r.cleanup(ctx, schema)
+			}
+		}
+		if cleanup {
+			lastCleanup = time.Now()
+		}
+
+		select {
+		case <-ctx.Done():
+			r.logger.Info("Доставка событий outbox остановлена")
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// ProcessBatch обрабатывает один пакет готовых событий outbox схемы schema и возвращает их количество.
+// Обработчики получают события в контексте схемы schema (см. WithSchema).
+func (r *OutboxRelay) ProcessBatch(ctx context.Context, schema string) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("не удалось начать транзакцию: %w", err)
+	}
+	defer func() {
+		// Откат после успешной фиксации не выполняет действий
+		_ = tx.Rollback()
+	}()
+
+	if err := setSearchPath(ctx, tx, schema); err != nil {
+		return 0, err
+	}
+
+	events, err := r.claim(ctx, tx)
+	if err != nil {
+		return 0, err
+	}
+
+	// Заказы, событие которых не удалось доставить: их последующие события ждут повторной попытки.
+	failed := make(map[string]bool)
+	for _, event := range events {
+		if failed[event.OrderUID] {
+			continue
+		}
+
+		err := event.err
+		if err == nil {
+			err = r.dispatch(WithSchema(ctx, schema), event.OrderEvent)
+		}
+		if err != nil {
+			failed[event.OrderUID] = true
+			if err := r.markFailed(ctx, tx, event.OrderEvent, err); err != nil {
+				return 0, err
+			}
+			continue
+		}
+
+		if _, err := tx.ExecContext(ctx, "UPDATE outbox SET processed_at = NOW() WHERE id = $1", event.ID); err != nil {
+			return 0, fmt.Errorf("ошибка при отметке события outbox: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("не удалось зафиксировать транзакцию: %w", err)
+	}
+	return len(events), nil
+}
+
+// claim блокирует пакет готовых событий, пропуская заблокированные другими экземплярами
+// и события, перед которыми у того же заказа есть необработанные.
+// Событие, которое не удалось декодировать, возвращается с ошибкой, чтобы отметить неудачную попытку
+// только его, а не всего пакета.
+func (r *OutboxRelay) claim(ctx context.Context, tx *sql.Tx) ([]claimedEvent, error) {
+	rows, err := tx.QueryContext(ctx, `
+        SELECT e.id, e.event_type, e.order_uid, e.payload, e.created_at
+        FROM outbox e
+        WHERE e.processed_at IS NULL
+            AND e.next_attempt_at <= NOW()
+            AND NOT EXISTS (
+                SELECT 1 FROM outbox p
+                WHERE p.order_uid = e.order_uid AND p.processed_at IS NULL AND p.id < e.id
+            )
+        ORDER BY e.id
+        LIMIT $1
+        FOR UPDATE SKIP LOCKED`, r.batchSize)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при выборке событий outbox: %w", err)
+	}
+	defer rows.Close()
+
+	var events []claimedEvent
+	for rows.Next() {
+		var event claimedEvent
+		var payload []byte
+		if err := rows.Scan(&event.ID, &event.Type, &event.OrderUID, &payload, &event.CreatedAt); err != nil {
+			return nil, err
+		}
+		if payload != nil {
+			event.Order = &model.Order{}
+			if err := json.Unmarshal(payload, event.Order); err != nil {
+				event.Order = nil
+				event.err = fmt.Errorf("ошибка при декодировании события outbox %d: %w", event.ID, err)
+			}
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+// dispatch передает событие всем обработчикам.
+func (r *OutboxRelay) dispatch(ctx context.Context, event model.OrderEvent) error {
+	for _, handler := range r.handlers {
+		if err := handler(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// markFailed увеличивает счетчик попыток и откладывает повторную доставку события с экспоненциальной
+// задержкой. Событие, исчерпавшее maxAttempts попыток, отмечается отложенным и обработанным:
+// оно больше не доставляется и не задерживает последующие события заказа.
+func (r *OutboxRelay) markFailed(ctx context.Context, tx *sql.Tx, event model.OrderEvent, cause error) error {
+	query := `
+        UPDATE outbox SET
+            attempts = attempts + 1,
+            next_attempt_at = NOW() + LEAST(POWER(2, attempts) * INTERVAL '1 second', $2 * INTERVAL '1 second'),
+            last_error = $3,
+            dead_at = CASE WHEN $4 > 0 AND attempts + 1 >= $4 THEN NOW() END,
+            processed_at = CASE WHEN $4 > 0 AND attempts + 1 >= $4 THEN NOW() END
+        WHERE id = $1
+        RETURNING attempts, dead_at IS NOT NULL`
+	var attempts int
+	var dead bool
+	if err := tx.QueryRowContext(ctx, query, event.ID, outboxMaxBackoff.Seconds(), cause.Error(), r.maxAttempts).
+		Scan(&attempts, &dead); err != nil {
+		return fmt.Errorf("ошибка при отметке неудачной доставки события outbox: %w", err)
+	}
+
+	entry := r.logger.WithError(cause).WithFields(logrus.Fields{"event": event.ID, "orderUID": event.OrderUID, "attempts": attempts})
+	if dead {
+		entry.Error("Событие outbox исчерпало попытки доставки и отложено")
+	} else {
+		entry.Warn("Ошибка доставки события outbox, будет выполнена повторная попытка")
+	}
+	return nil
+}
+
+// cleanup удаляет события outbox схемы schema, обработанные раньше срока хранения.
+// Отложенные события сохраняются для разбора.
+func (r *OutboxRelay) cleanup(ctx context.Context, schema string) {
+	res, err := r.db.ExecContext(ctx,
+		"DELETE FROM "+pq.QuoteIdentifier(schema)+".outbox WHERE processed_at < NOW() - $1 * INTERVAL '1 second' AND dead_at IS NULL",
+		outboxRetention.Seconds())
+	if err != nil {
+		r.logger.WithError(err).WithField("schema", schema).Error("Ошибка при очистке outbox")
+		return
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		r.logger.WithField("count", n).WithField("schema", schema).Debug("Обработанные события outbox удалены")
+	}
+}
@@ -0,0 +1,313 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/ArtemZ007/wb-l0/internal/domain/model"
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultPartitionPremake  = 3         // Число будущих месячных секций по умолчанию
+	defaultPartitionInterval = time.Hour // Периодичность обслуживания секций по умолчанию
+	partitionLockID          = 727011    // Ключ advisory-блокировки обслуживания секций
+)
+
+// partitionedTables перечисляет таблицы, секционированные по date_created с одинаковыми границами секций.
+var partitionedTables = []string{"items", "orders"}
+
+// partitionNamePattern разбирает имя месячной секции, например orders_p2024_05.
+var partitionNamePattern = regexp.MustCompile(`^(items|orders)_p(\d{4})_(\d{2})$`)
+
+// ExpiredPartitionAction определяет, что делать с секциями старше срока хранения.
+type ExpiredPartitionAction string
+
+const (
+	// PartitionDetach отсоединяет секцию, оставляя ее данные в отдельной таблице архива
+	// с суффиксом _archived и временем отсоединения, например orders_p2024_05_archived_20240801T000000.
+	PartitionDetach ExpiredPartitionAction = "detach"
+	// PartitionDrop отсоединяет и удаляет секцию вместе с доставками, оплатами и историей ее заказов.
+	PartitionDrop ExpiredPartitionAction = "drop"
+)
+
+// ParseExpiredPartitionAction разбирает действие над устаревшими секциями. Пустая строка означает PartitionDetach.
+func ParseExpiredPartitionAction(s string) (ExpiredPartitionAction, error) {
+	switch action := ExpiredPartitionAction(s); action {
+	case "":
+		return PartitionDetach, nil
+	case PartitionDetach, PartitionDrop:
+		return action, nil
+	default:
+		return "", fmt.Errorf("неизвестное действие над устаревшими секциями %q", s)
+	}
+}
+
+// partitionPolicy задает параметры обслуживания секций.
+type partitionPolicy struct {
+	premake   int                    // Число месяцев, для которых секции создаются заранее
+	retention time.Duration          // Срок хранения секции после ее окончания; 0 — секции не устаревают
+	action    ExpiredPartitionAction // Действие над устаревшими секциями
+	interval  time.Duration          // Периодичность обслуживания
+}
+
+// SetPartitioning задает параметры обслуживания месячных секций orders и items.
+// premake — число будущих месяцев, для которых секции создаются заранее,
+// retention — срок, после которого секция считается устаревшей (0 отключает удаление).
+// Обслуживание запускается в Start.
+func (s *Service) SetPartitioning(premake int, retention time.Duration, action ExpiredPartitionAction, interval time.Duration) {
+	if premake < 0 {
+		premake = defaultPartitionPremake
+	}
+	if interval <= 0 {
+		interval = defaultPartitionInterval
+	}
+	s.partitions = &partitionPolicy{premake: premake, retention: retention, action: action, interval: interval}
+}
+
+// runPartitionMaintenance периодически обслуживает секции до завершения контекста.
+func (s *Service) runPartitionMaintenance(ctx context.Context) {
+	ticker := time.NewTicker(s.partitions.interval)
+	defer ticker.Stop()
+	for {
+		if err := s.MaintainPartitions(ctx); err != nil && ctx.Err() == nil {
+			s.logger.WithError(err).Error("Ошибка при обслуживании секций заказов")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// MaintainPartitions обслуживает секции всех арендаторов: заранее создает секции будущих месяцев,
+// переносит строки из секции по умолчанию в месячные секции и отсоединяет или удаляет
+// секции старше срока хранения.
+func (s *Service) MaintainPartitions(ctx context.Context) error {
+	if s.partitions == nil {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	for _, schema := range s.tenants.All() {
+		schemaCtx := WithSchema(ctx, schema)
+		err := s.withTx(schemaCtx, nil, func(tx *sql.Tx) error {
+			// Несколько экземпляров сервиса не должны обслуживать секции одновременно
+			if _, err := tx.ExecContext(schemaCtx, "SELECT pg_advisory_xact_lock($1)", partitionLockID); err != nil {
+				return fmt.Errorf("не удалось получить блокировку обслуживания секций: %w", err)
+			}
+			if err := s.createPartitions(schemaCtx, tx, now); err != nil {
+				return err
+			}
+			return s.expirePartitions(schemaCtx, tx, now)
+		})
+		if err != nil {
+			return fmt.Errorf("ошибка при обслуживании секций схемы %s: %w", schema, err)
+		}
+	}
+	return nil
+}
+
+// createPartitions создает секции для текущего и будущих месяцев,
+// а также для месяцев, строки которых оказались в секции по умолчанию.
+func (s *Service) createPartitions(ctx context.Context, tx *sql.Tx, now time.Time) error {
+	months := make(map[time.Time]bool)
+	current := monthStart(now)
+	for i := 0; i <= s.partitions.premake; i++ {
+		months[current.AddDate(0, i, 0)] = true
+	}
+
+	rows, err := tx.QueryContext(ctx, "SELECT DISTINCT date_trunc('month', date_created) FROM orders_default")
+	if err != nil {
+		return fmt.Errorf("ошибка при выборке месяцев секции по умолчанию: %w", err)
+	}
+	for rows.Next() {
+		var month time.Time
+		if err := rows.Scan(&month); err != nil {
+			rows.Close()
+			return err
+		}
+		months[monthStart(month)] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	ordered := make([]time.Time, 0, len(months))
+	for month := range months {
+		ordered = append(ordered, month)
+	}
+	sort.Slice(ordered, func(i, j int) bool { return ordered[i].Before(ordered[j]) })
+
+	for _, month := range ordered {
+		for _, table := range partitionedTables {
+			if err := s.createPartition(ctx, tx, table, month); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// createPartition создает месячную секцию таблицы table, если она еще не присоединена.
+// Отсоединенная ранее таблица с именем секции переименовывается в архивную.
+// Строки этого месяца из секции по умолчанию переносятся в новую секцию до ее присоединения,
+// иначе PostgreSQL откажется присоединять секцию.
+func (s *Service) createPartition(ctx context.Context, tx *sql.Tx, table string, month time.Time) error {
+	name := partitionName(table, month)
+	var exists, attached bool
+	if err := tx.QueryRowContext(ctx, `
+        SELECT to_regclass($1) IS NOT NULL,
+            EXISTS (SELECT 1 FROM pg_inherits WHERE inhrelid = to_regclass($1) AND inhparent = to_regclass($2))`,
+		name, table).Scan(&exists, &attached); err != nil {
+		return fmt.Errorf("ошибка при проверке секции %s: %w", name, err)
+	}
+	if attached {
+		return nil
+	}
+	if exists {
+		if _, err := archivePartition(ctx, tx, name); err != nil {
+			return err
+		}
+	}
+
+	from, to := month.Format("2006-01-02"), month.AddDate(0, 1, 0).Format("2006-01-02")
+	partition, parent, fallback := pq.QuoteIdentifier(name), pq.QuoteIdentifier(table), pq.QuoteIdentifier(table+"_default")
+	statements := []string{
+		fmt.Sprintf("CREATE TABLE %s (LIKE %s INCLUDING DEFAULTS INCLUDING CONSTRAINTS)", partition, parent),
+		fmt.Sprintf("INSERT INTO %s SELECT * FROM %s WHERE date_created >= '%s' AND date_created < '%s'", partition, fallback, from, to),
+		fmt.Sprintf("DELETE FROM %s WHERE date_created >= '%s' AND date_created < '%s'", fallback, from, to),
+		fmt.Sprintf("ALTER TABLE %s ATTACH PARTITION %s FOR VALUES FROM ('%s') TO ('%s')", parent, partition, from, to),
+	}
+	for _, statement := range statements {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("ошибка при создании секции %s: %w", name, err)
+		}
+	}
+
+	s.logger.WithField("partition", name).Info("Создана секция")
+	return nil
+}
+
+// expirePartitions отсоединяет или удаляет секции, закончившиеся раньше срока хранения.
+func (s *Service) expirePartitions(ctx context.Context, tx *sql.Tx, now time.Time) error {
+	if s.partitions.retention <= 0 {
+		return nil
+	}
+	horizon := now.Add(-s.partitions.retention)
+
+	rows, err := tx.QueryContext(ctx,
+		"SELECT c.relname FROM pg_inherits i JOIN pg_class c ON c.oid = i.inhrelid WHERE i.inhparent = to_regclass('orders')")
+	if err != nil {
+		return fmt.Errorf("ошибка при выборке секций: %w", err)
+	}
+	var expired []time.Time
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		month, ok := parsePartitionName(name)
+		if ok && !month.AddDate(0, 1, 0).After(horizon) {
+			expired = append(expired, month)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, month := range expired {
+		if err := s.expirePartition(ctx, tx, month); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expirePartition отсоединяет секции месяца month. Заказы секции перестают быть доступны,
+// поэтому их ключи удаляются, а в outbox записываются события удаления для кэша.
+// При действии PartitionDrop секции удаляются вместе с доставками, оплатами и историей заказов.
+// Отсоединенные секции переименовываются в архивные, чтобы месяц можно было секционировать заново.
+func (s *Service) expirePartition(ctx context.Context, tx *sql.Tx, month time.Time) error {
+	archived := make(map[string]string, len(partitionedTables))
+	for _, table := range partitionedTables {
+		name := partitionName(table, month)
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s DETACH PARTITION %s",
+			pq.QuoteIdentifier(table), pq.QuoteIdentifier(name))); err != nil {
+			return fmt.Errorf("ошибка при отсоединении секции %s: %w", name, err)
+		}
+		archive, err := archivePartition(ctx, tx, name)
+		if err != nil {
+			return err
+		}
+		archived[table] = pq.QuoteIdentifier(archive)
+	}
+
+	orders := archived["orders"]
+	statements := []string{
+		"CREATE TEMP TABLE expired_orders AS SELECT order_uid, delivery_id, payment_id FROM " + orders,
+		"DELETE FROM order_keys WHERE order_uid IN (SELECT order_uid FROM expired_orders)",
+		"INSERT INTO outbox (event_type, order_uid) SELECT '" + model.EventOrderDeleted + "', order_uid FROM expired_orders",
+	}
+	if s.partitions.action == PartitionDrop {
+		statements = append(statements,
+			"DROP TABLE "+archived["items"],
+			"DROP TABLE "+orders,
+			"DELETE FROM deliveries WHERE id IN (SELECT delivery_id FROM expired_orders)",
+			"DELETE FROM payments WHERE id IN (SELECT payment_id FROM expired_orders)",
+			"DELETE FROM order_revisions WHERE order_uid IN (SELECT order_uid FROM expired_orders)",
+		)
+	}
+	statements = append(statements, "DROP TABLE expired_orders")
+
+	for _, statement := range statements {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("ошибка при очистке секции %s: %w", orders, err)
+		}
+	}
+
+	s.logger.WithFields(logrus.Fields{"month": month.Format("2006-01"), "action": s.partitions.action}).
+		Info("Устаревшие секции обработаны")
+	return nil
+}
+
+// archivePartition переименовывает отсоединенную секцию name в архивную таблицу и возвращает ее имя.
+func archivePartition(ctx context.Context, tx *sql.Tx, name string) (string, error) {
+	archive := name + "_archived_" + time.Now().UTC().Format("20060102T150405")
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s RENAME TO %s",
+		pq.QuoteIdentifier(name), pq.QuoteIdentifier(archive))); err != nil {
+		return "", fmt.Errorf("ошибка при архивации секции %s: %w", name, err)
+	}
+	return archive, nil
+}
+
+// partitionName возвращает имя месячной секции таблицы table.
+func partitionName(table string, month time.Time) string {
+	return fmt.Sprintf("%s_p%s", table, month.Format("2006_01"))
+}
+
+// parsePartitionName возвращает месяц секции по ее имени.
+func parsePartitionName(name string) (time.Time, bool) {
+	m := partitionNamePattern.FindStringSubmatch(name)
+	if m == nil {
+		return time.Time{}, false
+	}
+	month, err := time.Parse("2006_01", m[2]+"_"+m[3])
+	return month, err == nil
+}
+
+// monthStart возвращает начало месяца t в UTC.
+func monthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
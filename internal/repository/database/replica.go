@@ -0,0 +1,173 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultReplicaMaxLag        = 10 * time.Second // Допустимое отставание реплики по умолчанию
+	defaultReplicaCheckInterval = 5 * time.Second  // Периодичность проверки реплик по умолчанию
+)
+
+// replicaLagQuery возвращает, получает ли реплика WAL от основной базы, и ее отставание в секундах.
+// Реплика, воспроизведшая весь полученный WAL, считается не отстающей, но только пока процесс
+// приема WAL подключен к основной базе: у отключенной реплики полученный WAL тоже воспроизведен полностью.
+// Без роли pg_read_all_stats состояние процесса приема не видно, и проверяется только его наличие.
+const replicaLagQuery = `
+    SELECT
+        NOT pg_is_in_recovery() OR EXISTS (
+            SELECT 1 FROM pg_stat_wal_receiver
+            WHERE pid IS NOT NULL AND COALESCE(status, 'streaming') = 'streaming'
+        ),
+        CASE
+            WHEN NOT pg_is_in_recovery() THEN 0
+            WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+            ELSE COALESCE(EXTRACT(EPOCH FROM NOW() - pg_last_xact_replay_timestamp()), 0)
+        END`
+
+// primaryKey — ключ контекста, требующего чтения с основной базы.
+type primaryKey struct{}
+
+// WithPrimary возвращает контекст, в котором запросы на чтение выполняются на основной базе,
+// например когда отставание реплики привело бы к записи в кэш устаревших данных.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// replica описывает реплику базы данных и ее последнее известное состояние.
+type replica struct {
+	db      *sql.DB
+	name    string
+	healthy atomic.Bool
+}
+
+// replicaPool распределяет запросы на чтение между исправными репликами.
+type replicaPool struct {
+	replicas      []*replica
+	maxLag        time.Duration
+	checkInterval time.Duration
+	next          atomic.Uint64
+	logger        *logrus.Logger
+}
+
+// SetReplicas задает реплики для запросов на чтение.
+// Реплики начинают использоваться после первой проверки в Start; до этого чтение идет с основной базы.
+func (s *Service) SetReplicas(replicas []*sql.DB, maxLag, checkInterval time.Duration) {
+	if maxLag <= 0 {
+		maxLag = defaultReplicaMaxLag
+	}
+	if checkInterval <= 0 {
+		checkInterval = defaultReplicaCheckInterval
+	}
+
+	pool := &replicaPool{maxLag: maxLag, checkInterval: checkInterval, logger: s.logger}
+	for i, db := range replicas {
+		pool.replicas = append(pool.replicas, &replica{db: db, name: fmt.Sprintf("replica-%d", i+1)})
+	}
+	s.replicas = pool
+}
+
+// pick возвращает следующую исправную реплику по кругу или nil, если исправных нет.
+func (p *replicaPool) pick() *replica {
+	if p == nil || len(p.replicas) == 0 {
+		return nil
+	}
+	start := p.next.Add(1)
+	for i := range p.replicas {
+		r := p.replicas[(int(start)+i)%len(p.replicas)]
+		if r.healthy.Load() {
+			return r
+		}
+	}
+	return nil
+}
+
+// run периодически проверяет доступность и отставание реплик до завершения контекста.
+func (p *replicaPool) run(ctx context.Context) {
+	p.checkAll(ctx)
+
+	ticker := time.NewTicker(p.checkInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.checkAll(ctx)
+		}
+	}
+}
+
+// checkAll проверяет все реплики и обновляет их состояние.
+func (p *replicaPool) checkAll(ctx context.Context) {
+	for _, r := range p.replicas {
+		checkCtx, cancel := context.WithTimeout(ctx, p.checkInterval)
+		var receiving bool
+		var lagSeconds float64
+		err := r.db.QueryRowContext(checkCtx, replicaLagQuery).Scan(&receiving, &lagSeconds)
+		cancel()
+
+		lag := time.Duration(lagSeconds * float64(time.Second))
+		healthy := err == nil && receiving && lag <= p.maxLag
+		if was := r.healthy.Swap(healthy); was != healthy {
+			entry := p.logger.WithFields(logrus.Fields{"replica": r.name, "lag": lag.String()})
+			if healthy {
+				entry.Info("Реплика доступна для чтения")
+			} else if err != nil {
+				entry.WithError(err).Warn("Реплика недоступна, чтение переключено")
+			} else if !receiving {
+				entry.Warn("Реплика не получает WAL от основной базы, чтение переключено")
+			} else {
+				entry.Warn("Реплика отстает сильнее допустимого, чтение переключено")
+			}
+		}
+	}
+}
+
+// withReadTx выполняет fn в транзакции только для чтения на исправной реплике.
+// Если реплик нет, выбранная реплика недоступна или контекст требует чтения с основной базы
+// (см. WithPrimary), запрос выполняется на основной базе.
+func (s *Service) withReadTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	if primary, _ := ctx.Value(primaryKey{}).(bool); primary {
+		return s.withTx(ctx, readOnlyTx, fn)
+	}
+	if r := s.replicas.pick(); r != nil {
+		err := s.runTx(ctx, r.db, readOnlyTx, fn)
+		if err == nil || !isUnavailable(err) {
+			return err
+		}
+		r.healthy.Store(false)
+		s.logger.WithError(err).WithField("replica", r.name).Warn("Ошибка чтения с реплики, запрос повторяется на основной базе")
+	}
+	return s.withTx(ctx, readOnlyTx, fn)
+}
+
+// isUnavailable сообщает, что ошибка вызвана недоступностью сервера базы данных,
+// а не содержимым запроса.
+func isUnavailable(err error) bool {
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		code := string(pqErr.Code)
+		// 08 — ошибки соединения, 57P — остановка сервера, 53 — нехватка ресурсов
+		return strings.HasPrefix(code, "08") || strings.HasPrefix(code, "57P") || strings.HasPrefix(code, "53")
+	}
+	return false
+}
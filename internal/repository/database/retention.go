@@ -0,0 +1,142 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/ArtemZ007/wb-l0/internal/domain/model"
+	"github.com/lib/pq"
+)
+
+const (
+	defaultRetentionInterval = time.Hour // Периодичность очистки по умолчанию
+	purgeBatchSize           = 500       // Число заказов, удаляемых в одной транзакции
+)
+
+// retentionPolicy задает сроки окончательной очистки заказов.
+type retentionPolicy struct {
+	deletedFor time.Duration // Срок хранения удаленного заказа; 0 — удаленные заказы не очищаются
+	maxAge     time.Duration // Максимальный возраст заказа по date_created; 0 — без ограничения
+	interval   time.Duration // Периодичность очистки
+}
+
+// SetRetention задает сроки окончательной очистки заказов.
+// deletedFor — сколько хранится заказ после DeleteOrder, maxAge — предельный возраст любого заказа.
+// Нулевое значение отключает соответствующее правило; очистка запускается в Start.
+func (s *Service) SetRetention(deletedFor, maxAge, interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultRetentionInterval
+	}
+	s.retention = &retentionPolicy{deletedFor: deletedFor, maxAge: maxAge, interval: interval}
+}
+
+// enabled сообщает, задано ли хотя бы одно правило очистки.
+func (p *retentionPolicy) enabled() bool {
+	return p != nil && (p.deletedFor > 0 || p.maxAge > 0)
+}
+
+// runRetention периодически очищает заказы до завершения контекста.
+func (s *Service) runRetention(ctx context.Context) {
+	ticker := time.NewTicker(s.retention.interval)
+	defer ticker.Stop()
+	for {
+		if _, err := s.PurgeExpiredOrders(ctx); err != nil && ctx.Err() == nil {
+			s.logger.WithError(err).Error("Ошибка при очистке устаревших заказов")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// PurgeExpiredOrders окончательно удаляет заказы всех арендаторов, вышедшие за сроки хранения,
+// вместе с их историей.
+// Для каждого заказа в outbox записывается событие удаления, по которому заказ удаляется из кэша.
+// Возвращает число удаленных заказов.
+func (s *Service) PurgeExpiredOrders(ctx context.Context) (int, error) {
+	if !s.retention.enabled() {
+		return 0, nil
+	}
+
+	var deletedBefore sql.NullTime
+	if s.retention.deletedFor > 0 {
+		deletedBefore = sql.NullTime{Time: time.Now().Add(-s.retention.deletedFor), Valid: true}
+	}
+	var createdBefore sql.NullString
+	if s.retention.maxAge > 0 {
+		createdBefore = sql.NullString{String: time.Now().Add(-s.retention.maxAge).UTC().Format(time.RFC3339Nano), Valid: true}
+	}
+
+	total := 0
+	for _, schema := range s.tenants.All() {
+		schemaCtx := WithSchema(ctx, schema)
+		for {
+			var purged int
+			err := s.withTx(schemaCtx, nil, func(tx *sql.Tx) error {
+				var err error
+				purged, err = purgeOrders(schemaCtx, tx, deletedBefore, createdBefore)
+				return err
+			})
+			total += purged
+			if err != nil {
+				return total, err
+			}
+			if purged < purgeBatchSize {
+				break
+			}
+		}
+	}
+
+	if total > 0 {
+		s.logger.WithField("count", total).Info("Устаревшие заказы окончательно удалены")
+	}
+	return total, nil
+}
+
+// purgeOrders удаляет один пакет заказов, удаленных раньше deletedBefore или созданных раньше createdBefore.
+// Заказы, заблокированные другими транзакциями, пропускаются до следующего прохода.
+func purgeOrders(ctx context.Context, tx *sql.Tx, deletedBefore sql.NullTime, createdBefore sql.NullString) (int, error) {
+	rows, err := tx.QueryContext(ctx, `
+        SELECT order_uid
+        FROM orders
+        WHERE deleted_at < $1 OR date_created < $2::timestamp
+        LIMIT $3
+        FOR UPDATE SKIP LOCKED`, deletedBefore, createdBefore, purgeBatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка при выборке устаревших заказов: %w", err)
+	}
+	var uids []string
+	for rows.Next() {
+		var uid string
+		if err := rows.Scan(&uid); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		uids = append(uids, uid)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, uid := range uids {
+		if err := deleteOrder(ctx, tx, uid); err != nil {
+			return 0, err
+		}
+		if err := enqueueEvent(ctx, tx, model.EventOrderDeleted, uid, nil); err != nil {
+			return 0, err
+		}
+	}
+	if len(uids) > 0 {
+		if _, err := tx.ExecContext(ctx,
+			"DELETE FROM order_revisions WHERE order_uid = ANY($1::uuid[])", pq.Array(uids)); err != nil {
+			return 0, fmt.Errorf("ошибка при удалении истории заказов: %w", err)
+		}
+	}
+	return len(uids), nil
+}
@@ -0,0 +1,167 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ArtemZ007/wb-l0/internal/domain/model"
+)
+
+// revisionSourceKey — ключ контекста для источника изменения заказа.
+type revisionSourceKey struct{}
+
+// WithRevisionSource возвращает контекст, в котором изменения заказов приписываются источнику source.
+func WithRevisionSource(ctx context.Context, source model.RevisionSource) context.Context {
+	return context.WithValue(ctx, revisionSourceKey{}, source)
+}
+
+// RevisionSourceFromContext возвращает источник изменения из контекста.
+// Если источник не задан, изменение приписывается внутреннему процессу сервиса.
+func RevisionSourceFromContext(ctx context.Context) model.RevisionSource {
+	if source, ok := ctx.Value(revisionSourceKey{}).(model.RevisionSource); ok && source.Kind != "" {
+		return source
+	}
+	return model.RevisionSource{Kind: model.SourceSystem}
+}
+
+// lockOrder блокирует заказ до конца транзакции и возвращает его текущее состояние.
+// Если заказ не существует или удален, возвращает nil.
+func lockOrder(ctx context.Context, tx *sql.Tx, orderUID string) (*model.Order, error) {
+	orders, err := queryOrders(ctx, tx, "WHERE o.order_uid = $1 AND o.deleted_at IS NULL FOR UPDATE OF o", orderUID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при блокировке заказа: %w", err)
+	}
+	if len(orders) == 0 {
+		return nil, nil
+	}
+	return &orders[0], nil
+}
+
+// recordRevision записывает ревизию заказа с предыдущим документом и вычисленными изменениями.
+func recordRevision(ctx context.Context, tx *sql.Tx, operation, orderUID string, previous, current *model.Order) error {
+	var previousDoc sql.NullString
+	if previous != nil {
+		data, err := json.Marshal(previous)
+		if err != nil {
+			return fmt.Errorf("ошибка при сериализации предыдущей версии заказа: %w", err)
+		}
+		previousDoc = sql.NullString{String: string(data), Valid: true}
+	}
+	diff, err := json.Marshal(model.DiffOrders(previous, current))
+	if err != nil {
+		return fmt.Errorf("ошибка при сериализации изменений заказа: %w", err)
+	}
+
+	source := RevisionSourceFromContext(ctx)
+	query := `
+        INSERT INTO order_revisions (order_uid, revision, operation, source, source_ref, previous, diff)
+        SELECT $1, COALESCE(MAX(revision), 0) + 1, $2, $3, NULLIF($4, ''), $5, $6
+        FROM order_revisions
+        WHERE order_uid = $1`
+	if _, err := tx.ExecContext(ctx, query, orderUID, operation, source.Kind, source.Ref, previousDoc, string(diff)); err != nil {
+		return fmt.Errorf("ошибка при записи ревизии заказа: %w", err)
+	}
+	return nil
+}
+
+// OrderHistory возвращает историю изменений заказа от старых ревизий к новым.
+func (s *Service) OrderHistory(ctx context.Context, orderUID string) ([]model.OrderRevision, error) {
+	query := `
+        SELECT order_uid, revision, operation, source, COALESCE(source_ref, ''), previous, diff, changed_at
+        FROM order_revisions
+        WHERE order_uid = $1
+        ORDER BY revision`
+
+	revisions := []model.OrderRevision{}
+	err := s.withReadTx(ctx, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, query, orderUID)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			revision, err := scanRevision(rows)
+			if err != nil {
+				return err
+			}
+			revisions = append(revisions, *revision)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		s.logger.WithError(err).Error("Ошибка при получении истории заказа")
+		return nil, err
+	}
+	return revisions, nil
+}
+
+// GetOrderAsOf возвращает состояние заказа на момент at.
+// Если заказ в этот момент не существовал, возвращает nil. Заказ существовал на момент at, если
+// до него записана ревизия заказа, а для заказа без истории — если он создан не позже at.
+func (s *Service) GetOrderAsOf(ctx context.Context, orderUID string, at time.Time) (*model.Order, error) {
+	var order *model.Order
+	err := s.withReadTx(ctx, func(tx *sql.Tx) error {
+		// Первая ревизия после момента at хранит документ, актуальный на этот момент.
+		var previous []byte
+		err := tx.QueryRowContext(ctx, `
+            SELECT previous
+            FROM order_revisions
+            WHERE order_uid = $1 AND changed_at > $2
+            ORDER BY revision
+            LIMIT 1`, orderUID, at).Scan(&previous)
+		if errors.Is(err, sql.ErrNoRows) {
+			// После момента at заказ не менялся, значит актуально текущее состояние,
+			// если заказ к этому моменту уже существовал.
+			orders, err := queryOrders(ctx, tx, `
+                WHERE o.order_uid = $1 AND o.deleted_at IS NULL AND (
+                    EXISTS (SELECT 1 FROM order_revisions r WHERE r.order_uid = o.order_uid AND r.changed_at <= $2)
+                    OR NOT EXISTS (SELECT 1 FROM order_revisions r WHERE r.order_uid = o.order_uid)
+                        AND o.date_created <= ($2::timestamptz AT TIME ZONE 'UTC'))`, orderUID, at.UTC())
+			if err != nil || len(orders) == 0 {
+				return err
+			}
+			order = &orders[0]
+			return nil
+		}
+		if err != nil || previous == nil {
+			return err
+		}
+
+		order = &model.Order{}
+		if err := json.Unmarshal(previous, order); err != nil {
+			return fmt.Errorf("ошибка при декодировании ревизии заказа: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		s.logger.WithError(err).Error("Ошибка при получении состояния заказа на момент времени")
+		return nil, err
+	}
+	return order, nil
+}
+
+// scanRevision считывает строку order_revisions в модель ревизии.
+func scanRevision(rows *sql.Rows) (*model.OrderRevision, error) {
+	var revision model.OrderRevision
+	var previous, diff []byte
+	if err := rows.Scan(&revision.OrderUID, &revision.Revision, &revision.Operation, &revision.Source.Kind,
+		&revision.Source.Ref, &previous, &diff, &revision.ChangedAt); err != nil {
+		return nil, err
+	}
+
+	if previous != nil {
+		revision.Previous = &model.Order{}
+		if err := json.Unmarshal(previous, revision.Previous); err != nil {
+			return nil, err
+		}
+	}
+	if err := json.Unmarshal(diff, &revision.Diff); err != nil {
+		return nil, err
+	}
+	return &revision, nil
+}
@@ -0,0 +1,113 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/ArtemZ007/wb-l0/internal/domain/model"
+)
+
+const (
+	defaultSearchLimit = 50  // Число результатов поиска по умолчанию
+	maxSearchLimit     = 500 // Максимальное число результатов поиска
+)
+
+// ErrEmptySearchQuery возвращается, когда в запросе поиска не задан ни один критерий.
+var ErrEmptySearchQuery = errors.New("не задан ни один критерий поиска")
+
+// uuidPattern проверяет формат UUID для полей, хранящихся в базе с типом UUID.
+var uuidPattern = regexp.MustCompile("^[a-fA-F0-9]{8}-[a-fA-F0-9]{4}-[a-fA-F0-9]{4}-[a-fA-F0-9]{4}-[a-fA-F0-9]{12}$")
+
+// likeEscaper экранирует спецсимволы шаблона LIKE в пользовательском вводе.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// SearchOrders ищет заказы по номеру отслеживания, клиенту, контактам получателя,
+// транзакции оплаты и товарам. Результаты упорядочены от новых заказов к старым.
+func (s *Service) SearchOrders(ctx context.Context, query model.SearchQuery) ([]model.Order, error) {
+	if query.IsEmpty() {
+		return nil, ErrEmptySearchQuery
+	}
+
+	// Значение, не являющееся UUID, не может совпасть со столбцом типа UUID
+	if (query.TrackNumber != "" && !uuidPattern.MatchString(query.TrackNumber)) ||
+		(query.CustomerID != "" && !uuidPattern.MatchString(query.CustomerID)) {
+		return []model.Order{}, nil
+	}
+
+	where, args := buildSearchWhere(query)
+	var orders []model.Order
+	err := s.withReadTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		orders, err = queryOrders(ctx, tx, where, args...)
+		return err
+	})
+	if err != nil {
+		s.logger.WithError(err).Error("Ошибка при поиске заказов")
+		return nil, err
+	}
+
+	if orders == nil {
+		orders = []model.Order{}
+	}
+	s.logger.WithField("count", len(orders)).Debug("Поиск заказов выполнен")
+	return orders, nil
+}
+
+// buildSearchWhere формирует условие WHERE, сортировку и ограничение для SearchQuery.
+func buildSearchWhere(q model.SearchQuery) (string, []interface{}) {
+	conditions := []string{"o.deleted_at IS NULL"}
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if q.TrackNumber != "" {
+		conditions = append(conditions, "o.track_number = "+arg(q.TrackNumber)+"::uuid")
+	}
+	if q.CustomerID != "" {
+		conditions = append(conditions, "o.customer_id = "+arg(q.CustomerID)+"::uuid")
+	}
+	if q.Phone != "" {
+		conditions = append(conditions, "d.phone = "+arg(q.Phone))
+	}
+	if q.Email != "" {
+		conditions = append(conditions, "lower(d.email) = lower("+arg(q.Email)+")")
+	}
+	if q.Transaction != "" {
+		conditions = append(conditions, "p.transaction = "+arg(q.Transaction))
+	}
+	if q.Brand != "" {
+		conditions = append(conditions, "EXISTS (SELECT 1 FROM items i WHERE i.order_uid = o.order_uid AND i.brand ILIKE "+
+			arg("%"+likeEscaper.Replace(q.Brand)+"%")+")")
+	}
+	if q.NmID != 0 {
+		conditions = append(conditions, "EXISTS (SELECT 1 FROM items i WHERE i.order_uid = o.order_uid AND i.nm_id = "+
+			arg(q.NmID)+")")
+	}
+	if q.Text != "" {
+		pattern := arg("%" + likeEscaper.Replace(q.Text) + "%")
+		conditions = append(conditions, fmt.Sprintf(
+			"(d.name ILIKE %[1]s OR d.city ILIKE %[1]s OR d.address ILIKE %[1]s OR "+
+				"EXISTS (SELECT 1 FROM items i WHERE i.order_uid = o.order_uid AND i.name ILIKE %[1]s))", pattern))
+	}
+
+	return fmt.Sprintf("WHERE %s ORDER BY o.date_created DESC, o.order_uid DESC LIMIT %s",
+		strings.Join(conditions, " AND "), arg(SearchLimit(q.Limit))), args
+}
+
+// SearchLimit возвращает допустимое число результатов поиска.
+func SearchLimit(limit int) int {
+	switch {
+	case limit <= 0:
+		return defaultSearchLimit
+	case limit > maxSearchLimit:
+		return maxSearchLimit
+	default:
+		return limit
+	}
+}
@@ -0,0 +1,37 @@
+package database_test
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/ArtemZ007/wb-l0/internal/repository/database"
+	"github.com/ArtemZ007/wb-l0/internal/repository/ordertest"
+	"github.com/sirupsen/logrus"
+)
+
+// TestServiceContract выполняет общий набор проверок над базой данных из TEST_DATABASE_DSN.
+// Миграции применяются к схеме по умолчанию этой базы, поэтому для теста нужна отдельная база.
+func TestServiceContract(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN не задан")
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	svc, err := database.NewService(db, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ordertest.Run(context.Background(), svc); err != nil {
+		t.Fatal(err)
+	}
+}
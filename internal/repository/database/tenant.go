@@ -0,0 +1,210 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ArtemZ007/wb-l0/internal/domain/model"
+	"github.com/ArtemZ007/wb-l0/migrations"
+	"github.com/lib/pq"
+)
+
+// ErrUnknownTenant возвращается, когда для арендатора не задана схема и схема по умолчанию отключена.
+var ErrUnknownTenant = errors.New("неизвестный арендатор")
+
+// schemaNamePattern ограничивает имена схем арендаторов простыми идентификаторами.
+var schemaNamePattern = regexp.MustCompile(`^[a-z_][a-z0-9_]{0,62}$`)
+
+// tenantKey — ключ контекста для арендатора запроса.
+type tenantKey struct{}
+
+// schemaKey — ключ контекста для явно выбранной схемы.
+type schemaKey struct{}
+
+// WithTenant возвращает контекст, в котором запросы на чтение выполняются в схеме арендатора tenant.
+// Арендатор совпадает со значением поля entry заказа.
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantFromContext возвращает арендатора из контекста или пустую строку.
+func TenantFromContext(ctx context.Context) string {
+	tenant, _ := ctx.Value(tenantKey{}).(string)
+	return tenant
+}
+
+// WithSchema возвращает контекст, в котором запросы выполняются в схеме schema
+// независимо от арендатора. Используется фоновыми процессами, обходящими все схемы.
+func WithSchema(ctx context.Context, schema string) context.Context {
+	return context.WithValue(ctx, schemaKey{}, schema)
+}
+
+// TenantSchemas сопоставляет арендаторам схемы базы данных.
+// Арендаторы без явной схемы используют схему по умолчанию; пустая схема по умолчанию
+// означает, что такие арендаторы отклоняются с ErrUnknownTenant.
+type TenantSchemas struct {
+	mu            sync.RWMutex
+	schemas       map[string]string
+	defaultSchema string
+}
+
+// NewTenantSchemas создает сопоставление арендаторов со схемой по умолчанию defaultSchema.
+func NewTenantSchemas(defaultSchema string) (*TenantSchemas, error) {
+	if defaultSchema != "" && !schemaNamePattern.MatchString(defaultSchema) {
+		return nil, fmt.Errorf("некорректное имя схемы %q", defaultSchema)
+	}
+	return &TenantSchemas{schemas: make(map[string]string), defaultSchema: defaultSchema}, nil
+}
+
+// ParseTenantSchemas создает сопоставление из пар вида "арендатор=схема".
+func ParseTenantSchemas(pairs []string, defaultSchema string) (*TenantSchemas, error) {
+	tenants, err := NewTenantSchemas(defaultSchema)
+	if err != nil {
+		return nil, err
+	}
+	for _, pair := range pairs {
+		tenant, schema, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("некорректное сопоставление арендатора %q, ожидается арендатор=схема", pair)
+		}
+		if err := tenants.Set(strings.TrimSpace(tenant), strings.TrimSpace(schema)); err != nil {
+			return nil, err
+		}
+	}
+	return tenants, nil
+}
+
+// Set задает схему арендатора.
+func (t *TenantSchemas) Set(tenant, schema string) error {
+	if tenant == "" {
+		return errors.New("не задан арендатор")
+	}
+	if !schemaNamePattern.MatchString(schema) {
+		return fmt.Errorf("некорректное имя схемы %q", schema)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.schemas[tenant] = schema
+	return nil
+}
+
+// Schema возвращает схему арендатора.
+func (t *TenantSchemas) Schema(tenant string) (string, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if schema, ok := t.schemas[tenant]; ok {
+		return schema, nil
+	}
+	if t.defaultSchema == "" {
+		return "", fmt.Errorf("%w: %q", ErrUnknownTenant, tenant)
+	}
+	return t.defaultSchema, nil
+}
+
+// All возвращает все схемы арендаторов, включая схему по умолчанию, в алфавитном порядке.
+func (t *TenantSchemas) All() []string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	unique := make(map[string]bool, len(t.schemas)+1)
+	if t.defaultSchema != "" {
+		unique[t.defaultSchema] = true
+	}
+	for _, schema := range t.schemas {
+		unique[schema] = true
+	}
+
+	schemas := make([]string, 0, len(unique))
+	for schema := range unique {
+		schemas = append(schemas, schema)
+	}
+	sort.Strings(schemas)
+	return schemas
+}
+
+// SetTenants задает сопоставление арендаторов со схемами.
+// Схемы создаются и обновляются миграциями в ProvisionTenants.
+func (s *Service) SetTenants(tenants *TenantSchemas) {
+	s.tenants = tenants
+}
+
+// Tenants возвращает сопоставление арендаторов со схемами.
+func (s *Service) Tenants() *TenantSchemas {
+	return s.tenants
+}
+
+// ProvisionTenants применяет миграции ко всем схемам арендаторов.
+func (s *Service) ProvisionTenants(ctx context.Context) error {
+	for _, schema := range s.tenants.All() {
+		if err := s.provisionSchema(ctx, schema); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddTenant создает схему нового арендатора и начинает направлять в нее его заказы.
+func (s *Service) AddTenant(ctx context.Context, tenant, schema string) error {
+	if !schemaNamePattern.MatchString(schema) {
+		return fmt.Errorf("некорректное имя схемы %q", schema)
+	}
+	if err := s.provisionSchema(ctx, schema); err != nil {
+		return err
+	}
+	if err := s.tenants.Set(tenant, schema); err != nil {
+		return err
+	}
+	s.logger.WithField("tenant", tenant).WithField("schema", schema).Info("Арендатор добавлен")
+	return nil
+}
+
+// provisionSchema применяет встроенные миграции к схеме schema.
+func (s *Service) provisionSchema(ctx context.Context, schema string) error {
+	migrator, err := NewMigrator(s.db, migrations.FS, schema, s.logger)
+	if err != nil {
+		return fmt.Errorf("ошибка при загрузке миграций: %w", err)
+	}
+	if err := migrator.Up(ctx); err != nil {
+		s.logger.WithError(err).WithField("schema", schema).Error("Ошибка при выполнении миграций схемы арендатора")
+		return fmt.Errorf("ошибка при выполнении миграций схемы %s: %w", schema, err)
+	}
+	return nil
+}
+
+// Schema возвращает схему, в которой выполняются запросы контекста ctx: явно выбранную WithSchema
+// или схему арендатора из WithTenant.
+func (s *Service) Schema(ctx context.Context) (string, error) {
+	if schema, ok := ctx.Value(schemaKey{}).(string); ok && schema != "" {
+		return schema, nil
+	}
+	return s.tenants.Schema(TenantFromContext(ctx))
+}
+
+// orderContext возвращает контекст для записи заказа в схему арендатора, указанного в заказе.
+func orderContext(ctx context.Context, order *model.Order) context.Context {
+	if _, ok := ctx.Value(schemaKey{}).(string); ok {
+		return ctx
+	}
+	return WithTenant(ctx, orderTenant(order))
+}
+
+// orderTenant возвращает арендатора заказа — значение поля entry.
+func orderTenant(order *model.Order) string {
+	if order.Entry == nil {
+		return ""
+	}
+	return *order.Entry
+}
+
+// setSearchPath направляет неквалифицированные имена таблиц транзакции tx в схему schema.
+func setSearchPath(ctx context.Context, tx *sql.Tx, schema string) error {
+	if _, err := tx.ExecContext(ctx, "SET LOCAL search_path TO "+pq.QuoteIdentifier(schema)+", public"); err != nil {
+		return fmt.Errorf("не удалось выбрать схему %s: %w", schema, err)
+	}
+	return nil
+}
@@ -0,0 +1,223 @@
+package filestore
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ArtemZ007/wb-l0/internal/domain/model"
+	"github.com/ArtemZ007/wb-l0/internal/repository/database"
+)
+
+// ListOrders возвращает страницу заказов, отобранных и упорядоченных согласно запросу.
+// Порядок и курсоры совпадают с database.Service: заказы упорядочены по (date_created, order_uid).
+func (s *Store) ListOrders(ctx context.Context, query model.ListQuery) (*model.OrderPage, error) {
+	var after *database.PageCursor
+	if query.Cursor != "" {
+		c, err := database.DecodeCursor(query.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		after = c
+	}
+
+	s.mu.RLock()
+	orders := s.collect(func(o *model.Order) bool {
+		return matchesList(o, query)
+	})
+	s.mu.RUnlock()
+
+	sortOrders(orders, query.Descending)
+	if after != nil {
+		cursor := &model.Order{OrderUID: after.OrderUID, DateCreated: after.DateCreated}
+		start := sort.Search(len(orders), func(i int) bool {
+			if query.Descending {
+				return lessOrder(&orders[i], cursor)
+			}
+			return lessOrder(cursor, &orders[i])
+		})
+		orders = orders[start:]
+	}
+
+	limit := database.PageLimit(query.Limit)
+	if len(orders) > limit+1 {
+		orders = orders[:limit+1]
+	}
+	page := database.NewOrderPage(orders, limit)
+	s.logger.WithField("count", len(page.Orders)).Debug("Страница заказов успешно получена")
+	return page, nil
+}
+
+// SearchOrders ищет заказы по тем же критериям, что и database.Service.
+// Результаты упорядочены от новых заказов к старым.
+func (s *Store) SearchOrders(ctx context.Context, query model.SearchQuery) ([]model.Order, error) {
+	if query.IsEmpty() {
+		return nil, database.ErrEmptySearchQuery
+	}
+
+	s.mu.RLock()
+	orders := s.collect(func(o *model.Order) bool {
+		return matchesSearch(o, query)
+	})
+	s.mu.RUnlock()
+
+	sortOrders(orders, true)
+	if limit := database.SearchLimit(query.Limit); len(orders) > limit {
+		orders = orders[:limit]
+	}
+	s.logger.WithField("count", len(orders)).Debug("Поиск заказов выполнен")
+	return orders, nil
+}
+
+// OrderHistory возвращает историю изменений заказа от старых ревизий к новым.
+func (s *Store) OrderHistory(ctx context.Context, orderUID string) ([]model.OrderRevision, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	history := s.data.Revisions[orderUID]
+	revisions := make([]model.OrderRevision, len(history))
+	for i, revision := range history {
+		revision.Previous = cloneOrder(revision.Previous)
+		revisions[i] = revision
+	}
+	return revisions, nil
+}
+
+// GetOrderAsOf возвращает состояние заказа на момент at.
+// Если заказ в этот момент не существовал, возвращает nil.
+func (s *Store) GetOrderAsOf(ctx context.Context, orderUID string, at time.Time) (*model.Order, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	// Первая ревизия после момента at хранит документ, актуальный на этот момент.
+	revisions := s.data.Revisions[orderUID]
+	for _, revision := range revisions {
+		if revision.ChangedAt.After(at) {
+			return cloneOrder(revision.Previous), nil
+		}
+	}
+	// После момента at заказ не менялся: актуально текущее состояние, если заказ к этому моменту
+	// уже существовал. Для заказа без истории это определяется по дате создания.
+	r := s.active(orderUID)
+	if r == nil || len(revisions) == 0 && createdAt(&r.Order).After(at) {
+		return nil, nil
+	}
+	return cloneOrder(&r.Order), nil
+}
+
+// collect возвращает копии активных заказов, удовлетворяющих условию match.
+// Вызывается под блокировкой чтения.
+func (s *Store) collect(match func(o *model.Order) bool) []model.Order {
+	orders := []model.Order{}
+	for _, r := range s.data.Orders {
+		if r.DeletedAt == nil && match(&r.Order) {
+			orders = append(orders, *cloneOrder(&r.Order))
+		}
+	}
+	return orders
+}
+
+// sortOrders упорядочивает заказы по (date_created, order_uid).
+func sortOrders(orders []model.Order, descending bool) {
+	sort.Slice(orders, func(i, j int) bool {
+		if descending {
+			return lessOrder(&orders[j], &orders[i])
+		}
+		return lessOrder(&orders[i], &orders[j])
+	})
+}
+
+// lessOrder сравнивает заказы по дате создания, а при ее совпадении — по order_uid.
+func lessOrder(a, b *model.Order) bool {
+	ta, tb := createdAt(a), createdAt(b)
+	if !ta.Equal(tb) {
+		return ta.Before(tb)
+	}
+	return strings.ToLower(a.OrderUID) < strings.ToLower(b.OrderUID)
+}
+
+// matchesList проверяет фильтры ListQuery.
+func matchesList(o *model.Order, q model.ListQuery) bool {
+	if q.Entry != "" && value(o.Entry) != q.Entry {
+		return false
+	}
+	if q.DeliveryService != "" && value(o.DeliveryService) != q.DeliveryService {
+		return false
+	}
+	if q.Locale != "" && value(o.Locale) != q.Locale {
+		return false
+	}
+	if q.CustomerID != "" && !strings.EqualFold(value(o.CustomerID), q.CustomerID) {
+		return false
+	}
+	created := createdAt(o)
+	if !q.CreatedFrom.IsZero() && created.Before(q.CreatedFrom) {
+		return false
+	}
+	if !q.CreatedTo.IsZero() && !created.Before(q.CreatedTo) {
+		return false
+	}
+	return true
+}
+
+// matchesSearch проверяет критерии SearchQuery. Критерии объединяются через И.
+func matchesSearch(o *model.Order, q model.SearchQuery) bool {
+	var d model.Delivery
+	if o.Delivery != nil {
+		d = *o.Delivery
+	}
+	var p model.Payment
+	if o.Payment != nil {
+		p = *o.Payment
+	}
+
+	if q.TrackNumber != "" && !strings.EqualFold(value(o.TrackNumber), q.TrackNumber) {
+		return false
+	}
+	if q.CustomerID != "" && !strings.EqualFold(value(o.CustomerID), q.CustomerID) {
+		return false
+	}
+	if q.Phone != "" && value(d.Phone) != q.Phone {
+		return false
+	}
+	if q.Email != "" && !strings.EqualFold(value(d.Email), q.Email) {
+		return false
+	}
+	if q.Transaction != "" && value(p.Transaction) != q.Transaction {
+		return false
+	}
+	if q.Brand != "" && !anyItem(o, func(item model.Item) bool { return containsFold(value(item.Brand), q.Brand) }) {
+		return false
+	}
+	if q.NmID != 0 && !anyItem(o, func(item model.Item) bool { return item.NmID != nil && *item.NmID == q.NmID }) {
+		return false
+	}
+	if q.Text != "" && !containsFold(value(d.Name), q.Text) && !containsFold(value(d.City), q.Text) &&
+		!containsFold(value(d.Address), q.Text) &&
+		!anyItem(o, func(item model.Item) bool { return containsFold(value(item.Name), q.Text) }) {
+		return false
+	}
+	return true
+}
+
+// anyItem сообщает, есть ли в заказе товар, удовлетворяющий условию match.
+func anyItem(o *model.Order, match func(item model.Item) bool) bool {
+	for _, item := range o.Items {
+		if match(item) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsFold проверяет вхождение подстроки без учета регистра, как ILIKE.
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+// value возвращает значение строкового поля или пустую строку.
+func value(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,484 @@
+// Package filestore реализует database.IOrderService поверх файла JSON.
+// Хранилище предназначено для локального запуска и демонстрации без PostgreSQL:
+// все данные держатся в памяти и сохраняются в файл после каждого изменения.
+package filestore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/ArtemZ007/wb-l0/internal/domain/model"
+	"github.com/ArtemZ007/wb-l0/internal/repository/database"
+	"github.com/sirupsen/logrus"
+)
+
+// record описывает сохраненный заказ.
+type record struct {
+	Order     model.Order `json:"order"`                // Документ заказа
+	DeletedAt *time.Time  `json:"deleted_at,omitempty"` // Время удаления, пусто для активного заказа
+}
+
+// snapshot описывает содержимое файла хранилища.
+type snapshot struct {
+	Orders    map[string]*record               `json:"orders"`    // Заказы по order_uid
+	Revisions map[string][]model.OrderRevision `json:"revisions"` // История заказов по order_uid
+	EventID   int64                            `json:"event_id"`  // Идентификатор последнего события
+}
+
+// Store — встроенное хранилище заказов, реализующее database.IOrderService.
+// Разделение по арендаторам не поддерживается: заказы всех арендаторов хранятся вместе.
+// События об изменениях передаются обработчику сразу после записи, без outbox.
+type Store struct {
+	mu             sync.RWMutex
+	path           string
+	data           snapshot
+	conflictPolicy database.ConflictPolicy
+	onEvent        database.OutboxHandler
+	dispatched     chan struct{} // Закрывается после доставки событий последней записанной операции
+	logger         *logrus.Logger
+}
+
+// NewStore создает хранилище в файле path и загружает из него сохраненные заказы.
+// Пустой path означает хранилище только в памяти.
+func NewStore(path string, logger *logrus.Logger) (*Store, error) {
+	s := &Store{
+		path:           path,
+		conflictPolicy: database.ConflictReject,
+		dispatched:     make(chan struct{}),
+		logger:         logger,
+		data: snapshot{
+			Orders:    make(map[string]*record),
+			Revisions: make(map[string][]model.OrderRevision),
+		},
+	}
+	close(s.dispatched)
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// SetConflictPolicy устанавливает политику разрешения конфликтов для SaveOrder.
+func (s *Store) SetConflictPolicy(policy database.ConflictPolicy) {
+	s.conflictPolicy = policy
+}
+
+// SetEventHandler задает обработчик событий об изменении заказов, например обновление кэша.
+func (s *Store) SetEventHandler(handler database.OutboxHandler) {
+	s.onEvent = handler
+}
+
+// load читает состояние хранилища из файла, если он существует.
+func (s *Store) load() error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка при чтении файла хранилища: %w", err)
+	}
+	if err := json.Unmarshal(data, &s.data); err != nil {
+		return fmt.Errorf("ошибка при разборе файла хранилища: %w", err)
+	}
+	if s.data.Orders == nil {
+		s.data.Orders = make(map[string]*record)
+	}
+	if s.data.Revisions == nil {
+		s.data.Revisions = make(map[string][]model.OrderRevision)
+	}
+	s.logger.WithField("count", len(s.data.Orders)).Info("Заказы загружены из файла хранилища")
+	return nil
+}
+
+// persist атомарно записывает состояние хранилища в файл: сначала во временный файл,
+// затем переименованием поверх основного.
+func (s *Store) persist() error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := json.Marshal(&s.data)
+	if err != nil {
+		return fmt.Errorf("ошибка при сериализации хранилища: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("ошибка при создании временного файла хранилища: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("ошибка при записи файла хранилища: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("ошибка при записи файла хранилища: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("ошибка при записи файла хранилища: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("ошибка при замене файла хранилища: %w", err)
+	}
+	return nil
+}
+
+// change накапливает изменения одной операции, чтобы откатить их, если запись в файл не удалась.
+type change struct {
+	store     *Store
+	orders    map[string]*record
+	revisions map[string][]model.OrderRevision
+	eventID   int64
+	events    []model.OrderEvent
+}
+
+// begin начинает операцию изменения. Вызывается под блокировкой записи.
+func (s *Store) begin() *change {
+	return &change{
+		store:     s,
+		orders:    make(map[string]*record),
+		revisions: make(map[string][]model.OrderRevision),
+		eventID:   s.data.EventID,
+	}
+}
+
+// put заменяет запись заказа. Записи не изменяются на месте, чтобы откат мог вернуть прежние.
+func (c *change) put(uid string, r *record) {
+	if _, ok := c.orders[uid]; !ok {
+		c.orders[uid] = c.store.data.Orders[uid]
+	}
+	c.store.data.Orders[uid] = r
+}
+
+// revision записывает ревизию заказа и событие о его изменении.
+func (c *change) revision(ctx context.Context, operation, uid string, previous, current *model.Order) {
+	if _, ok := c.revisions[uid]; !ok {
+		c.revisions[uid] = c.store.data.Revisions[uid]
+	}
+	history := c.store.data.Revisions[uid]
+	revision := model.OrderRevision{
+		OrderUID:  uid,
+		Revision:  len(history) + 1,
+		Operation: operation,
+		Source:    database.RevisionSourceFromContext(ctx),
+		Previous:  cloneOrder(previous),
+		Diff:      model.DiffOrders(previous, current),
+		ChangedAt: time.Now().UTC(),
+	}
+	// Новый срез, чтобы не затронуть общий массив прежней истории
+	c.store.data.Revisions[uid] = append(append([]model.OrderRevision(nil), history...), revision)
+
+	event := model.OrderEvent{Type: model.EventOrderUpserted, OrderUID: uid, Order: cloneOrder(current), CreatedAt: revision.ChangedAt}
+	if current == nil {
+		event.Type = model.EventOrderDeleted
+	}
+	c.store.data.EventID++
+	event.ID = c.store.data.EventID
+	c.events = append(c.events, event)
+}
+
+// commit сохраняет изменения в файл, а при ошибке возвращает хранилище в исходное состояние.
+func (c *change) commit() error {
+	if len(c.orders) == 0 {
+		return nil
+	}
+	if err := c.store.persist(); err != nil {
+		c.rollback()
+		return err
+	}
+	return nil
+}
+
+// rollback отменяет изменения операции.
+func (c *change) rollback() {
+	for uid, r := range c.orders {
+		if r == nil {
+			delete(c.store.data.Orders, uid)
+		} else {
+			c.store.data.Orders[uid] = r
+		}
+	}
+	for uid, history := range c.revisions {
+		if history == nil {
+			delete(c.store.data.Revisions, uid)
+		} else {
+			c.store.data.Revisions[uid] = history
+		}
+	}
+	c.store.data.EventID = c.eventID
+	c.events = nil
+}
+
+// unlockAndDispatch освобождает блокировку записи и передает события операции c обработчику,
+// если операция зафиксирована без ошибки err. Очередь доставки занимается до освобождения блокировки,
+// поэтому события разных операций доставляются в порядке изменений, а обработчик, который читает
+// хранилище или ожидает его чтения, не блокирует запись. Вызывается под блокировкой записи.
+func (s *Store) unlockAndDispatch(ctx context.Context, c *change, err error) {
+	if err != nil || len(c.events) == 0 || s.onEvent == nil {
+		s.mu.Unlock()
+		return
+	}
+	previous, done := s.dispatched, make(chan struct{})
+	s.dispatched = done
+	s.mu.Unlock()
+
+	defer close(done)
+	<-previous
+	s.dispatch(ctx, c.events)
+}
+
+// dispatch передает события обработчику. Ошибки обработчика не отменяют изменения, а только логируются.
+func (s *Store) dispatch(ctx context.Context, events []model.OrderEvent) {
+	if s.onEvent == nil {
+		return
+	}
+	for _, event := range events {
+		if err := s.onEvent(ctx, event); err != nil {
+			s.logger.WithError(err).WithField("orderUID", event.OrderUID).Error("Ошибка при обработке события заказа")
+		}
+	}
+}
+
+// active возвращает активный (не удаленный) заказ или nil.
+func (s *Store) active(uid string) *record {
+	if r := s.data.Orders[uid]; r != nil && r.DeletedAt == nil {
+		return r
+	}
+	return nil
+}
+
+// GetOrder возвращает заказ по его уникальному идентификатору.
+// Если заказ не найден или удален, возвращает nil.
+func (s *Store) GetOrder(ctx context.Context, orderUID string) (*model.Order, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	r := s.active(orderUID)
+	if r == nil {
+		s.logger.Info("Заказ не найден", orderUID)
+		return nil, nil
+	}
+	return cloneOrder(&r.Order), nil
+}
+
+// ExistingOrders возвращает идентификаторы неудаленных заказов из orderUIDs.
+func (s *Store) ExistingOrders(ctx context.Context, orderUIDs []string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var existing []string
+	for _, orderUID := range orderUIDs {
+		if s.active(orderUID) != nil {
+			existing = append(existing, orderUID)
+		}
+	}
+	return existing, nil
+}
+
+// SaveOrder сохраняет заказ. Если заказ уже существует, он обрабатывается согласно политике конфликтов,
+// а результат сообщает вызывающему, что именно произошло.
+func (s *Store) SaveOrder(ctx context.Context, order *model.Order) (database.SaveOutcome, error) {
+	s.mu.Lock()
+	c := s.begin()
+	outcome := s.save(ctx, c, order)
+	err := c.commit()
+	s.unlockAndDispatch(ctx, c, err)
+	if err != nil {
+		s.logger.WithError(err).Error("Ошибка при сохранении заказа")
+		return "", err
+	}
+
+	s.logger.WithField("outcome", outcome).Info("Заказ успешно сохранен", order.OrderUID)
+	return outcome, nil
+}
+
+// save применяет SaveOrder в рамках операции c.
+func (s *Store) save(ctx context.Context, c *change, order *model.Order) database.SaveOutcome {
+	existing := s.data.Orders[order.OrderUID]
+	if existing == nil {
+		order.Version = 1
+		c.put(order.OrderUID, &record{Order: *cloneOrder(order)})
+		c.revision(ctx, model.OperationCreate, order.OrderUID, nil, order)
+		return database.OutcomeInserted
+	}
+
+	switch s.conflictPolicy {
+	case database.ConflictOverwrite:
+	case database.ConflictLatestWins:
+		if !createdAt(&existing.Order).Before(createdAt(order)) {
+			return database.OutcomeStale
+		}
+	default:
+		return database.OutcomeDuplicate
+	}
+
+	// Перезапись удаленного заказа восстанавливает его
+	var previous *model.Order
+	operation := model.OperationRestore
+	if existing.DeletedAt == nil {
+		previous, operation = &existing.Order, model.OperationUpdate
+	}
+	order.Version = existing.Order.Version + 1
+	c.put(order.OrderUID, &record{Order: *cloneOrder(order)})
+	c.revision(ctx, operation, order.OrderUID, previous, order)
+	return database.OutcomeOverwritten
+}
+
+// SaveOrders сохраняет пакет заказов одной записью в файл.
+// Повторяющиеся заказы пакета сводятся к последней версии.
+func (s *Store) SaveOrders(ctx context.Context, orders []model.Order) (*database.BulkResult, error) {
+	index := make(map[string]int, len(orders))
+	unique := make([]model.Order, 0, len(orders))
+	for _, order := range orders {
+		if i, ok := index[order.OrderUID]; ok {
+			unique[i] = order
+			continue
+		}
+		index[order.OrderUID] = len(unique)
+		unique = append(unique, order)
+	}
+
+	result := &database.BulkResult{}
+	s.mu.Lock()
+	c := s.begin()
+	for i := range unique {
+		switch s.save(ctx, c, &unique[i]) {
+		case database.OutcomeInserted:
+			result.Inserted++
+		case database.OutcomeOverwritten:
+			result.Overwritten++
+		default:
+			result.Skipped++
+		}
+	}
+	err := c.commit()
+	s.unlockAndDispatch(ctx, c, err)
+	if err != nil {
+		s.logger.WithError(err).Error("Ошибка при сохранении пакета заказов")
+		return nil, err
+	}
+
+	s.logger.WithField("inserted", result.Inserted).WithField("overwritten", result.Overwritten).
+		WithField("skipped", result.Skipped).Info("Пакет заказов сохранен")
+	return result, nil
+}
+
+// UpdateOrder обновляет существующий заказ.
+// Если order.Version задана и не совпадает с текущей версией заказа, возвращает *database.VersionConflictError.
+// После успешного обновления order.Version содержит новую версию.
+func (s *Store) UpdateOrder(ctx context.Context, order *model.Order) error {
+	s.mu.Lock()
+	c := s.begin()
+	err := func() error {
+		previous := s.active(order.OrderUID)
+		if previous == nil {
+			return database.ErrOrderNotFound
+		}
+		if order.Version != 0 && order.Version != previous.Order.Version {
+			return &database.VersionConflictError{OrderUID: order.OrderUID, Expected: order.Version, Actual: previous.Order.Version}
+		}
+		order.Version = previous.Order.Version + 1
+		c.put(order.OrderUID, &record{Order: *cloneOrder(order)})
+		c.revision(ctx, model.OperationUpdate, order.OrderUID, &previous.Order, order)
+		return c.commit()
+	}()
+	s.unlockAndDispatch(ctx, c, err)
+	if err != nil {
+		s.logger.WithError(err).Error("Ошибка при обновлении заказа")
+		return err
+	}
+
+	s.logger.Info("Заказ успешно обновлен", order.OrderUID)
+	return nil
+}
+
+// DeleteOrder помечает заказ удаленным. Удаленный заказ исключается из чтения,
+// но остается в хранилище и может быть восстановлен через RestoreOrder.
+func (s *Store) DeleteOrder(ctx context.Context, orderUID string) error {
+	s.mu.Lock()
+	c := s.begin()
+	err := func() error {
+		previous := s.active(orderUID)
+		if previous == nil {
+			return database.ErrOrderNotFound
+		}
+		now := time.Now().UTC()
+		deleted := &record{Order: *cloneOrder(&previous.Order), DeletedAt: &now}
+		deleted.Order.Version++
+		c.put(orderUID, deleted)
+		c.revision(ctx, model.OperationDelete, orderUID, &previous.Order, nil)
+		return c.commit()
+	}()
+	s.unlockAndDispatch(ctx, c, err)
+	if err != nil {
+		s.logger.WithError(err).Error("Ошибка при удалении заказа")
+		return err
+	}
+
+	s.logger.Info("Заказ успешно удален", orderUID)
+	return nil
+}
+
+// RestoreOrder восстанавливает заказ, удаленный через DeleteOrder.
+// Если удаленного заказа с таким идентификатором нет, возвращает database.ErrOrderNotFound.
+func (s *Store) RestoreOrder(ctx context.Context, orderUID string) error {
+	s.mu.Lock()
+	c := s.begin()
+	err := func() error {
+		deleted := s.data.Orders[orderUID]
+		if deleted == nil || deleted.DeletedAt == nil {
+			return database.ErrOrderNotFound
+		}
+		restored := &record{Order: *cloneOrder(&deleted.Order)}
+		restored.Order.Version++
+		c.put(orderUID, restored)
+		c.revision(ctx, model.OperationRestore, orderUID, nil, &restored.Order)
+		return c.commit()
+	}()
+	s.unlockAndDispatch(ctx, c, err)
+	if err != nil {
+		s.logger.WithError(err).Error("Ошибка при восстановлении заказа")
+		return err
+	}
+
+	s.logger.Info("Заказ успешно восстановлен", orderUID)
+	return nil
+}
+
+// Start ничего не запускает: у встроенного хранилища нет фоновых процессов.
+func (s *Store) Start(ctx context.Context) error {
+	s.logger.Info("Сервис успешно запущен")
+	return nil
+}
+
+// cloneOrder возвращает глубокую копию заказа, чтобы вызывающий не мог изменить данные хранилища.
+func cloneOrder(order *model.Order) *model.Order {
+	if order == nil {
+		return nil
+	}
+	data, err := json.Marshal(order)
+	if err != nil {
+		return nil
+	}
+	clone := &model.Order{}
+	if err := json.Unmarshal(data, clone); err != nil {
+		return nil
+	}
+	return clone
+}
+
+// createdAt возвращает дату создания заказа. Некорректная дата считается нулевой.
+func createdAt(order *model.Order) time.Time {
+	t, err := time.Parse(time.RFC3339Nano, order.DateCreated)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
@@ -0,0 +1,94 @@
+package filestore_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ArtemZ007/wb-l0/internal/repository/filestore"
+	"github.com/ArtemZ007/wb-l0/internal/repository/ordertest"
+	"github.com/sirupsen/logrus"
+)
+
+// newLogger возвращает логгер, не засоряющий вывод тестов.
+func newLogger() *logrus.Logger {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return logger
+}
+
+func TestStoreContract(t *testing.T) {
+	store, err := filestore.NewStore(filepath.Join(t.TempDir(), "orders.json"), newLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ordertest.Run(context.Background(), store); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStoreReopen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "orders.json")
+	store, err := filestore.NewStore(path, newLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	order := ordertest.NewOrder(time.Now().UTC().Truncate(time.Second))
+	if _, err := store.SaveOrder(ctx, &order); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := filestore.NewStore(path, newLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := reopened.GetOrder(ctx, order.OrderUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got == nil || got.Version != order.Version {
+		t.Fatalf("заказ %s не восстановлен из файла: %+v", order.OrderUID, got)
+	}
+}
+
+func TestStoreAsOfWithoutHistory(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "orders.json")
+	created := time.Now().UTC().Truncate(time.Second)
+	order := ordertest.NewOrder(created)
+	order.Version = 1
+	// Файл хранилища прежней версии без истории заказов
+	data, err := json.Marshal(map[string]interface{}{
+		"orders": map[string]interface{}{order.OrderUID: map[string]interface{}{"order": order}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	store, err := filestore.NewStore(path, newLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	past, err := store.GetOrderAsOf(ctx, order.OrderUID, created.Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if past != nil {
+		t.Fatal("заказ без истории существует до создания")
+	}
+	current, err := store.GetOrderAsOf(ctx, order.OrderUID, created.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current == nil || current.OrderUID != order.OrderUID {
+		t.Fatalf("заказ без истории не найден после создания: %+v", current)
+	}
+}
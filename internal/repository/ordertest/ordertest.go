@@ -0,0 +1,415 @@
+// Package ordertest содержит общий набор проверок поведения database.IOrderService.
+// Набор выполняется для каждой реализации хранилища заказов, чтобы они вели себя одинаково.
+// Проверки создают заказы со случайными идентификаторами и не зависят от уже сохраненных данных,
+// но ожидают политику конфликтов database.ConflictReject.
+package ordertest
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ArtemZ007/wb-l0/internal/domain/model"
+	"github.com/ArtemZ007/wb-l0/internal/repository/database"
+)
+
+// check описывает одну проверку набора.
+type check struct {
+	name string
+	run  func(ctx context.Context, svc database.IOrderService) error
+}
+
+// checks перечисляет проверки набора в порядке выполнения.
+var checks = []check{
+	{"сохранение и получение заказа", checkSaveAndGet},
+	{"обновление с проверкой версии", checkUpdate},
+	{"постраничная выборка", checkList},
+	{"поиск", checkSearch},
+	{"история изменений", checkHistory},
+	{"удаление и восстановление", checkDeleteRestore},
+	{"пакетное сохранение", checkBulk},
+}
+
+// Run выполняет набор проверок над svc и возвращает ошибку первой не пройденной проверки.
+func Run(ctx context.Context, svc database.IOrderService) error {
+	for _, c := range checks {
+		if err := c.run(ctx, svc); err != nil {
+			return fmt.Errorf("%s: %w", c.name, err)
+		}
+	}
+	return nil
+}
+
+func checkSaveAndGet(ctx context.Context, svc database.IOrderService) error {
+	order := NewOrder(time.Now().UTC().Truncate(time.Second))
+	outcome, err := svc.SaveOrder(ctx, &order)
+	if err != nil {
+		return err
+	}
+	if outcome != database.OutcomeInserted || order.Version != 1 {
+		return fmt.Errorf("новый заказ: результат %s, версия %d", outcome, order.Version)
+	}
+
+	duplicate := order
+	if outcome, err = svc.SaveOrder(ctx, &duplicate); err != nil {
+		return err
+	}
+	if outcome != database.OutcomeDuplicate {
+		return fmt.Errorf("повторный заказ: результат %s", outcome)
+	}
+
+	got, err := svc.GetOrder(ctx, order.OrderUID)
+	if err != nil {
+		return err
+	}
+	if err := sameOrder(got, &order); err != nil {
+		return err
+	}
+
+	missing, err := svc.GetOrder(ctx, newUUID())
+	if err != nil {
+		return err
+	}
+	if missing != nil {
+		return errors.New("получен несуществующий заказ")
+	}
+	return nil
+}
+
+func checkUpdate(ctx context.Context, svc database.IOrderService) error {
+	order := NewOrder(time.Now().UTC().Truncate(time.Second))
+	if _, err := svc.SaveOrder(ctx, &order); err != nil {
+		return err
+	}
+
+	*order.Payment.Amount += 100
+	if err := svc.UpdateOrder(ctx, &order); err != nil {
+		return err
+	}
+	if order.Version != 2 {
+		return fmt.Errorf("версия после обновления %d, ожидалась 2", order.Version)
+	}
+
+	stale := order
+	stale.Version = 1
+	var conflict *database.VersionConflictError
+	if err := svc.UpdateOrder(ctx, &stale); !errors.As(err, &conflict) || conflict.Actual != 2 {
+		return fmt.Errorf("обновление устаревшей версии: %v", err)
+	}
+
+	got, err := svc.GetOrder(ctx, order.OrderUID)
+	if err != nil {
+		return err
+	}
+	if err := sameOrder(got, &order); err != nil {
+		return err
+	}
+
+	unknown := NewOrder(time.Now().UTC().Truncate(time.Second))
+	if err := svc.UpdateOrder(ctx, &unknown); !errors.Is(err, database.ErrOrderNotFound) {
+		return fmt.Errorf("обновление несуществующего заказа: %v", err)
+	}
+	return nil
+}
+
+func checkList(ctx context.Context, svc database.IOrderService) error {
+	customer := newUUID()
+	base := time.Now().UTC().Truncate(time.Second).Add(-time.Hour)
+	var uids []string
+	for i := 0; i < 3; i++ {
+		order := NewOrder(base.Add(time.Duration(i) * time.Minute))
+		order.CustomerID = &customer
+		if _, err := svc.SaveOrder(ctx, &order); err != nil {
+			return err
+		}
+		uids = append(uids, order.OrderUID)
+	}
+
+	for _, descending := range []bool{false, true} {
+		var got []string
+		query := model.ListQuery{Limit: 2, CustomerID: customer, Descending: descending}
+		for pages := 0; ; pages++ {
+			if pages > len(uids) {
+				return errors.New("курсор не завершает выборку")
+			}
+			page, err := svc.ListOrders(ctx, query)
+			if err != nil {
+				return err
+			}
+			for _, order := range page.Orders {
+				got = append(got, order.OrderUID)
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			query.Cursor = page.NextCursor
+		}
+
+		want := uids
+		if descending {
+			want = []string{uids[2], uids[1], uids[0]}
+		}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			return fmt.Errorf("порядок заказов %v, ожидался %v", got, want)
+		}
+	}
+
+	if _, err := svc.ListOrders(ctx, model.ListQuery{Cursor: "!"}); !errors.Is(err, database.ErrInvalidCursor) {
+		return fmt.Errorf("некорректный курсор: %v", err)
+	}
+	return nil
+}
+
+func checkSearch(ctx context.Context, svc database.IOrderService) error {
+	older := NewOrder(time.Now().UTC().Truncate(time.Second).Add(-time.Minute))
+	newer := NewOrder(time.Now().UTC().Truncate(time.Second))
+	newer.CustomerID = older.CustomerID
+	for _, order := range []*model.Order{&older, &newer} {
+		if _, err := svc.SaveOrder(ctx, order); err != nil {
+			return err
+		}
+	}
+
+	found, err := svc.SearchOrders(ctx, model.SearchQuery{CustomerID: *older.CustomerID})
+	if err != nil {
+		return err
+	}
+	if len(found) != 2 || found[0].OrderUID != newer.OrderUID || found[1].OrderUID != older.OrderUID {
+		return fmt.Errorf("поиск по клиенту вернул %d заказов в неверном порядке", len(found))
+	}
+
+	brand := *older.Items[0].Brand
+	found, err = svc.SearchOrders(ctx, model.SearchQuery{Brand: brand[2:10], Email: "USER-" + brand + "@example.com"})
+	if err != nil {
+		return err
+	}
+	if len(found) != 1 || found[0].OrderUID != older.OrderUID {
+		return fmt.Errorf("поиск по бренду и почте вернул %d заказов", len(found))
+	}
+
+	if _, err := svc.SearchOrders(ctx, model.SearchQuery{}); !errors.Is(err, database.ErrEmptySearchQuery) {
+		return fmt.Errorf("пустой запрос поиска: %v", err)
+	}
+	return nil
+}
+
+func checkHistory(ctx context.Context, svc database.IOrderService) error {
+	before := time.Now().Add(-time.Second)
+	order := NewOrder(time.Now().UTC().Truncate(time.Second))
+	ctx = database.WithRevisionSource(ctx, model.RevisionSource{Kind: model.SourceAdmin, Ref: "ordertest"})
+	if _, err := svc.SaveOrder(ctx, &order); err != nil {
+		return err
+	}
+	original := *order.Payment.Amount
+	*order.Payment.Amount = original + 1
+	if err := svc.UpdateOrder(ctx, &order); err != nil {
+		return err
+	}
+
+	history, err := svc.OrderHistory(ctx, order.OrderUID)
+	if err != nil {
+		return err
+	}
+	if len(history) != 2 || history[0].Operation != model.OperationCreate || history[1].Operation != model.OperationUpdate {
+		return fmt.Errorf("история содержит %d ревизий", len(history))
+	}
+	if history[1].Source.Kind != model.SourceAdmin || history[1].Source.Ref != "ordertest" {
+		return fmt.Errorf("источник ревизии %+v", history[1].Source)
+	}
+	if history[1].Previous == nil || *history[1].Previous.Payment.Amount != original {
+		return errors.New("ревизия не содержит предыдущий документ")
+	}
+
+	past, err := svc.GetOrderAsOf(ctx, order.OrderUID, before)
+	if err != nil {
+		return err
+	}
+	if past != nil {
+		return errors.New("заказ существует до создания")
+	}
+	current, err := svc.GetOrderAsOf(ctx, order.OrderUID, time.Now().Add(time.Second))
+	if err != nil {
+		return err
+	}
+	if err := sameOrder(current, &order); err != nil {
+		return err
+	}
+
+	// Заказ без ревизий после момента at существует на этот момент, только если он был сохранен
+	missing := NewOrder(time.Now().UTC())
+	if past, err := svc.GetOrderAsOf(ctx, missing.OrderUID, time.Now()); err != nil || past != nil {
+		return fmt.Errorf("несохраненный заказ существует: %v", err)
+	}
+	return nil
+}
+
+func checkDeleteRestore(ctx context.Context, svc database.IOrderService) error {
+	order := NewOrder(time.Now().UTC().Truncate(time.Second))
+	if _, err := svc.SaveOrder(ctx, &order); err != nil {
+		return err
+	}
+	if err := svc.RestoreOrder(ctx, order.OrderUID); !errors.Is(err, database.ErrOrderNotFound) {
+		return fmt.Errorf("восстановление активного заказа: %v", err)
+	}
+
+	if err := svc.DeleteOrder(ctx, order.OrderUID); err != nil {
+		return err
+	}
+	if got, err := svc.GetOrder(ctx, order.OrderUID); err != nil || got != nil {
+		return fmt.Errorf("удаленный заказ доступен для чтения: %v", err)
+	}
+	found, err := svc.SearchOrders(ctx, model.SearchQuery{CustomerID: *order.CustomerID})
+	if err != nil {
+		return err
+	}
+	if len(found) != 0 {
+		return errors.New("удаленный заказ найден поиском")
+	}
+	if err := svc.DeleteOrder(ctx, order.OrderUID); !errors.Is(err, database.ErrOrderNotFound) {
+		return fmt.Errorf("повторное удаление: %v", err)
+	}
+
+	if err := svc.RestoreOrder(ctx, order.OrderUID); err != nil {
+		return err
+	}
+	got, err := svc.GetOrder(ctx, order.OrderUID)
+	if err != nil {
+		return err
+	}
+	if got == nil || got.Version != 3 {
+		return errors.New("восстановленный заказ недоступен или имеет неверную версию")
+	}
+
+	history, err := svc.OrderHistory(ctx, order.OrderUID)
+	if err != nil {
+		return err
+	}
+	if len(history) != 3 || history[1].Operation != model.OperationDelete || history[2].Operation != model.OperationRestore {
+		return fmt.Errorf("история содержит %d ревизий", len(history))
+	}
+	return nil
+}
+
+func checkBulk(ctx context.Context, svc database.IOrderService) error {
+	existing := NewOrder(time.Now().UTC().Truncate(time.Second))
+	if _, err := svc.SaveOrder(ctx, &existing); err != nil {
+		return err
+	}
+
+	first := NewOrder(time.Now().UTC().Truncate(time.Second))
+	second := NewOrder(time.Now().UTC().Truncate(time.Second))
+	result, err := svc.SaveOrders(ctx, []model.Order{first, existing, second, first})
+	if err != nil {
+		return err
+	}
+	if result.Inserted != 2 || result.Overwritten != 0 || result.Skipped != 1 {
+		return fmt.Errorf("результат пакета %+v", *result)
+	}
+
+	for _, order := range []model.Order{first, second} {
+		got, err := svc.GetOrder(ctx, order.OrderUID)
+		if err != nil {
+			return err
+		}
+		order.Version = 1
+		if err := sameOrder(got, &order); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sameOrder сравнивает прочитанный заказ с сохраненным по значимым полям.
+func sameOrder(got, want *model.Order) error {
+	if got == nil {
+		return fmt.Errorf("заказ %s не найден", want.OrderUID)
+	}
+	gotCreated, _ := time.Parse(time.RFC3339Nano, got.DateCreated)
+	wantCreated, _ := time.Parse(time.RFC3339Nano, want.DateCreated)
+	switch {
+	case got.OrderUID != want.OrderUID:
+		return fmt.Errorf("получен заказ %s вместо %s", got.OrderUID, want.OrderUID)
+	case got.Version != want.Version:
+		return fmt.Errorf("версия заказа %d, ожидалась %d", got.Version, want.Version)
+	case !gotCreated.Equal(wantCreated):
+		return fmt.Errorf("дата создания %s, ожидалась %s", got.DateCreated, want.DateCreated)
+	case got.Payment == nil || *got.Payment.Amount != *want.Payment.Amount:
+		return errors.New("оплата заказа не совпадает")
+	case got.Delivery == nil || *got.Delivery.Email != *want.Delivery.Email:
+		return errors.New("доставка заказа не совпадает")
+	case len(got.Items) != len(want.Items) || *got.Items[0].Brand != *want.Items[0].Brand:
+		return errors.New("товары заказа не совпадают")
+	}
+	return nil
+}
+
+// NewOrder возвращает корректный заказ со случайными идентификаторами и датой создания created.
+func NewOrder(created time.Time) model.Order {
+	uid, track, customer := newUUID(), newUUID(), newUUID()
+	brand := "brand-" + newUUID()[:8]
+	str := func(s string) *string { return &s }
+	num := func(n int) *int { return &n }
+	paymentDt := created.Unix()
+
+	return model.Order{
+		ID:          uid,
+		OrderUID:    uid,
+		TrackNumber: &track,
+		Entry:       str("WBIL"),
+		Delivery: &model.Delivery{
+			Name:    str("Test Testov"),
+			Phone:   str("+9720000000"),
+			Zip:     str("2639809"),
+			City:    str("Kiryat Mozkin"),
+			Address: str("Ploshad Mira 15"),
+			Region:  str("Kraiot"),
+			Email:   str("user-" + brand + "@example.com"),
+		},
+		Payment: &model.Payment{
+			Transaction:  str(uid),
+			RequestID:    str(""),
+			Currency:     str("USD"),
+			Provider:     str("wbpay"),
+			Amount:       num(1817),
+			PaymentDt:    &paymentDt,
+			Bank:         str("alpha"),
+			DeliveryCost: num(1500),
+			GoodsTotal:   num(317),
+			CustomFee:    num(0),
+		},
+		Items: []model.Item{{
+			ChrtID:      num(9934930),
+			TrackNumber: &track,
+			Price:       num(453),
+			RID:         str(newUUID()),
+			Name:        str("Mascaras"),
+			Sale:        num(30),
+			Size:        str("0"),
+			TotalPrice:  num(317),
+			NmID:        num(2389212),
+			Brand:       &brand,
+			Status:      num(202),
+		}},
+		Locale:            str("en"),
+		InternalSignature: str(""),
+		CustomerID:        &customer,
+		DeliveryService:   str("meest"),
+		Shardkey:          str("9"),
+		SMID:              num(99),
+		DateCreated:       created.Format(time.RFC3339),
+		OofShard:          str("1"),
+	}
+}
+
+// newUUID возвращает случайный UUID версии 4.
+func newUUID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
@@ -3,6 +3,8 @@ package subscription
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"strconv"
 	"time"
 
 	"github.com/ArtemZ007/wb-l0/internal/domain/model"
@@ -15,14 +17,18 @@ import (
 // Listener представляет слушателя сообщений
 type Listener struct {
 	conn         stan.Conn
-	cacheService *cache.CacheService
-	orderService database.IOrderService
+	orders       cache.OrderWriter
 	log          logger.Logger
 	subscription stan.Subscription
+	batchSize    int            // Размер пакета; значение меньше 2 отключает пакетный режим
+	batchWait    time.Duration  // Максимальное время накопления пакета
+	batch        chan *stan.Msg // Сообщения, ожидающие пакетной обработки
 }
 
 // NewListener создает новый экземпляр Listener.
-func NewListener(natsURL, clusterID, clientID string, cacheService *cache.CacheService, orderService database.IOrderService, log logger.Logger) (*Listener, error) {
+// Заказы записываются через orders, обычно хранилище заказов cache.OrderStore. Кэш обновляется
+// не слушателем, а доставкой событий outbox, записанных вместе с заказом.
+func NewListener(natsURL, clusterID, clientID string, orders cache.OrderWriter, log logger.Logger) (*Listener, error) {
 	log.Info("Подключение к NATS Streaming", map[string]interface{}{
 		"natsURL":   natsURL,
 		"clusterID": clusterID,
@@ -34,18 +40,37 @@ func NewListener(natsURL, clusterID, clientID string, cacheService *cache.CacheS
 		return nil, err
 	}
 	return &Listener{
-		conn:         conn,
-		cacheService: cacheService,
-		orderService: orderService,
-		log:          log,
+		conn:   conn,
+		orders: orders,
+		log:    log,
 	}, nil
 }
 
+// SetBatchMode включает пакетный режим: сообщения накапливаются до size штук
+// или в течение wait и сохраняются одним вызовом SaveOrders.
+func (l *Listener) SetBatchMode(size int, wait time.Duration) {
+	l.batchSize = size
+	l.batchWait = wait
+}
+
 // Start начинает прослушивание сообщений на указанной теме.
 func (l *Listener) Start(ctx context.Context) error {
 	subject := "orders"
+	handler := l.handleMessage
+	if l.batchSize > 1 {
+		l.batch = make(chan *stan.Msg, l.batchSize)
+		handler = func(msg *stan.Msg) {
+			// После остановки обработки пакетов сообщение не подтверждается и будет доставлено повторно
+			select {
+			case l.batch <- msg:
+			case <-ctx.Done():
+			}
+		}
+		go l.runBatches(ctx)
+	}
+
 	var err error
-	l.subscription, err = l.conn.Subscribe(subject, l.handleMessage, stan.DurableName("order-listener-durable"), stan.SetManualAckMode(), stan.AckWait(30*time.Second))
+	l.subscription, err = l.conn.Subscribe(subject, handler, stan.DurableName("order-listener-durable"), stan.SetManualAckMode(), stan.AckWait(30*time.Second))
 	if err != nil {
 		l.log.Error("Ошибка подписки на тему", map[string]interface{}{
 			"subject": subject,
@@ -72,29 +97,139 @@ func (l *Listener) handleMessage(msg *stan.Msg) {
 	var order model.Order
 	if err := json.Unmarshal(msg.Data, &order); err != nil {
 		l.log.Error("Ошибка десериализации заказа", map[string]interface{}{"error": err})
+		// Повторная доставка не исправит некорректное сообщение, поэтому оно подтверждается
+		l.ack(msg)
 		return
 	}
+	l.saveOrder(msg, &order)
+}
 
-	// Сохранение заказа в базе данных
-	if err := l.orderService.SaveOrder(context.Background(), &order); err != nil {
+// saveOrder сохраняет заказ из сообщения msg и подтверждает сообщение, если повторная доставка
+// не нужна: заказ сохранен, пропущен или не может быть сохранен в принципе.
+func (l *Listener) saveOrder(msg *stan.Msg, order *model.Order) {
+	// Сохранение заказа в базе данных с указанием сообщения-источника для истории изменений
+	ctx := database.WithRevisionSource(context.Background(), model.RevisionSource{
+		Kind: model.SourceNATS,
+		Ref:  strconv.FormatUint(msg.Sequence, 10),
+	})
+	outcome, err := l.orders.SaveOrder(ctx, order)
+	if errors.Is(err, database.ErrUnknownTenant) {
+		l.log.Error("Заказ неизвестного арендатора отклонен", map[string]interface{}{"orderUID": order.OrderUID, "error": err})
+		// Повторная доставка не поможет, пока арендатор не добавлен в конфигурацию
+		l.ack(msg)
+		return
+	}
+	if err != nil {
 		l.log.Error("Ошибка сохранения заказа в базе данных", map[string]interface{}{"error": err})
 		return
 	}
-	l.log.Info("Заказ сохранен в базе данных", map[string]interface{}{"orderUID": order.OrderUID})
+	if !outcome.Changed() {
+		l.log.Info("Заказ пропущен", map[string]interface{}{"orderUID": order.OrderUID, "outcome": outcome})
+		l.ack(msg)
+		return
+	}
+	l.log.Info("Заказ сохранен в базе данных", map[string]interface{}{"orderUID": order.OrderUID, "outcome": outcome})
+
+	l.ack(msg)
+}
+
+// runBatches накапливает сообщения в пакеты и обрабатывает их до завершения контекста.
+// Неподтвержденные сообщения незавершенного пакета будут доставлены повторно.
+func (l *Listener) runBatches(ctx context.Context) {
+	timer := time.NewTimer(l.batchWait)
+	timer.Stop()
+	defer timer.Stop()
+
+	var batch []*stan.Msg
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg := <-l.batch:
+			batch = append(batch, msg)
+			if len(batch) == 1 {
+				timer.Reset(l.batchWait)
+			}
+			if len(batch) >= l.batchSize {
+				timer.Stop()
+				l.handleBatch(batch)
+				batch = nil
+			}
+		case <-timer.C:
+			if len(batch) > 0 {
+				l.handleBatch(batch)
+				batch = nil
+			}
+		}
+	}
+}
+
+// handleBatch сохраняет пакет заказов одним вызовом и подтверждает сообщения после успешной записи.
+// Если пакет сохранить не удалось, заказы сохраняются по одному, чтобы один некорректный заказ
+// не приводил к бесконечной повторной доставке всего пакета.
+func (l *Listener) handleBatch(msgs []*stan.Msg) {
+	orders := make([]model.Order, 0, len(msgs))
+	valid := make([]*stan.Msg, 0, len(msgs))
+	for _, msg := range msgs {
+		var order model.Order
+		if err := json.Unmarshal(msg.Data, &order); err != nil {
+			l.log.Error("Ошибка десериализации заказа", map[string]interface{}{"error": err})
+			l.ack(msg)
+			continue
+		}
+		orders = append(orders, order)
+		valid = append(valid, msg)
+	}
+	if len(orders) == 0 {
+		return
+	}
 
-	// Сохранение заказа в кэше
-	if err := l.cacheService.AddOrUpdateOrder(&order); err != nil {
-		l.log.Error("Ошибка сохранения заказа в кэше", map[string]interface{}{"error": err})
+	ctx := database.WithRevisionSource(context.Background(), model.RevisionSource{
+		Kind: model.SourceNATS,
+		Ref:  strconv.FormatUint(valid[0].Sequence, 10) + "-" + strconv.FormatUint(valid[len(valid)-1].Sequence, 10),
+	})
+	result, err := l.orders.SaveOrders(ctx, orders)
+	if err != nil {
+		l.log.Error("Ошибка пакетного сохранения заказов в базе данных, заказы будут сохранены по одному",
+			map[string]interface{}{"error": err, "count": len(orders)})
+		for i, msg := range valid {
+			l.saveOrder(msg, &orders[i])
+		}
 		return
 	}
-	l.log.Info("Заказ сохранен в кэше", map[string]interface{}{"orderUID": order.OrderUID})
+	l.log.Info("Пакет заказов сохранен в базе данных", map[string]interface{}{
+		"inserted":    result.Inserted,
+		"overwritten": result.Overwritten,
+		"skipped":     result.Skipped,
+	})
 
-	// Подтверждение получения сообщения
+	for _, msg := range valid {
+		l.ack(msg)
+	}
+}
+
+// ack подтверждает получение сообщения.
+func (l *Listener) ack(msg *stan.Msg) {
 	if err := msg.Ack(); err != nil {
 		l.log.Error("Ошибка подтверждения сообщения", map[string]interface{}{"error": err})
 	}
 }
 
+// EventPublisher возвращает обработчик outbox, публикующий события заказов в тему subject.
+func (l *Listener) EventPublisher(subject string) database.OutboxHandler {
+	return func(ctx context.Context, event model.OrderEvent) error {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		if err := l.conn.Publish(subject, data); err != nil {
+			l.log.Error("Ошибка публикации события заказа", map[string]interface{}{"subject": subject, "error": err})
+			return err
+		}
+		return nil
+	}
+}
+
 // Stop останавливает слушателя и закрывает соединение с NATS Streaming.
 func (l *Listener) Stop() error {
 	if l.subscription != nil {
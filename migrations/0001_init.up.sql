@@ -0,0 +1,59 @@
+-- Начальная схема заказов.
+-- Все объекты создаются в схеме, выбранной исполнителем миграций через search_path.
+CREATE TABLE IF NOT EXISTS deliveries (
+    id UUID PRIMARY KEY,
+    name TEXT NOT NULL,
+    phone TEXT NOT NULL CHECK (phone ~ '^\+\d{1,15}$'),
+    zip TEXT NOT NULL,
+    city TEXT NOT NULL,
+    address TEXT NOT NULL,
+    region TEXT NOT NULL,
+    email TEXT NOT NULL CHECK (
+        email ~* '^[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}$'
+    )
+);
+
+CREATE TABLE IF NOT EXISTS payments (
+    id UUID PRIMARY KEY,
+    transaction TEXT NOT NULL UNIQUE,
+    request_id TEXT NOT NULL,
+    currency TEXT NOT NULL CHECK (currency IN ('USD', 'EUR', 'RUB')),
+    provider TEXT NOT NULL,
+    amount BIGINT NOT NULL CHECK (amount > 0),
+    payment_dt BIGINT NOT NULL,
+    bank TEXT NOT NULL,
+    delivery_cost BIGINT NOT NULL CHECK (delivery_cost >= 0),
+    goods_total BIGINT NOT NULL CHECK (goods_total > 0),
+    custom_fee BIGINT NOT NULL CHECK (custom_fee >= 0)
+);
+
+CREATE TABLE IF NOT EXISTS orders (
+    order_uid UUID PRIMARY KEY,
+    track_number UUID NOT NULL UNIQUE,
+    entry TEXT,
+    delivery_id UUID REFERENCES deliveries(id) ON DELETE CASCADE,
+    payment_id UUID REFERENCES payments(id) ON DELETE CASCADE,
+    locale TEXT NOT NULL,
+    internal_signature TEXT,
+    customer_id UUID NOT NULL,
+    delivery_service TEXT NOT NULL,
+    shardkey TEXT NOT NULL,
+    sm_id BIGINT NOT NULL,
+    date_created TIMESTAMP NOT NULL DEFAULT NOW(),
+    oof_shard TEXT
+);
+
+CREATE TABLE IF NOT EXISTS items (
+    id UUID PRIMARY KEY,
+    chrt_id BIGINT NOT NULL,
+    track_number TEXT NOT NULL,
+    price BIGINT NOT NULL,
+    rid TEXT NOT NULL,
+    name TEXT NOT NULL,
+    sale INT NOT NULL,
+    size TEXT NOT NULL,
+    total_price BIGINT NOT NULL,
+    nm_id BIGINT NOT NULL,
+    brand TEXT NOT NULL,
+    status INT NOT NULL
+);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS items_order_uid_idx;
+ALTER TABLE items DROP COLUMN IF EXISTS position;
+ALTER TABLE items DROP COLUMN IF EXISTS order_uid;
+ALTER TABLE items ALTER COLUMN id DROP DEFAULT;
+ALTER TABLE payments ALTER COLUMN id DROP DEFAULT;
+ALTER TABLE deliveries ALTER COLUMN id DROP DEFAULT;
@@ -0,0 +1,7 @@
+-- Связь товаров с заказом и генерация идентификаторов агрегата.
+ALTER TABLE deliveries ALTER COLUMN id SET DEFAULT gen_random_uuid();
+ALTER TABLE payments ALTER COLUMN id SET DEFAULT gen_random_uuid();
+ALTER TABLE items ALTER COLUMN id SET DEFAULT gen_random_uuid();
+ALTER TABLE items ADD COLUMN IF NOT EXISTS order_uid UUID REFERENCES orders(order_uid) ON DELETE CASCADE;
+ALTER TABLE items ADD COLUMN IF NOT EXISTS position INT NOT NULL DEFAULT 0;
+CREATE INDEX IF NOT EXISTS items_order_uid_idx ON items (order_uid, position);
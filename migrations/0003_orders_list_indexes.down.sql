@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS orders_customer_id_idx;
+DROP INDEX IF EXISTS orders_locale_idx;
+DROP INDEX IF EXISTS orders_delivery_service_idx;
+DROP INDEX IF EXISTS orders_entry_idx;
+DROP INDEX IF EXISTS orders_date_created_uid_idx;
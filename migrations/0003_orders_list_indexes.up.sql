@@ -0,0 +1,6 @@
+-- Индексы для постраничной выборки и фильтрации заказов.
+CREATE INDEX IF NOT EXISTS orders_date_created_uid_idx ON orders (date_created, order_uid);
+CREATE INDEX IF NOT EXISTS orders_entry_idx ON orders (entry, date_created, order_uid);
+CREATE INDEX IF NOT EXISTS orders_delivery_service_idx ON orders (delivery_service, date_created, order_uid);
+CREATE INDEX IF NOT EXISTS orders_locale_idx ON orders (locale, date_created, order_uid);
+CREATE INDEX IF NOT EXISTS orders_customer_id_idx ON orders (customer_id, date_created, order_uid);
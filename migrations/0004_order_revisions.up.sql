@@ -0,0 +1,14 @@
+-- История изменений заказов.
+CREATE TABLE IF NOT EXISTS order_revisions (
+    id BIGSERIAL PRIMARY KEY,
+    order_uid UUID NOT NULL,
+    revision INT NOT NULL,
+    operation TEXT NOT NULL CHECK (operation IN ('create', 'update', 'delete')),
+    source TEXT NOT NULL,
+    source_ref TEXT,
+    previous JSONB,
+    diff JSONB NOT NULL DEFAULT '[]',
+    changed_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (order_uid, revision)
+);
+CREATE INDEX IF NOT EXISTS order_revisions_changed_at_idx ON order_revisions (order_uid, changed_at);
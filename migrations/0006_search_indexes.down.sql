@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS items_brand_trgm_idx;
+DROP INDEX IF EXISTS items_name_trgm_idx;
+DROP INDEX IF EXISTS deliveries_address_trgm_idx;
+DROP INDEX IF EXISTS deliveries_city_trgm_idx;
+DROP INDEX IF EXISTS deliveries_name_trgm_idx;
+DROP INDEX IF EXISTS items_nm_id_idx;
+DROP INDEX IF EXISTS deliveries_email_idx;
+DROP INDEX IF EXISTS deliveries_phone_idx;
+DROP INDEX IF EXISTS orders_payment_id_idx;
+DROP INDEX IF EXISTS orders_delivery_id_idx;
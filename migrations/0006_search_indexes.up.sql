@@ -0,0 +1,14 @@
+-- Индексы для поиска заказов по связанным сущностям.
+CREATE EXTENSION IF NOT EXISTS pg_trgm WITH SCHEMA public;
+
+CREATE INDEX IF NOT EXISTS orders_delivery_id_idx ON orders (delivery_id);
+CREATE INDEX IF NOT EXISTS orders_payment_id_idx ON orders (payment_id);
+CREATE INDEX IF NOT EXISTS deliveries_phone_idx ON deliveries (phone);
+CREATE INDEX IF NOT EXISTS deliveries_email_idx ON deliveries (lower(email));
+CREATE INDEX IF NOT EXISTS items_nm_id_idx ON items (nm_id);
+
+CREATE INDEX IF NOT EXISTS deliveries_name_trgm_idx ON deliveries USING gin (name public.gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS deliveries_city_trgm_idx ON deliveries USING gin (city public.gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS deliveries_address_trgm_idx ON deliveries USING gin (address public.gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS items_name_trgm_idx ON items USING gin (name public.gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS items_brand_trgm_idx ON items USING gin (brand public.gin_trgm_ops);
@@ -0,0 +1,13 @@
+-- Удаленные заказы очищаются, иначе после удаления столбца они снова станут видимы.
+WITH removed AS (
+    DELETE FROM orders WHERE deleted_at IS NOT NULL RETURNING delivery_id, payment_id
+), removed_deliveries AS (
+    DELETE FROM deliveries WHERE id IN (SELECT delivery_id FROM removed)
+)
+DELETE FROM payments WHERE id IN (SELECT payment_id FROM removed);
+DELETE FROM order_revisions WHERE operation = 'restore';
+ALTER TABLE order_revisions DROP CONSTRAINT IF EXISTS order_revisions_operation_check;
+ALTER TABLE order_revisions ADD CONSTRAINT order_revisions_operation_check
+    CHECK (operation IN ('create', 'update', 'delete'));
+DROP INDEX IF EXISTS orders_deleted_at_idx;
+ALTER TABLE orders DROP COLUMN IF EXISTS deleted_at;
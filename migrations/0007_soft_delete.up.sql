@@ -0,0 +1,8 @@
+-- Мягкое удаление заказов: удаленный заказ скрыт от чтения до окончательной очистки.
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+CREATE INDEX IF NOT EXISTS orders_deleted_at_idx ON orders (deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- Восстановление удаленного заказа фиксируется в истории отдельной операцией.
+ALTER TABLE order_revisions DROP CONSTRAINT IF EXISTS order_revisions_operation_check;
+ALTER TABLE order_revisions ADD CONSTRAINT order_revisions_operation_check
+    CHECK (operation IN ('create', 'update', 'delete', 'restore'));
@@ -0,0 +1,71 @@
+-- Возврат к несекционированным таблицам. Отсоединенные секции остаются отдельными таблицами.
+ALTER TABLE items RENAME TO items_partitioned;
+ALTER TABLE items_partitioned RENAME CONSTRAINT items_pkey TO items_partitioned_pkey;
+ALTER TABLE orders RENAME TO orders_partitioned;
+ALTER TABLE orders_partitioned RENAME CONSTRAINT orders_pkey TO orders_partitioned_pkey;
+
+CREATE TABLE orders (
+    order_uid UUID PRIMARY KEY,
+    track_number UUID NOT NULL UNIQUE,
+    entry TEXT,
+    delivery_id UUID REFERENCES deliveries(id) ON DELETE CASCADE,
+    payment_id UUID REFERENCES payments(id) ON DELETE CASCADE,
+    locale TEXT NOT NULL,
+    internal_signature TEXT,
+    customer_id UUID NOT NULL,
+    delivery_service TEXT NOT NULL,
+    shardkey TEXT NOT NULL,
+    sm_id BIGINT NOT NULL,
+    date_created TIMESTAMP NOT NULL DEFAULT NOW(),
+    oof_shard TEXT,
+    deleted_at TIMESTAMPTZ
+);
+
+CREATE TABLE items (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    chrt_id BIGINT NOT NULL,
+    track_number TEXT NOT NULL,
+    price BIGINT NOT NULL,
+    rid TEXT NOT NULL,
+    name TEXT NOT NULL,
+    sale INT NOT NULL,
+    size TEXT NOT NULL,
+    total_price BIGINT NOT NULL,
+    nm_id BIGINT NOT NULL,
+    brand TEXT NOT NULL,
+    status INT NOT NULL,
+    order_uid UUID REFERENCES orders(order_uid) ON DELETE CASCADE,
+    position INT NOT NULL DEFAULT 0
+);
+
+INSERT INTO orders (
+    order_uid, track_number, entry, delivery_id, payment_id, locale, internal_signature, customer_id,
+    delivery_service, shardkey, sm_id, date_created, oof_shard, deleted_at
+)
+SELECT order_uid, track_number, entry, delivery_id, payment_id, locale, internal_signature, customer_id,
+    delivery_service, shardkey, sm_id, date_created, oof_shard, deleted_at
+FROM orders_partitioned;
+
+INSERT INTO items (
+    id, chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status, order_uid, position
+)
+SELECT id, chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status, order_uid, position
+FROM items_partitioned;
+
+DROP TABLE items_partitioned;
+DROP TABLE orders_partitioned;
+DROP TABLE order_keys;
+
+CREATE INDEX orders_date_created_uid_idx ON orders (date_created, order_uid);
+CREATE INDEX orders_entry_idx ON orders (entry, date_created, order_uid);
+CREATE INDEX orders_delivery_service_idx ON orders (delivery_service, date_created, order_uid);
+CREATE INDEX orders_locale_idx ON orders (locale, date_created, order_uid);
+CREATE INDEX orders_customer_id_idx ON orders (customer_id, date_created, order_uid);
+CREATE INDEX orders_delivery_id_idx ON orders (delivery_id);
+CREATE INDEX orders_payment_id_idx ON orders (payment_id);
+CREATE INDEX orders_deleted_at_idx ON orders (deleted_at) WHERE deleted_at IS NOT NULL;
+
+CREATE INDEX items_order_uid_idx ON items (order_uid, position);
+CREATE INDEX items_nm_id_idx ON items (nm_id);
+CREATE INDEX items_name_trgm_idx ON items USING gin (name public.gin_trgm_ops);
+CREATE INDEX items_brand_trgm_idx ON items USING gin (brand public.gin_trgm_ops);
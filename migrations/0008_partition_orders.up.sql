@@ -0,0 +1,95 @@
+-- Секционирование заказов и товаров по дате создания.
+-- Первичный ключ секционированной таблицы обязан включать ключ секционирования,
+-- поэтому уникальность order_uid и track_number между секциями обеспечивает таблица order_keys.
+-- Товары связаны с заказом приложением, а не внешним ключом, чтобы секции
+-- можно было отсоединять и удалять независимо друг от друга.
+-- Существующие строки попадают в секцию по умолчанию и переносятся
+-- в помесячные секции при обслуживании секций.
+ALTER TABLE items RENAME TO items_unpartitioned;
+ALTER TABLE items_unpartitioned RENAME CONSTRAINT items_pkey TO items_unpartitioned_pkey;
+ALTER TABLE orders RENAME TO orders_unpartitioned;
+ALTER TABLE orders_unpartitioned RENAME CONSTRAINT orders_pkey TO orders_unpartitioned_pkey;
+
+CREATE TABLE order_keys (
+    order_uid UUID PRIMARY KEY,
+    track_number UUID NOT NULL UNIQUE,
+    date_created TIMESTAMP NOT NULL
+);
+CREATE INDEX order_keys_date_created_idx ON order_keys (date_created);
+
+CREATE TABLE orders (
+    order_uid UUID NOT NULL,
+    track_number UUID NOT NULL,
+    entry TEXT,
+    delivery_id UUID REFERENCES deliveries(id) ON DELETE CASCADE,
+    payment_id UUID REFERENCES payments(id) ON DELETE CASCADE,
+    locale TEXT NOT NULL,
+    internal_signature TEXT,
+    customer_id UUID NOT NULL,
+    delivery_service TEXT NOT NULL,
+    shardkey TEXT NOT NULL,
+    sm_id BIGINT NOT NULL,
+    date_created TIMESTAMP NOT NULL DEFAULT NOW(),
+    oof_shard TEXT,
+    deleted_at TIMESTAMPTZ,
+    PRIMARY KEY (order_uid, date_created)
+) PARTITION BY RANGE (date_created);
+CREATE TABLE orders_default PARTITION OF orders DEFAULT;
+
+CREATE TABLE items (
+    id UUID NOT NULL DEFAULT gen_random_uuid(),
+    order_uid UUID NOT NULL,
+    date_created TIMESTAMP NOT NULL,
+    position INT NOT NULL DEFAULT 0,
+    chrt_id BIGINT NOT NULL,
+    track_number TEXT NOT NULL,
+    price BIGINT NOT NULL,
+    rid TEXT NOT NULL,
+    name TEXT NOT NULL,
+    sale INT NOT NULL,
+    size TEXT NOT NULL,
+    total_price BIGINT NOT NULL,
+    nm_id BIGINT NOT NULL,
+    brand TEXT NOT NULL,
+    status INT NOT NULL,
+    PRIMARY KEY (id, date_created)
+) PARTITION BY RANGE (date_created);
+CREATE TABLE items_default PARTITION OF items DEFAULT;
+
+INSERT INTO order_keys (order_uid, track_number, date_created)
+SELECT order_uid, track_number, date_created FROM orders_unpartitioned;
+
+INSERT INTO orders (
+    order_uid, track_number, entry, delivery_id, payment_id, locale, internal_signature, customer_id,
+    delivery_service, shardkey, sm_id, date_created, oof_shard, deleted_at
+)
+SELECT order_uid, track_number, entry, delivery_id, payment_id, locale, internal_signature, customer_id,
+    delivery_service, shardkey, sm_id, date_created, oof_shard, deleted_at
+FROM orders_unpartitioned;
+
+INSERT INTO items (
+    id, order_uid, date_created, position, chrt_id, track_number, price, rid, name, sale, size,
+    total_price, nm_id, brand, status
+)
+SELECT i.id, i.order_uid, o.date_created, i.position, i.chrt_id, i.track_number, i.price, i.rid, i.name,
+    i.sale, i.size, i.total_price, i.nm_id, i.brand, i.status
+FROM items_unpartitioned i
+JOIN orders_unpartitioned o ON o.order_uid = i.order_uid;
+
+DROP TABLE items_unpartitioned;
+DROP TABLE orders_unpartitioned;
+
+CREATE INDEX orders_track_number_idx ON orders (track_number);
+CREATE INDEX orders_date_created_uid_idx ON orders (date_created, order_uid);
+CREATE INDEX orders_entry_idx ON orders (entry, date_created, order_uid);
+CREATE INDEX orders_delivery_service_idx ON orders (delivery_service, date_created, order_uid);
+CREATE INDEX orders_locale_idx ON orders (locale, date_created, order_uid);
+CREATE INDEX orders_customer_id_idx ON orders (customer_id, date_created, order_uid);
+CREATE INDEX orders_delivery_id_idx ON orders (delivery_id);
+CREATE INDEX orders_payment_id_idx ON orders (payment_id);
+CREATE INDEX orders_deleted_at_idx ON orders (deleted_at) WHERE deleted_at IS NOT NULL;
+
+CREATE INDEX items_order_uid_idx ON items (order_uid, position);
+CREATE INDEX items_nm_id_idx ON items (nm_id);
+CREATE INDEX items_name_trgm_idx ON items USING gin (name public.gin_trgm_ops);
+CREATE INDEX items_brand_trgm_idx ON items USING gin (brand public.gin_trgm_ops);
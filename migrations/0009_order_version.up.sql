@@ -0,0 +1,2 @@
+-- Версия заказа для оптимистичной блокировки: увеличивается при каждом изменении.
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS version INT NOT NULL DEFAULT 1;
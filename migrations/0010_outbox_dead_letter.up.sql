@@ -0,0 +1,4 @@
+-- События outbox, исчерпавшие попытки доставки, откладываются с отметкой dead_at и не блокируют
+-- последующие события заказа. Такие события не удаляются очисткой outbox.
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS dead_at TIMESTAMPTZ;
+CREATE INDEX IF NOT EXISTS outbox_dead_idx ON outbox (dead_at) WHERE dead_at IS NOT NULL;
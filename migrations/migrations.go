@@ -0,0 +1,12 @@
+// Package migrations содержит SQL-миграции схемы заказов, встроенные в бинарный файл.
+//
+// Каждая миграция состоит из пары файлов NNNN_name.up.sql и NNNN_name.down.sql.
+// Миграции не указывают схему явно: исполнитель выставляет search_path на целевую схему.
+package migrations
+
+import "embed"
+
+// FS содержит все файлы миграций.
+//
+//go:embed *.sql
+var FS embed.FS
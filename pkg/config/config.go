@@ -4,32 +4,124 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // IConfiguration определяет интерфейс для конфигурационных настроек.
 type IConfiguration interface {
+	GetStorageBackend() string
+	GetStorageFile() string
 	GetDBConnectionString() string
+	GetDBReplicaConnectionStrings() []string
+	GetDBReplicaMaxLag() time.Duration
+	GetDBReplicaCheckInterval() time.Duration
+	GetDBConnectAttempts() int
+	GetDBConnectBackoff() time.Duration
+	GetDBConnectMaxBackoff() time.Duration
+	GetDBMaxOpenConns() int
+	GetDBMaxIdleConns() int
+	GetDBConnMaxLifetime() time.Duration
+	GetDBConnMaxIdleTime() time.Duration
+	GetDBBreakerThreshold() int
+	GetDBBreakerCooldown() time.Duration
+	GetTenantSchemas() []string
+	GetTenantDefaultSchema() string
 	GetRedisAddr() string
 	GetRedisPassword() string
 	GetRedisDB() int
+	GetCacheLocalMaxEntries() int
+	GetCacheLocalMaxBytes() int
+	GetCacheBackend() string
+	GetCacheCodec() string
+	GetCacheCompression() string
+	GetCacheTTLPolicy() string
+	GetCacheTTL() time.Duration
+	GetCacheTTLMaxAge() time.Duration
+	GetCacheMaxEntries() int
+	GetCacheNegativeTTL() time.Duration
+	GetCacheReconcileInterval() time.Duration
+	GetCacheReconcileRepair() bool
+	GetCacheSnapshotFile() string
+	GetCacheSnapshotInterval() time.Duration
+	GetCacheSnapshotMaxAge() time.Duration
 	GetServerPort() int
 	GetLogLevel() string
 	GetNATSURL() string
 	GetNATSClusterID() string
 	GetNATSClientID() string
+	GetNATSBatchSize() int
+	GetNATSBatchWait() time.Duration
+	GetOrderConflictPolicy() string
+	GetOrderDeletedRetention() time.Duration
+	GetOrderMaxAge() time.Duration
+	GetOrderRetentionInterval() time.Duration
+	GetPartitionPremake() int
+	GetPartitionRetention() time.Duration
+	GetPartitionExpiredAction() string
+	GetPartitionInterval() time.Duration
+	GetOutboxPollInterval() time.Duration
+	GetOutboxBatchSize() int
+	GetOutboxMaxAttempts() int
+	GetOutboxEventsSubject() string
 }
 
 // Configuration содержит конфигурационные настройки.
 type Configuration struct {
-	DBConnectionString string
-	RedisAddr          string
-	RedisPassword      string
-	RedisDB            int
-	ServerPort         int
-	LogLevel           string
-	NATSURL            string
-	NATSClusterID      string
-	NATSClientID       string
+	StorageBackend      string
+	StorageFile         string
+	DBConnectionString  string
+	DBReplicaStrings    []string
+	DBReplicaMaxLag     time.Duration
+	DBReplicaCheck      time.Duration
+	DBConnectAttempts   int
+	DBConnectBackoff    time.Duration
+	DBConnectMaxBackoff time.Duration
+	DBMaxOpenConns      int
+	DBMaxIdleConns      int
+	DBConnMaxLifetime   time.Duration
+	DBConnMaxIdleTime   time.Duration
+	DBBreakerThreshold  int
+	DBBreakerCooldown   time.Duration
+	TenantSchemas       []string
+	TenantDefaultSchema string
+	RedisAddr           string
+	RedisPassword       string
+	RedisDB             int
+	CacheLocalEntries   int
+	CacheLocalBytes     int
+	CacheBackend        string
+	CacheCodec          string
+	CacheCompression    string
+	CacheTTLPolicy      string
+	CacheTTL            time.Duration
+	CacheTTLMaxAge      time.Duration
+	CacheMaxEntries     int
+	CacheNegativeTTL    time.Duration
+	CacheReconcileEvery time.Duration
+	CacheReconcileFix   bool
+	CacheSnapshotFile   string
+	CacheSnapshotEvery  time.Duration
+	CacheSnapshotMaxAge time.Duration
+	ServerPort          int
+	LogLevel            string
+	NATSURL             string
+	NATSClusterID       string
+	NATSClientID        string
+	NATSBatchSize       int
+	NATSBatchWait       time.Duration
+	OrderConflictPolicy string
+	OrderDeletedRetain  time.Duration
+	OrderMaxAge         time.Duration
+	OrderRetentionCheck time.Duration
+	PartitionPremake    int
+	PartitionRetention  time.Duration
+	PartitionExpired    string
+	PartitionInterval   time.Duration
+	OutboxPollInterval  time.Duration
+	OutboxBatchSize     int
+	OutboxMaxAttempts   int
+	OutboxEventsSubject string
 }
 
 // NewConfiguration загружает конфигурационные настройки из переменных окружения.
@@ -39,21 +131,226 @@ func NewConfiguration() IConfiguration {
 		log.Fatalf("Ошибка преобразования REDIS_DB: %v", err)
 	}
 
+	cacheLocalEntries, err := getEnvAsInt("CACHE_LOCAL_MAX_ENTRIES", 10000)
+	if err != nil {
+		log.Fatalf("Ошибка преобразования CACHE_LOCAL_MAX_ENTRIES: %v", err)
+	}
+
+	cacheLocalBytes, err := getEnvAsInt("CACHE_LOCAL_MAX_BYTES", 64<<20)
+	if err != nil {
+		log.Fatalf("Ошибка преобразования CACHE_LOCAL_MAX_BYTES: %v", err)
+	}
+
+	cacheTTL, err := getEnvAsDuration("CACHE_TTL", 24*time.Hour)
+	if err != nil {
+		log.Fatalf("Ошибка преобразования CACHE_TTL: %v", err)
+	}
+
+	cacheTTLMaxAge, err := getEnvAsDuration("CACHE_TTL_MAX_AGE", 30*24*time.Hour)
+	if err != nil {
+		log.Fatalf("Ошибка преобразования CACHE_TTL_MAX_AGE: %v", err)
+	}
+
+	cacheMaxEntries, err := getEnvAsInt("CACHE_MAX_ENTRIES", 0)
+	if err != nil {
+		log.Fatalf("Ошибка преобразования CACHE_MAX_ENTRIES: %v", err)
+	}
+
+	cacheNegativeTTL, err := getEnvAsDuration("CACHE_NEGATIVE_TTL", 5*time.Second)
+	if err != nil {
+		log.Fatalf("Ошибка преобразования CACHE_NEGATIVE_TTL: %v", err)
+	}
+
+	cacheReconcileEvery, err := getEnvAsDuration("CACHE_RECONCILE_INTERVAL", 0)
+	if err != nil {
+		log.Fatalf("Ошибка преобразования CACHE_RECONCILE_INTERVAL: %v", err)
+	}
+
+	cacheReconcileFix, err := getEnvAsBool("CACHE_RECONCILE_REPAIR", false)
+	if err != nil {
+		log.Fatalf("Ошибка преобразования CACHE_RECONCILE_REPAIR: %v", err)
+	}
+
+	cacheSnapshotEvery, err := getEnvAsDuration("CACHE_SNAPSHOT_INTERVAL", 5*time.Minute)
+	if err != nil {
+		log.Fatalf("Ошибка преобразования CACHE_SNAPSHOT_INTERVAL: %v", err)
+	}
+
+	cacheSnapshotMaxAge, err := getEnvAsDuration("CACHE_SNAPSHOT_MAX_AGE", time.Hour)
+	if err != nil {
+		log.Fatalf("Ошибка преобразования CACHE_SNAPSHOT_MAX_AGE: %v", err)
+	}
+
 	serverPort, err := getEnvAsInt("SERVER_PORT", 8080)
 	if err != nil {
 		log.Fatalf("Ошибка преобразования SERVER_PORT: %v", err)
 	}
 
+	outboxPollInterval, err := getEnvAsDuration("OUTBOX_POLL_INTERVAL", time.Second)
+	if err != nil {
+		log.Fatalf("Ошибка преобразования OUTBOX_POLL_INTERVAL: %v", err)
+	}
+
+	outboxBatchSize, err := getEnvAsInt("OUTBOX_BATCH_SIZE", 100)
+	if err != nil {
+		log.Fatalf("Ошибка преобразования OUTBOX_BATCH_SIZE: %v", err)
+	}
+
+	outboxMaxAttempts, err := getEnvAsInt("OUTBOX_MAX_ATTEMPTS", 10)
+	if err != nil {
+		log.Fatalf("Ошибка преобразования OUTBOX_MAX_ATTEMPTS: %v", err)
+	}
+
+	natsBatchSize, err := getEnvAsInt("NATS_BATCH_SIZE", 1)
+	if err != nil {
+		log.Fatalf("Ошибка преобразования NATS_BATCH_SIZE: %v", err)
+	}
+
+	natsBatchWait, err := getEnvAsDuration("NATS_BATCH_WAIT", 500*time.Millisecond)
+	if err != nil {
+		log.Fatalf("Ошибка преобразования NATS_BATCH_WAIT: %v", err)
+	}
+
+	replicaMaxLag, err := getEnvAsDuration("DB_REPLICA_MAX_LAG", 10*time.Second)
+	if err != nil {
+		log.Fatalf("Ошибка преобразования DB_REPLICA_MAX_LAG: %v", err)
+	}
+
+	replicaCheck, err := getEnvAsDuration("DB_REPLICA_CHECK_INTERVAL", 5*time.Second)
+	if err != nil {
+		log.Fatalf("Ошибка преобразования DB_REPLICA_CHECK_INTERVAL: %v", err)
+	}
+
+	dbConnectAttempts, err := getEnvAsInt("DB_CONNECT_ATTEMPTS", 10)
+	if err != nil {
+		log.Fatalf("Ошибка преобразования DB_CONNECT_ATTEMPTS: %v", err)
+	}
+
+	dbConnectBackoff, err := getEnvAsDuration("DB_CONNECT_BACKOFF", time.Second)
+	if err != nil {
+		log.Fatalf("Ошибка преобразования DB_CONNECT_BACKOFF: %v", err)
+	}
+
+	dbConnectMaxBackoff, err := getEnvAsDuration("DB_CONNECT_MAX_BACKOFF", 30*time.Second)
+	if err != nil {
+		log.Fatalf("Ошибка преобразования DB_CONNECT_MAX_BACKOFF: %v", err)
+	}
+
+	dbMaxOpenConns, err := getEnvAsInt("DB_MAX_OPEN_CONNS", 25)
+	if err != nil {
+		log.Fatalf("Ошибка преобразования DB_MAX_OPEN_CONNS: %v", err)
+	}
+
+	dbMaxIdleConns, err := getEnvAsInt("DB_MAX_IDLE_CONNS", 5)
+	if err != nil {
+		log.Fatalf("Ошибка преобразования DB_MAX_IDLE_CONNS: %v", err)
+	}
+
+	dbConnMaxLifetime, err := getEnvAsDuration("DB_CONN_MAX_LIFETIME", 30*time.Minute)
+	if err != nil {
+		log.Fatalf("Ошибка преобразования DB_CONN_MAX_LIFETIME: %v", err)
+	}
+
+	dbConnMaxIdleTime, err := getEnvAsDuration("DB_CONN_MAX_IDLE_TIME", 5*time.Minute)
+	if err != nil {
+		log.Fatalf("Ошибка преобразования DB_CONN_MAX_IDLE_TIME: %v", err)
+	}
+
+	dbBreakerThreshold, err := getEnvAsInt("DB_BREAKER_THRESHOLD", 5)
+	if err != nil {
+		log.Fatalf("Ошибка преобразования DB_BREAKER_THRESHOLD: %v", err)
+	}
+
+	dbBreakerCooldown, err := getEnvAsDuration("DB_BREAKER_COOLDOWN", 30*time.Second)
+	if err != nil {
+		log.Fatalf("Ошибка преобразования DB_BREAKER_COOLDOWN: %v", err)
+	}
+
+	orderDeletedRetain, err := getEnvAsDuration("ORDER_DELETED_RETENTION", 30*24*time.Hour)
+	if err != nil {
+		log.Fatalf("Ошибка преобразования ORDER_DELETED_RETENTION: %v", err)
+	}
+
+	orderMaxAge, err := getEnvAsDuration("ORDER_MAX_AGE", 0)
+	if err != nil {
+		log.Fatalf("Ошибка преобразования ORDER_MAX_AGE: %v", err)
+	}
+
+	orderRetentionCheck, err := getEnvAsDuration("ORDER_RETENTION_INTERVAL", time.Hour)
+	if err != nil {
+		log.Fatalf("Ошибка преобразования ORDER_RETENTION_INTERVAL: %v", err)
+	}
+
+	partitionPremake, err := getEnvAsInt("ORDER_PARTITION_PREMAKE", 3)
+	if err != nil {
+		log.Fatalf("Ошибка преобразования ORDER_PARTITION_PREMAKE: %v", err)
+	}
+
+	partitionRetention, err := getEnvAsDuration("ORDER_PARTITION_RETENTION", 0)
+	if err != nil {
+		log.Fatalf("Ошибка преобразования ORDER_PARTITION_RETENTION: %v", err)
+	}
+
+	partitionInterval, err := getEnvAsDuration("ORDER_PARTITION_INTERVAL", time.Hour)
+	if err != nil {
+		log.Fatalf("Ошибка преобразования ORDER_PARTITION_INTERVAL: %v", err)
+	}
+
 	return &Configuration{
-		DBConnectionString: getEnv("DB_CONNECTION_STRING", ""),
-		RedisAddr:          getEnv("REDIS_ADDR", "localhost:6379"),
-		RedisPassword:      getEnv("REDIS_PASSWORD", ""),
-		RedisDB:            redisDB,
-		ServerPort:         serverPort,
-		LogLevel:           getEnv("LOG_LEVEL", "info"),
-		NATSURL:            getEnv("NATS_URL", "nats://localhost:4222"),
-		NATSClusterID:      getEnv("NATS_CLUSTER_ID", "test-cluster"),
-		NATSClientID:       getEnv("NATS_CLIENT_ID", "client-123"),
+		StorageBackend:      getEnv("STORAGE_BACKEND", "postgres"),
+		StorageFile:         getEnv("STORAGE_FILE", "orders.json"),
+		DBConnectionString:  getEnv("DB_CONNECTION_STRING", ""),
+		DBReplicaStrings:    getEnvAsList("DB_REPLICA_CONNECTION_STRINGS"),
+		DBReplicaMaxLag:     replicaMaxLag,
+		DBReplicaCheck:      replicaCheck,
+		DBConnectAttempts:   dbConnectAttempts,
+		DBConnectBackoff:    dbConnectBackoff,
+		DBConnectMaxBackoff: dbConnectMaxBackoff,
+		DBMaxOpenConns:      dbMaxOpenConns,
+		DBMaxIdleConns:      dbMaxIdleConns,
+		DBConnMaxLifetime:   dbConnMaxLifetime,
+		DBConnMaxIdleTime:   dbConnMaxIdleTime,
+		DBBreakerThreshold:  dbBreakerThreshold,
+		DBBreakerCooldown:   dbBreakerCooldown,
+		TenantSchemas:       getEnvAsList("TENANT_SCHEMAS"),
+		TenantDefaultSchema: getEnv("TENANT_DEFAULT_SCHEMA", "ecommerce"),
+		RedisAddr:           getEnv("REDIS_ADDR", "localhost:6379"),
+		RedisPassword:       getEnv("REDIS_PASSWORD", ""),
+		RedisDB:             redisDB,
+		CacheLocalEntries:   cacheLocalEntries,
+		CacheLocalBytes:     cacheLocalBytes,
+		CacheBackend:        getEnv("CACHE_BACKEND", "redis"),
+		CacheCodec:          getEnv("CACHE_CODEC", "msgpack"),
+		CacheCompression:    getEnv("CACHE_COMPRESSION", "zstd"),
+		CacheTTLPolicy:      getEnv("CACHE_TTL_POLICY", "none"),
+		CacheTTL:            cacheTTL,
+		CacheTTLMaxAge:      cacheTTLMaxAge,
+		CacheMaxEntries:     cacheMaxEntries,
+		CacheNegativeTTL:    cacheNegativeTTL,
+		CacheReconcileEvery: cacheReconcileEvery,
+		CacheReconcileFix:   cacheReconcileFix,
+		CacheSnapshotFile:   getEnv("CACHE_SNAPSHOT_FILE", "cache-snapshot.json"),
+		CacheSnapshotEvery:  cacheSnapshotEvery,
+		CacheSnapshotMaxAge: cacheSnapshotMaxAge,
+		ServerPort:          serverPort,
+		LogLevel:            getEnv("LOG_LEVEL", "info"),
+		NATSURL:             getEnv("NATS_URL", "nats://localhost:4222"),
+		NATSClusterID:       getEnv("NATS_CLUSTER_ID", "test-cluster"),
+		NATSClientID:        getEnv("NATS_CLIENT_ID", "client-123"),
+		NATSBatchSize:       natsBatchSize,
+		NATSBatchWait:       natsBatchWait,
+		OrderConflictPolicy: getEnv("ORDER_CONFLICT_POLICY", "reject"),
+		OrderDeletedRetain:  orderDeletedRetain,
+		OrderMaxAge:         orderMaxAge,
+		OrderRetentionCheck: orderRetentionCheck,
+		PartitionPremake:    partitionPremake,
+		PartitionRetention:  partitionRetention,
+		PartitionExpired:    getEnv("ORDER_PARTITION_EXPIRED_ACTION", "detach"),
+		PartitionInterval:   partitionInterval,
+		OutboxPollInterval:  outboxPollInterval,
+		OutboxBatchSize:     outboxBatchSize,
+		OutboxMaxAttempts:   outboxMaxAttempts,
+		OutboxEventsSubject: getEnv("OUTBOX_EVENTS_SUBJECT", ""),
 	}
 }
 
@@ -78,11 +375,120 @@ func getEnvAsInt(key string, defaultValue int) (int, error) {
 	return value, nil
 }
 
+// getEnvAsDuration получает значение переменной окружения как длительность (например, 1s, 5m) или возвращает значение по умолчанию.
+func getEnvAsDuration(key string, defaultValue time.Duration) (time.Duration, error) {
+	valueStr := getEnv(key, "")
+	if valueStr == "" {
+		return defaultValue, nil
+	}
+	return time.ParseDuration(valueStr)
+}
+
+// getEnvAsBool получает значение переменной окружения как логическое значение (true, false, 1, 0) или возвращает значение по умолчанию.
+func getEnvAsBool(key string, defaultValue bool) (bool, error) {
+	valueStr := getEnv(key, "")
+	if valueStr == "" {
+		return defaultValue, nil
+	}
+	return strconv.ParseBool(valueStr)
+}
+
+// getEnvAsList получает значение переменной окружения как список строк, разделенных запятыми.
+func getEnvAsList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(getEnv(key, ""), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
 // GetDBConnectionString возвращает строку подключения к базе данных.
 func (c *Configuration) GetDBConnectionString() string {
 	return c.DBConnectionString
 }
 
+// GetDBReplicaConnectionStrings возвращает строки подключения к репликам для чтения.
+func (c *Configuration) GetDBReplicaConnectionStrings() []string {
+	return c.DBReplicaStrings
+}
+
+// GetDBReplicaMaxLag возвращает допустимое отставание реплики.
+func (c *Configuration) GetDBReplicaMaxLag() time.Duration {
+	return c.DBReplicaMaxLag
+}
+
+// GetDBReplicaCheckInterval возвращает периодичность проверки реплик.
+func (c *Configuration) GetDBReplicaCheckInterval() time.Duration {
+	return c.DBReplicaCheck
+}
+
+// GetDBConnectAttempts возвращает число попыток подключения к базе данных при старте.
+func (c *Configuration) GetDBConnectAttempts() int {
+	return c.DBConnectAttempts
+}
+
+// GetDBConnectBackoff возвращает паузу перед второй попыткой подключения; пауза удваивается после каждой неудачи.
+func (c *Configuration) GetDBConnectBackoff() time.Duration {
+	return c.DBConnectBackoff
+}
+
+// GetDBConnectMaxBackoff возвращает максимальную паузу между попытками подключения.
+func (c *Configuration) GetDBConnectMaxBackoff() time.Duration {
+	return c.DBConnectMaxBackoff
+}
+
+// GetDBMaxOpenConns возвращает максимальное число открытых соединений с базой данных.
+func (c *Configuration) GetDBMaxOpenConns() int {
+	return c.DBMaxOpenConns
+}
+
+// GetDBMaxIdleConns возвращает максимальное число простаивающих соединений с базой данных.
+func (c *Configuration) GetDBMaxIdleConns() int {
+	return c.DBMaxIdleConns
+}
+
+// GetDBConnMaxLifetime возвращает максимальное время жизни соединения с базой данных.
+func (c *Configuration) GetDBConnMaxLifetime() time.Duration {
+	return c.DBConnMaxLifetime
+}
+
+// GetDBConnMaxIdleTime возвращает максимальное время простоя соединения с базой данных.
+func (c *Configuration) GetDBConnMaxIdleTime() time.Duration {
+	return c.DBConnMaxIdleTime
+}
+
+// GetDBBreakerThreshold возвращает число подряд ошибок недоступности базы, после которого запросы приостанавливаются; 0 отключает выключатель.
+func (c *Configuration) GetDBBreakerThreshold() int {
+	return c.DBBreakerThreshold
+}
+
+// GetDBBreakerCooldown возвращает паузу, в течение которой запросы к недоступной базе отклоняются.
+func (c *Configuration) GetDBBreakerCooldown() time.Duration {
+	return c.DBBreakerCooldown
+}
+
+// GetStorageBackend возвращает хранилище заказов: postgres или file.
+func (c *Configuration) GetStorageBackend() string {
+	return c.StorageBackend
+}
+
+// GetStorageFile возвращает путь к файлу встроенного хранилища заказов.
+func (c *Configuration) GetStorageFile() string {
+	return c.StorageFile
+}
+
+// GetTenantSchemas возвращает сопоставления арендаторов со схемами в виде "арендатор=схема".
+func (c *Configuration) GetTenantSchemas() []string {
+	return c.TenantSchemas
+}
+
+// GetTenantDefaultSchema возвращает схему арендаторов без явного сопоставления; пустая строка отклоняет их заказы.
+func (c *Configuration) GetTenantDefaultSchema() string {
+	return c.TenantDefaultSchema
+}
+
 // GetRedisAddr возвращает адрес Redis.
 func (c *Configuration) GetRedisAddr() string {
 	return c.RedisAddr
@@ -98,6 +504,81 @@ func (c *Configuration) GetRedisDB() int {
 	return c.RedisDB
 }
 
+// GetCacheLocalMaxEntries возвращает максимальное число заказов в локальном кэше экземпляра; 0 — без ограничения.
+func (c *Configuration) GetCacheLocalMaxEntries() int {
+	return c.CacheLocalEntries
+}
+
+// GetCacheLocalMaxBytes возвращает максимальный объем заказов в локальном кэше экземпляра в байтах; 0 — без ограничения.
+func (c *Configuration) GetCacheLocalMaxBytes() int {
+	return c.CacheLocalBytes
+}
+
+// GetCacheBackend возвращает хранилище, из которого выдаются заказы: redis, memory или database.
+func (c *Configuration) GetCacheBackend() string {
+	return c.CacheBackend
+}
+
+// GetCacheCodec возвращает кодек, которым заказы записываются в Redis: json или msgpack.
+func (c *Configuration) GetCacheCodec() string {
+	return c.CacheCodec
+}
+
+// GetCacheCompression возвращает алгоритм сжатия заказов в Redis: none или zstd.
+func (c *Configuration) GetCacheCompression() string {
+	return c.CacheCompression
+}
+
+// GetCacheTTLPolicy возвращает режим времени жизни заказов в кэше: none, fixed или age.
+func (c *Configuration) GetCacheTTLPolicy() string {
+	return c.CacheTTLPolicy
+}
+
+// GetCacheTTL возвращает время жизни заказа в кэше; в режиме age — время жизни заказа старше CACHE_TTL_MAX_AGE.
+func (c *Configuration) GetCacheTTL() time.Duration {
+	return c.CacheTTL
+}
+
+// GetCacheTTLMaxAge возвращает возраст заказа по дате создания, до которого он хранится в кэше в режиме age.
+func (c *Configuration) GetCacheTTLMaxAge() time.Duration {
+	return c.CacheTTLMaxAge
+}
+
+// GetCacheMaxEntries возвращает максимальное число заказов в кэше; 0 — без ограничения.
+func (c *Configuration) GetCacheMaxEntries() int {
+	return c.CacheMaxEntries
+}
+
+// GetCacheNegativeTTL возвращает, сколько кэш помнит об отсутствии заказа в базе данных; 0 — не помнит.
+func (c *Configuration) GetCacheNegativeTTL() time.Duration {
+	return c.CacheNegativeTTL
+}
+
+// GetCacheReconcileInterval возвращает периодичность сверки кэша с базой данных; 0 — сверка не выполняется.
+func (c *Configuration) GetCacheReconcileInterval() time.Duration {
+	return c.CacheReconcileEvery
+}
+
+// GetCacheReconcileRepair сообщает, исправлять ли расхождения, найденные периодической сверкой кэша.
+func (c *Configuration) GetCacheReconcileRepair() bool {
+	return c.CacheReconcileFix
+}
+
+// GetCacheSnapshotFile возвращает путь к файлу снимка кэша в памяти процесса; пустая строка отключает снимки.
+func (c *Configuration) GetCacheSnapshotFile() string {
+	return c.CacheSnapshotFile
+}
+
+// GetCacheSnapshotInterval возвращает периодичность сохранения снимка кэша в памяти процесса.
+func (c *Configuration) GetCacheSnapshotInterval() time.Duration {
+	return c.CacheSnapshotEvery
+}
+
+// GetCacheSnapshotMaxAge возвращает максимальный возраст снимка кэша, который восстанавливается при запуске.
+func (c *Configuration) GetCacheSnapshotMaxAge() time.Duration {
+	return c.CacheSnapshotMaxAge
+}
+
 // GetServerPort возвращает порт сервера.
 func (c *Configuration) GetServerPort() int {
 	return c.ServerPort
@@ -122,3 +603,74 @@ func (c *Configuration) GetNATSClusterID() string {
 func (c *Configuration) GetNATSClientID() string {
 	return c.NATSClientID
 }
+
+// GetNATSBatchSize возвращает размер пакета сообщений NATS; значение меньше 2 отключает пакетный режим.
+func (c *Configuration) GetNATSBatchSize() int {
+	return c.NATSBatchSize
+}
+
+// GetNATSBatchWait возвращает максимальное время накопления пакета сообщений NATS.
+func (c *Configuration) GetNATSBatchWait() time.Duration {
+	return c.NATSBatchWait
+}
+
+// GetOrderConflictPolicy возвращает политику разрешения конфликтов при сохранении заказа.
+func (c *Configuration) GetOrderConflictPolicy() string {
+	return c.OrderConflictPolicy
+}
+
+// GetOrderDeletedRetention возвращает срок хранения удаленного заказа до окончательной очистки; 0 отключает очистку.
+func (c *Configuration) GetOrderDeletedRetention() time.Duration {
+	return c.OrderDeletedRetain
+}
+
+// GetOrderMaxAge возвращает предельный возраст заказа, после которого он очищается; 0 отключает ограничение.
+func (c *Configuration) GetOrderMaxAge() time.Duration {
+	return c.OrderMaxAge
+}
+
+// GetOrderRetentionInterval возвращает периодичность очистки устаревших заказов.
+func (c *Configuration) GetOrderRetentionInterval() time.Duration {
+	return c.OrderRetentionCheck
+}
+
+// GetPartitionPremake возвращает число будущих месяцев, для которых секции заказов создаются заранее.
+func (c *Configuration) GetPartitionPremake() int {
+	return c.PartitionPremake
+}
+
+// GetPartitionRetention возвращает срок хранения секций заказов после их окончания; 0 отключает удаление секций.
+func (c *Configuration) GetPartitionRetention() time.Duration {
+	return c.PartitionRetention
+}
+
+// GetPartitionExpiredAction возвращает действие над устаревшими секциями: detach или drop.
+func (c *Configuration) GetPartitionExpiredAction() string {
+	return c.PartitionExpired
+}
+
+// GetPartitionInterval возвращает периодичность обслуживания секций заказов.
+func (c *Configuration) GetPartitionInterval() time.Duration {
+	return c.PartitionInterval
+}
+
+// GetOutboxPollInterval возвращает интервал опроса outbox.
+func (c *Configuration) GetOutboxPollInterval() time.Duration {
+	return c.OutboxPollInterval
+}
+
+// GetOutboxBatchSize возвращает число событий outbox, обрабатываемых за один проход.
+func (c *Configuration) GetOutboxBatchSize() int {
+	return c.OutboxBatchSize
+}
+
+// GetOutboxMaxAttempts возвращает число попыток доставки события outbox, после которого событие
+// откладывается; 0 снимает ограничение.
+func (c *Configuration) GetOutboxMaxAttempts() int {
+	return c.OutboxMaxAttempts
+}
+
+// GetOutboxEventsSubject возвращает тему NATS для публикации событий заказов; пустая строка отключает публикацию.
+func (c *Configuration) GetOutboxEventsSubject() string {
+	return c.OutboxEventsSubject
+}
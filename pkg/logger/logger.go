@@ -16,6 +16,7 @@ type Logger interface {
 	WithField(key string, value interface{}) Logger  // Добавление одного поля к записи лога
 	WithFields(fields map[string]interface{}) Logger // Добавление множества полей к записи лога
 	WithError(err error) *logrus.Entry               // Добавление ошибки к записи лога
+	Logrus() *logrus.Logger                          // Логгер logrus для пакетов, принимающих его напрямую
 }
 
 // LogrusAdapter оборачивает logrus.Logger для реализации интерфейса Logger.
@@ -86,3 +87,8 @@ func (l *LogrusAdapter) WithFields(fields map[string]interface{}) Logger {
 func (l *LogrusAdapter) WithError(err error) *logrus.Entry {
 	return l.logger.WithError(err)
 }
+
+// Logrus возвращает исходный logrus.Logger с настройками адаптера.
+func (l *LogrusAdapter) Logrus() *logrus.Logger {
+	return l.logger
+}